@@ -1202,7 +1202,7 @@ const docTemplate = `{
     "securityDefinitions": {
         "CookieAuth": {
             "type": "apiKey",
-            "name": "user_id",
+            "name": "g_session",
             "in": "cookie"
         }
     }
@@ -0,0 +1,69 @@
+package article
+
+import (
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Stat is an article row in the Big Book analytics
+type Stat struct {
+	models.KnowledgeArticle
+	HelpfulRate  float64 // % of votes that were helpful, 0 without votes
+	LastFeedback string  // Latest "not helpful" comment
+}
+
+// StaleAfter is how long a published article may go without an edit before it is listed as
+// stale (ARTICLE_STALE_DAYS, default 180).
+func StaleAfter() time.Duration {
+	days := 180
+	if v, err := strconv.Atoi(os.Getenv("ARTICLE_STALE_DAYS")); err == nil && v > 0 {
+		days = v
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// MostViewed lists published articles by views
+func MostViewed(limit int) []Stat {
+	var articles []models.KnowledgeArticle
+	database.DB.Where("is_verified = ?", true).Order("views_count desc").Limit(limit).Find(&articles)
+	return stats(articles)
+}
+
+// LeastHelpful lists published articles with at least one "not helpful" vote, worst share first
+func LeastHelpful(limit int) []Stat {
+	var articles []models.KnowledgeArticle
+	database.DB.Where("is_verified = ? AND not_helpful_count > 0", true).
+		Order("helpful_count::float / (helpful_count + not_helpful_count) asc, not_helpful_count desc").
+		Limit(limit).Find(&articles)
+	return stats(articles)
+}
+
+// Stale lists published articles not edited within StaleAfter, oldest first
+func Stale(now time.Time, limit int) []Stat {
+	var articles []models.KnowledgeArticle
+	database.DB.Where("is_verified = ? AND COALESCE(updated_at, created_at) < ?", true, now.Add(-StaleAfter())).
+		Order("COALESCE(updated_at, created_at) asc").Limit(limit).Find(&articles)
+	return stats(articles)
+}
+
+func stats(articles []models.KnowledgeArticle) []Stat {
+	result := make([]Stat, 0, len(articles))
+	for _, a := range articles {
+		st := Stat{KnowledgeArticle: a}
+		if votes := a.HelpfulCount + a.NotHelpfulCount; votes > 0 {
+			st.HelpfulRate = float64(a.HelpfulCount) / float64(votes) * 100
+		}
+		if a.NotHelpfulCount > 0 {
+			var vote models.ArticleVote
+			if err := database.DB.Where("article_id = ? AND helpful = ? AND comment <> ''", a.ID, false).
+				Order("updated_at desc").First(&vote).Error; err == nil {
+				st.LastFeedback = vote.Comment
+			}
+		}
+		result = append(result, st)
+	}
+	return result
+}
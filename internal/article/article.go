@@ -0,0 +1,172 @@
+package article
+
+import (
+	"errors"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"it-broadcast-ops/internal/upload"
+	"it-broadcast-ops/internal/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrArticleNotFound = errors.New("Artikel tidak ditemukan")
+	ErrFlagNote        = errors.New("Catatan revisi wajib diisi")
+)
+
+// maxCommentLength caps feedback left with a "not helpful" vote
+const maxCommentLength = 1000
+
+// RecordView counts userID's visit to the article, at most once per user per day.
+// Days are Jakarta days whatever the zone of now. It reports whether the view was counted.
+func RecordView(articleID, userID uuid.UUID, now time.Time) (bool, error) {
+	y, m, d := now.In(utils.Jakarta()).Date()
+	counted := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ArticleView{
+			ArticleID: articleID,
+			UserID:    userID,
+			ViewedOn:  time.Date(y, m, d, 0, 0, 0, 0, time.UTC),
+		})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		counted = true
+		return tx.Model(&models.KnowledgeArticle{}).Where("id = ?", articleID).
+			UpdateColumn("views_count", gorm.Expr("views_count + 1")).Error
+	})
+	return counted, err
+}
+
+// Vote stores userID's "was this helpful" answer. A user has one vote per article;
+// voting again replaces it and moves the counters accordingly. The comment is only
+// kept for "not helpful" votes.
+func Vote(articleID, userID uuid.UUID, helpful bool, comment string) (*models.ArticleVote, error) {
+	comment = strings.TrimSpace(comment)
+	if helpful {
+		comment = ""
+	}
+	if r := []rune(comment); len(r) > maxCommentLength {
+		comment = string(r[:maxCommentLength])
+	}
+
+	var vote models.ArticleVote
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var article models.KnowledgeArticle
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			First(&article, "id = ? AND is_verified = ?", articleID, true).Error; err != nil {
+			return ErrArticleNotFound
+		}
+
+		err := tx.Where("article_id = ? AND user_id = ?", articleID, userID).First(&vote).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			vote = models.ArticleVote{ArticleID: articleID, UserID: userID, Helpful: helpful, Comment: comment}
+			if err := tx.Create(&vote).Error; err != nil {
+				return err
+			}
+			return adjustCounts(tx, articleID, helpful, 1)
+		case err != nil:
+			return err
+		}
+
+		changed := vote.Helpful != helpful
+		if err := tx.Model(&vote).Updates(map[string]interface{}{"helpful": helpful, "comment": comment}).Error; err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		if err := adjustCounts(tx, articleID, !helpful, -1); err != nil {
+			return err
+		}
+		return adjustCounts(tx, articleID, helpful, 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &vote, nil
+}
+
+func adjustCounts(tx *gorm.DB, articleID uuid.UUID, helpful bool, delta int) error {
+	column := "not_helpful_count"
+	if helpful {
+		column = "helpful_count"
+	}
+	return tx.Model(&models.KnowledgeArticle{}).Where("id = ?", articleID).
+		UpdateColumn(column, gorm.Expr(column+" + ?", delta)).Error
+}
+
+// VoteOf returns userID's vote on the article, nil if they have not voted
+func VoteOf(articleID, userID uuid.UUID) *models.ArticleVote {
+	var vote models.ArticleVote
+	if err := database.DB.Where("article_id = ? AND user_id = ?", articleID, userID).First(&vote).Error; err != nil {
+		return nil
+	}
+	return &vote
+}
+
+// FlagForRevision marks the article as needing rework and tells its author why
+func FlagForRevision(articleID uuid.UUID, note string) error {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return ErrFlagNote
+	}
+
+	var article models.KnowledgeArticle
+	if err := database.DB.First(&article, "id = ?", articleID).Error; err != nil {
+		return ErrArticleNotFound
+	}
+	now := time.Now()
+	if err := database.DB.Model(&article).UpdateColumns(map[string]interface{}{
+		"needs_revision": true,
+		"revision_note":  note,
+		"flagged_at":     now,
+	}).Error; err != nil {
+		return err
+	}
+
+	go notification.SendNotificationToUser(
+		article.AuthorID.String(),
+		"📝 Artikel perlu direvisi",
+		fmt.Sprintf("%s: %s", article.Title, note),
+		"/staff/articles/"+article.ID.String(),
+	)
+	return nil
+}
+
+// ClearRevisionFlag removes the revision flag, e.g. after the article was edited
+func ClearRevisionFlag(tx *gorm.DB, articleID uuid.UUID) error {
+	return tx.Model(&models.KnowledgeArticle{}).Where("id = ?", articleID).UpdateColumns(map[string]interface{}{
+		"needs_revision": false,
+		"revision_note":  "",
+		"flagged_at":     nil,
+	}).Error
+}
+
+// ErrorStatus maps service errors to HTTP status codes
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrArticleNotFound), errors.Is(err, ErrRevisionNotFound), errors.Is(err, ErrAttachmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrFlagNote), errors.Is(err, ErrArticleFields), errors.Is(err, ErrReviewNote),
+		errors.Is(err, ErrTicketNotDraftable):
+		return http.StatusBadRequest
+	case errors.Is(err, upload.ErrTooLarge), errors.Is(err, upload.ErrType), errors.Is(err, upload.ErrMissing):
+		return upload.ErrorStatus(err)
+	case errors.Is(err, ErrNotAuthor):
+		return http.StatusForbidden
+	case errors.Is(err, ErrInvalidTransition):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,76 @@
+package article
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func seedArticle(t *testing.T) (*gorm.DB, models.KnowledgeArticle, models.User) {
+	db := testutil.SetupTestDB()
+	user := models.User{Email: "reader@example.com", FullName: "Reader", Role: models.RoleConsumer, IsActive: true}
+	db.Create(&user)
+	article := models.KnowledgeArticle{Title: "Reset prompter", Content: "Tekan tombol reset.", Category: "SOFTWARE",
+		AuthorID: user.ID, IsVerified: true, Status: models.ArticlePublished}
+	db.Create(&article)
+	return db, article, user
+}
+
+func TestRecordView_OncePerUserPerDay(t *testing.T) {
+	db, article, user := seedArticle(t)
+	now := time.Now()
+
+	counted, err := RecordView(article.ID, user.ID, now)
+	assert.NoError(t, err)
+	assert.True(t, counted)
+
+	counted, err = RecordView(article.ID, user.ID, now)
+	assert.NoError(t, err)
+	assert.False(t, counted, "same reader, same day")
+
+	counted, _ = RecordView(article.ID, user.ID, now.Add(24*time.Hour))
+	assert.True(t, counted, "next day counts again")
+
+	db.First(&article, "id = ?", article.ID)
+	assert.Equal(t, 2, article.ViewsCount)
+
+	// Days follow Jakarta, not UTC: 20:00 UTC is already 03:00 the next day in Jakarta
+	other := models.User{Email: "other@example.com", FullName: "Other", Role: models.RoleConsumer, IsActive: true}
+	db.Create(&other)
+	evening := time.Date(2025, 1, 1, 20, 0, 0, 0, time.UTC)
+	counted, _ = RecordView(article.ID, other.ID, evening)
+	assert.True(t, counted)
+	counted, _ = RecordView(article.ID, other.ID, time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC))
+	assert.False(t, counted, "same Jakarta day")
+	counted, _ = RecordView(article.ID, other.ID, time.Date(2025, 1, 1, 16, 0, 0, 0, time.UTC))
+	assert.True(t, counted, "23:00 in Jakarta, the day before")
+}
+
+func TestVote_OneVotePerUser(t *testing.T) {
+	db, article, user := seedArticle(t)
+
+	_, err := Vote(article.ID, user.ID, true, "ignored for helpful votes")
+	assert.NoError(t, err)
+	_, err = Vote(article.ID, user.ID, true, "")
+	assert.NoError(t, err)
+
+	db.First(&article, "id = ?", article.ID)
+	assert.Equal(t, 1, article.HelpfulCount, "voting twice does not count twice")
+
+	// Changing the vote moves the counters
+	vote, err := Vote(article.ID, user.ID, false, "Tombol reset tidak ada di model baru")
+	assert.NoError(t, err)
+	assert.Equal(t, "Tombol reset tidak ada di model baru", vote.Comment)
+
+	db.First(&article, "id = ?", article.ID)
+	assert.Equal(t, 0, article.HelpfulCount)
+	assert.Equal(t, 1, article.NotHelpfulCount)
+
+	var votes int64
+	db.Model(&models.ArticleVote{}).Where("article_id = ?", article.ID).Count(&votes)
+	assert.Equal(t, int64(1), votes)
+}
@@ -0,0 +1,219 @@
+package article
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Registered for thumbnails
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/upload"
+	"log"
+	"mime/multipart"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+var ErrAttachmentNotFound = errors.New("Lampiran tidak ditemukan")
+
+const (
+	thumbnailWidth = 320
+	maxImagePixels = 40_000_000 // Refuse decompression bombs before decoding
+)
+
+// Snippet is the Markdown that references the attachment from the article body:
+// an inline image for pictures, a link for documents
+func Snippet(a models.ArticleAttachment) string {
+	link := fmt.Sprintf("[%s](attachment:%s)", strings.NewReplacer("[", "", "]", "").Replace(a.FileName), a.ID)
+	if strings.HasPrefix(a.ContentType, "image/") {
+		return "!" + link
+	}
+	return link
+}
+
+// AddAttachment stores an uploaded file for the article. The type is sniffed from the
+// content, the client's file name and extension are only kept for display.
+func AddAttachment(articleID, uploaderID uuid.UUID, fileName string, r io.Reader) (*models.ArticleAttachment, error) {
+	file, err := upload.Read(r, upload.ArticleFilePolicy)
+	if err != nil {
+		return nil, err
+	}
+	return addAttachment(articleID, uploaderID, fileName, file)
+}
+
+// AddFormAttachment is AddAttachment for a multipart form file
+func AddFormAttachment(articleID, uploaderID uuid.UUID, header *multipart.FileHeader) (*models.ArticleAttachment, error) {
+	file, err := upload.ReadForm(header, upload.ArticleFilePolicy)
+	if err != nil {
+		return nil, err
+	}
+	return addAttachment(articleID, uploaderID, header.Filename, file)
+}
+
+func addAttachment(articleID, uploaderID uuid.UUID, fileName string, file *upload.File) (*models.ArticleAttachment, error) {
+	var article models.KnowledgeArticle
+	if err := database.DB.Select("id").First(&article, "id = ?", articleID).Error; err != nil {
+		return nil, ErrArticleNotFound
+	}
+
+	attachment := models.ArticleAttachment{
+		ID:          uuid.New(),
+		ArticleID:   articleID,
+		FileName:    filepath.Base(fileName),
+		ContentType: file.ContentType,
+		Size:        int64(len(file.Data)),
+		UploaderID:  uploaderID,
+		CreatedAt:   time.Now(),
+	}
+
+	var thumbnail []byte
+	if strings.HasPrefix(file.ContentType, "image/") {
+		var err error
+		if thumbnail, err = makeThumbnail(file.Data); err != nil {
+			return nil, fmt.Errorf("%w: %v", upload.ErrType, err)
+		}
+	}
+
+	// Files of an article share a prefix: articles/<article id>/<random>.<ext>
+	key, err := upload.Store("articles/"+articleID.String(), file)
+	if err != nil {
+		return nil, err
+	}
+	attachment.URL = upload.URL(key)
+	if thumbnail != nil {
+		thumbKey := strings.TrimSuffix(key, file.Ext) + "_thumb.jpg"
+		if err := upload.Put(thumbKey, thumbnail, "image/jpeg"); err != nil {
+			removeAttachmentFiles(attachment)
+			return nil, err
+		}
+		attachment.ThumbnailURL = upload.URL(thumbKey)
+	}
+
+	if err := database.DB.Create(&attachment).Error; err != nil {
+		removeAttachmentFiles(attachment)
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// makeThumbnail scales an image down to thumbnailWidth and encodes it as JPEG
+func makeThumbnail(data []byte) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w > thumbnailWidth {
+		h = h * thumbnailWidth / w
+		w = thumbnailWidth
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	// JPEG has no alpha: flatten transparent images onto white
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Attachments lists an article's attachments, oldest first
+func Attachments(articleID uuid.UUID) []models.ArticleAttachment {
+	var attachments []models.ArticleAttachment
+	database.DB.Where("article_id = ?", articleID).Order("created_at asc").Find(&attachments)
+	return attachments
+}
+
+// DeleteAttachment removes an attachment of the article and its files
+func DeleteAttachment(articleID, attachmentID uuid.UUID) error {
+	var attachment models.ArticleAttachment
+	if err := database.DB.First(&attachment, "id = ? AND article_id = ?", attachmentID, articleID).Error; err != nil {
+		return ErrAttachmentNotFound
+	}
+	if err := database.DB.Delete(&attachment).Error; err != nil {
+		return err
+	}
+	removeAttachmentFiles(attachment)
+	forgetRendered(articleID)
+	return nil
+}
+
+func removeAttachmentFiles(a models.ArticleAttachment) {
+	upload.Remove(a.URL)
+	upload.Remove(a.ThumbnailURL)
+}
+
+// attachmentRef matches inline references such as ![diagram](attachment:<uuid>)
+var attachmentRef = regexp.MustCompile(`\(attachment:([0-9a-fA-F-]{36})\)`)
+
+// resolveAttachments replaces attachment: references with the files' URLs.
+// Unknown references are left alone; the sanitizer drops the unknown scheme.
+func resolveAttachments(content string, attachments []models.ArticleAttachment) string {
+	if len(attachments) == 0 {
+		return content
+	}
+	urls := make(map[string]string, len(attachments))
+	for _, a := range attachments {
+		urls[a.ID.String()] = a.URL
+	}
+	return attachmentRef.ReplaceAllStringFunc(content, func(ref string) string {
+		id := strings.ToLower(attachmentRef.FindStringSubmatch(ref)[1])
+		if url, ok := urls[id]; ok {
+			return "(" + url + ")"
+		}
+		return ref
+	})
+}
+
+// Purge is the manager's "delete permanently" of an archived article. The article and its
+// attachments are deleted, its revisions are kept as history. Attachment files are only
+// removed from storage once the deletion has been committed.
+func Purge(articleID uuid.UUID) error {
+	var attachments []models.ArticleAttachment
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("article_id = ?", articleID).Find(&attachments).Error; err != nil {
+			return err
+		}
+		// Attachments, votes and views go with the article (ON DELETE CASCADE)
+		res := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", articleID).Delete(&models.KnowledgeArticle{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrArticleNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		removeAttachmentFiles(a)
+	}
+	log.Printf("[Article] 🗑️  Purged archived article %s", articleID)
+	return nil
+}
@@ -0,0 +1,89 @@
+package article
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/upload"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestMakeThumbnail_ScalesDown(t *testing.T) {
+	thumb, err := makeThumbnail(pngBytes(t, 1280, 720))
+	assert.NoError(t, err)
+
+	img, err := jpeg.Decode(bytes.NewReader(thumb))
+	assert.NoError(t, err)
+	assert.Equal(t, thumbnailWidth, img.Bounds().Dx())
+	assert.Equal(t, 180, img.Bounds().Dy())
+}
+
+func TestResolveAttachments(t *testing.T) {
+	a := models.ArticleAttachment{ID: uuid.New(), FileName: "wiring [v2].png", ContentType: "image/png",
+		URL: "/files/articles/x/y.png"}
+	doc := models.ArticleAttachment{ID: uuid.New(), FileName: "manual.pdf", ContentType: "application/pdf"}
+
+	assert.Equal(t, "![wiring v2.png](attachment:"+a.ID.String()+")", Snippet(a))
+	assert.Equal(t, "[manual.pdf](attachment:"+doc.ID.String()+")", Snippet(doc))
+
+	unknown := uuid.New().String()
+	out := resolveAttachments(Snippet(a)+" "+"![x](attachment:"+unknown+")", []models.ArticleAttachment{a})
+	assert.Equal(t, "![wiring v2.png](/files/articles/x/y.png) ![x](attachment:"+unknown+")", out)
+}
+
+func TestAddAttachment_SniffsAndPurges(t *testing.T) {
+	db, article, user := seedArticle(t)
+	root := t.TempDir()
+	upload.SetStorage(upload.NewLocalStorage(root))
+	defer upload.SetStorage(upload.NewLocalStorage("data/uploads"))
+
+	// The extension lies: content decides
+	_, err := AddAttachment(article.ID, user.ID, "diagram.png", strings.NewReader("#!/bin/sh\nrm -rf /"))
+	assert.ErrorIs(t, err, upload.ErrType)
+
+	attachment, err := AddAttachment(article.ID, user.ID, "diagram.txt", bytes.NewReader(pngBytes(t, 64, 64)))
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", attachment.ContentType)
+	assert.True(t, strings.HasPrefix(attachment.URL, "/files/articles/"+article.ID.String()+"/"))
+	assert.True(t, strings.HasSuffix(attachment.URL, ".png"))
+	assert.NotEmpty(t, attachment.ThumbnailURL)
+	assert.Len(t, Attachments(article.ID), 1)
+
+	dir := filepath.Join(root, "articles", article.ID.String())
+	files, _ := os.ReadDir(dir)
+	assert.Len(t, files, 2, "original and thumbnail")
+
+	// Only archived articles can be purged, and archiving keeps the files
+	assert.ErrorIs(t, Purge(article.ID), ErrArticleNotFound)
+	db.Create(&models.ArticleRevision{ArticleID: article.ID, Number: 1, Title: article.Title, EditorID: user.ID})
+	assert.NoError(t, Archive(article.ID))
+	files, _ = os.ReadDir(dir)
+	assert.Len(t, files, 2, "archived articles keep their files")
+
+	assert.NoError(t, Purge(article.ID))
+	files, _ = os.ReadDir(dir)
+	assert.Empty(t, files)
+	var revisions int64
+	db.Model(&models.ArticleRevision{}).Where("article_id = ?", article.ID).Count(&revisions)
+	assert.Equal(t, int64(1), revisions, "revisions are kept as history")
+	var count int64
+	db.Model(&models.ArticleAttachment{}).Where("article_id = ?", article.ID).Count(&count)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,72 @@
+package article
+
+import "strings"
+
+// Diff line kinds
+const (
+	LineSame    = " "
+	LineAdded   = "+"
+	LineRemoved = "-"
+)
+
+// DiffLine is one line of a line diff between two texts
+type DiffLine struct {
+	Kind string // LineSame, LineAdded or LineRemoved
+	Text string
+}
+
+// Diff returns the line diff turning from into to, based on their longest common subsequence.
+// Articles are short procedures, so the quadratic table is fine.
+func Diff(from, to string) []DiffLine {
+	a := splitLines(from)
+	b := splitLines(to)
+
+	// lcs[i][j] = length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, DiffLine{LineSame, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, DiffLine{LineRemoved, a[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{LineAdded, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, DiffLine{LineRemoved, a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, DiffLine{LineAdded, b[j]})
+	}
+	return lines
+}
+
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
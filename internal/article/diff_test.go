@@ -0,0 +1,27 @@
+package article
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff_Lines(t *testing.T) {
+	from := "Matikan mixer\nTunggu 10 detik\nNyalakan mixer"
+	to := "Matikan mixer\r\nCabut kabel power\nTunggu 10 detik\nNyalakan mixer\nCek level"
+
+	assert.Equal(t, []DiffLine{
+		{LineSame, "Matikan mixer"},
+		{LineAdded, "Cabut kabel power"},
+		{LineSame, "Tunggu 10 detik"},
+		{LineSame, "Nyalakan mixer"},
+		{LineAdded, "Cek level"},
+	}, Diff(from, to))
+
+	assert.Equal(t, []DiffLine{
+		{LineRemoved, "lama"},
+		{LineAdded, "baru"},
+	}, Diff("lama", "baru"))
+
+	assert.Empty(t, Diff("", ""))
+}
@@ -0,0 +1,150 @@
+package article
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"it-broadcast-ops/internal/models"
+	redisClient "it-broadcast-ops/internal/redis"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+// renderVersion is part of the cache key; bump it when the pipeline output changes
+const renderVersion = 1
+
+// renderTTL only bounds memory use: a revision never changes, so neither does its HTML
+const renderTTL = 7 * 24 * time.Hour
+
+// tocMaxLevel is the deepest heading listed in the table of contents
+const tocMaxLevel = 3
+
+// Heading is one table of contents entry, ID is the anchor of the rendered heading
+type Heading struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// Rendered is article content ready for a template
+type Rendered struct {
+	HTML template.HTML
+	TOC  []Heading
+}
+
+// GitHub flavoured Markdown: tables, task lists, strikethrough and autolinks.
+// Raw HTML is let through here and cleaned by the sanitizer below.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+var sanitizer = newSanitizer()
+
+func newSanitizer() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Fenced code blocks keep their language for highlighting
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	// Task list items render as read-only checkboxes
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^(|checked|disabled)$`)).OnElements("input")
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// RenderMarkdown converts Markdown to sanitized HTML and collects its headings
+func RenderMarkdown(source string) (Rendered, error) {
+	src := []byte(source)
+	doc := markdown.Parser().Parse(text.NewReader(src))
+
+	var toc []Heading
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if heading.Level <= tocMaxLevel {
+			id, _ := heading.AttributeString("id")
+			idBytes, _ := id.([]byte)
+			toc = append(toc, Heading{Level: heading.Level, ID: string(idBytes), Text: plainText(heading, src)})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+
+	var buf bytes.Buffer
+	if err := markdown.Renderer().Render(&buf, src, doc); err != nil {
+		return Rendered{}, err
+	}
+	return Rendered{HTML: template.HTML(sanitizer.SanitizeBytes(buf.Bytes())), TOC: toc}, nil
+}
+
+// plainText is the text of an inline node tree, markup dropped
+func plainText(n ast.Node, src []byte) string {
+	var sb strings.Builder
+	ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Text:
+			sb.Write(t.Segment.Value(src))
+			if t.SoftLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(sb.String())
+}
+
+func renderCacheKey(articleID uuid.UUID, revision int) string {
+	return fmt.Sprintf("cache:article:html:v%d:%s:%d", renderVersion, articleID, revision)
+}
+
+// forgetRendered drops the cached HTML of every revision, e.g. when an inline attachment is deleted
+func forgetRendered(articleID uuid.UUID) {
+	if redisClient.IsConnected() {
+		redisClient.DeletePattern(fmt.Sprintf("cache:article:html:v%d:%s:*", renderVersion, articleID))
+	}
+}
+
+// Render returns the article's content as HTML. The result is cached per revision,
+// so an edit never serves stale HTML.
+func Render(article *models.KnowledgeArticle) Rendered {
+	key := renderCacheKey(article.ID, article.Revision)
+	var rendered Rendered
+	if redisClient.IsConnected() {
+		if err := redisClient.Get(key, &rendered); err == nil {
+			return rendered
+		}
+	}
+
+	content := article.Content
+	if strings.Contains(content, "attachment:") {
+		content = resolveAttachments(content, Attachments(article.ID))
+	}
+	rendered, err := RenderMarkdown(content)
+	if err != nil {
+		// Never fall back to raw content: show it escaped instead
+		log.Printf("Rendering article %s failed: %v", article.ID, err)
+		return Rendered{HTML: template.HTML("<pre>" + template.HTMLEscapeString(article.Content) + "</pre>")}
+	}
+	if redisClient.IsConnected() {
+		redisClient.Set(key, rendered, renderTTL)
+	}
+	return rendered
+}
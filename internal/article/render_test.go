@@ -0,0 +1,38 @@
+package article
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	source := "# Reset prompter\n\n" +
+		"## Langkah\n\n" +
+		"- [x] Matikan prompter\n- [ ] Tekan **reset**\n\n" +
+		"```bash\nsystemctl restart prompter\n```\n\n" +
+		"## Referensi\n\n" +
+		"| Model | Tombol |\n|---|---|\n| PX-1 | Belakang |\n\n" +
+		"<script>alert(1)</script><a href=\"javascript:alert(1)\" onclick=\"x()\">klik</a>\n"
+
+	rendered, err := RenderMarkdown(source)
+	assert.NoError(t, err)
+	html := string(rendered.HTML)
+
+	assert.Contains(t, html, `<table>`)
+	assert.Contains(t, html, `<code class="language-bash">`)
+	assert.Contains(t, html, `type="checkbox"`)
+	assert.Contains(t, html, `<strong>reset</strong>`)
+	assert.Contains(t, html, `id="langkah"`)
+
+	// Stored XSS is stripped
+	assert.NotContains(t, html, "<script")
+	assert.NotContains(t, html, "javascript:")
+	assert.NotContains(t, html, "onclick")
+
+	assert.Equal(t, []Heading{
+		{Level: 1, ID: "reset-prompter", Text: "Reset prompter"},
+		{Level: 2, ID: "langkah", Text: "Langkah"},
+		{Level: 2, ID: "referensi", Text: "Referensi"},
+	}, rendered.TOC)
+}
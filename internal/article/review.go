@@ -0,0 +1,237 @@
+package article
+
+import (
+	"errors"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTicketNotDraftable = errors.New("Hanya tiket yang sudah selesai dan punya solusi yang bisa dijadikan artikel")
+	ErrNotAuthor          = errors.New("Hanya penulis yang dapat mengubah draft ini")
+	ErrInvalidTransition  = errors.New("Status artikel tidak mengizinkan aksi ini")
+	ErrReviewNote         = errors.New("Feedback untuk penulis wajib diisi")
+	ErrAlreadyDrafted     = errors.New("Tiket ini sudah dijadikan artikel")
+)
+
+// DraftFromTicket starts a staff draft prefilled from a finished ticket. A ticket gets one
+// article: its author asking again gets that draft back, anyone else ErrAlreadyDrafted.
+func DraftFromTicket(ticketID, authorID uuid.UUID) (*models.KnowledgeArticle, error) {
+	var ticket models.Ticket
+	if err := database.DB.First(&ticket, "id = ?", ticketID).Error; err != nil {
+		return nil, ErrTicketNotDraftable
+	}
+	if (ticket.Status != models.StatusResolved && ticket.Status != models.StatusClosed) ||
+		strings.TrimSpace(ticket.Solution) == "" {
+		return nil, ErrTicketNotDraftable
+	}
+
+	if existing, err := draftOf(ticketID, authorID); existing != nil || err != nil {
+		return existing, err
+	}
+
+	content := strings.TrimSpace(ticket.Solution)
+	if description := strings.TrimSpace(ticket.Description); description != "" {
+		content = fmt.Sprintf("Masalah:\n%s\n\nSolusi:\n%s", description, content)
+	}
+	article := models.KnowledgeArticle{
+		AuthorID:       authorID,
+		Status:         models.ArticleDraft,
+		SourceTicketID: &ticket.ID,
+	}
+	fields := Fields{Title: ticket.Subject, Category: ticket.Category, Content: content}
+	if err := Create(&article, fields, authorID, fmt.Sprintf("Draft dari tiket #%d", ticket.TicketNumber)); err != nil {
+		// Lost the race against a concurrent click, the unique index kept the other draft
+		if existing, lookupErr := draftOf(ticketID, authorID); existing != nil || lookupErr != nil {
+			return existing, lookupErr
+		}
+		return nil, err
+	}
+	// The ticket now has its article, drop it from the manager's candidates
+	database.DB.Model(&ticket).Update("is_converted_to_article", true)
+	return &article, nil
+}
+
+// draftOf returns the article already written from the ticket if authorID wrote it,
+// ErrAlreadyDrafted naming its author otherwise, and nothing when there is none
+func draftOf(ticketID, authorID uuid.UUID) (*models.KnowledgeArticle, error) {
+	var existing models.KnowledgeArticle
+	if err := database.DB.Preload("Author").Where("source_ticket_id = ?", ticketID).First(&existing).Error; err != nil {
+		return nil, nil
+	}
+	if existing.AuthorID != authorID {
+		return nil, fmt.Errorf("%w oleh %s", ErrAlreadyDrafted, existing.Author.FullName)
+	}
+	return &existing, nil
+}
+
+// editable reports whether the author can still change the draft
+func editable(article *models.KnowledgeArticle) bool {
+	return article.Status == models.ArticleDraft || article.Status == models.ArticleChangesRequested
+}
+
+// CheckDraftAuthor reports whether userID may still change the draft, e.g. attach files to it
+func CheckDraftAuthor(articleID, userID uuid.UUID) error {
+	var article models.KnowledgeArticle
+	if err := database.DB.Select("id", "author_id", "status").First(&article, "id = ?", articleID).Error; err != nil {
+		return ErrArticleNotFound
+	}
+	if article.AuthorID != userID {
+		return ErrNotAuthor
+	}
+	if !editable(&article) {
+		return ErrInvalidTransition
+	}
+	return nil
+}
+
+// SaveDraft stores the author's edit of a draft as a new revision
+func SaveDraft(articleID, authorID uuid.UUID, fields Fields, note string) (*models.KnowledgeArticle, error) {
+	fields, err := fields.clean()
+	if err != nil {
+		return nil, err
+	}
+
+	var article models.KnowledgeArticle
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockArticle(tx, articleID, &article); err != nil {
+			return err
+		}
+		if article.AuthorID != authorID {
+			return ErrNotAuthor
+		}
+		if !editable(&article) {
+			return ErrInvalidTransition
+		}
+		if fields == (Fields{Title: article.Title, Category: article.Category, Content: article.Content}) {
+			return nil // Nothing changed, e.g. submitting right after saving
+		}
+		return revise(tx, &article, fields, authorID, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+// Submit sends the author's draft to the managers for review
+func Submit(articleID, authorID uuid.UUID) (*models.KnowledgeArticle, error) {
+	var article models.KnowledgeArticle
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockArticle(tx, articleID, &article); err != nil {
+			return err
+		}
+		if article.AuthorID != authorID {
+			return ErrNotAuthor
+		}
+		if !editable(&article) {
+			return ErrInvalidTransition
+		}
+		article.Status = models.ArticleInReview
+		return tx.Model(&article).Update("status", article.Status).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+// Approve publishes an article waiting for review
+func Approve(articleID, reviewerID uuid.UUID) (*models.KnowledgeArticle, error) {
+	article, err := decide(articleID, reviewerID, models.ArticlePublished, "")
+	if err != nil {
+		return nil, err
+	}
+	notifyAuthor(article, "✅ Artikel dipublikasikan", article.Title, "/staff/articles/"+article.ID.String())
+	return article, nil
+}
+
+// RequestChanges sends the article back to its author with feedback
+func RequestChanges(articleID, reviewerID uuid.UUID, note string) (*models.KnowledgeArticle, error) {
+	article, err := decide(articleID, reviewerID, models.ArticleChangesRequested, note)
+	if err != nil {
+		return nil, err
+	}
+	notifyAuthor(article, "✏️ Artikel perlu diperbaiki", fmt.Sprintf("%s: %s", article.Title, article.ReviewNote),
+		"/staff/articles/"+article.ID.String()+"/edit")
+	return article, nil
+}
+
+// Reject archives the article with feedback. It can still be restored from the Archive.
+func Reject(articleID, reviewerID uuid.UUID, note string) (*models.KnowledgeArticle, error) {
+	article, err := decide(articleID, reviewerID, models.ArticleArchived, note)
+	if err != nil {
+		return nil, err
+	}
+	notifyAuthor(article, "❌ Artikel ditolak", fmt.Sprintf("%s: %s", article.Title, article.ReviewNote), "/staff/bigbook")
+	return article, nil
+}
+
+// decide moves an article out of review. Feedback is mandatory unless it gets published.
+func decide(articleID, reviewerID uuid.UUID, status, note string) (*models.KnowledgeArticle, error) {
+	note = strings.TrimSpace(note)
+	if status != models.ArticlePublished && note == "" {
+		return nil, ErrReviewNote
+	}
+
+	var article models.KnowledgeArticle
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockArticle(tx, articleID, &article); err != nil {
+			return err
+		}
+		if article.Status != models.ArticleInReview {
+			return ErrInvalidTransition
+		}
+
+		now := time.Now()
+		article.Status = status
+		article.IsVerified = status == models.ArticlePublished
+		article.ReviewNote = note
+		article.ReviewerID = &reviewerID
+		article.ReviewedAt = &now
+		if err := tx.Model(&article).Select("status", "is_verified", "review_note", "reviewer_id", "reviewed_at").
+			Updates(&article).Error; err != nil {
+			return err
+		}
+		if status == models.ArticleArchived {
+			return tx.Delete(&article).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+func notifyAuthor(article *models.KnowledgeArticle, title, message, url string) {
+	go notification.SendNotificationToUser(article.AuthorID.String(), title, message, url)
+}
+
+// Drafts lists the author's unpublished articles, rejected ones included so the
+// feedback stays visible
+func Drafts(authorID uuid.UUID) []models.KnowledgeArticle {
+	var articles []models.KnowledgeArticle
+	database.DB.Unscoped().
+		Where("author_id = ? AND status IN ?", authorID, []string{
+			models.ArticleDraft, models.ArticleInReview, models.ArticleChangesRequested, models.ArticleArchived}).
+		Where("deleted_at IS NULL OR (status = ? AND NOT is_verified AND reviewed_at IS NOT NULL)", models.ArticleArchived).
+		Order("updated_at desc").
+		Limit(20).
+		Find(&articles)
+	return articles
+}
+
+// ReviewQueue lists articles waiting for a manager, oldest first
+func ReviewQueue() []models.KnowledgeArticle {
+	var articles []models.KnowledgeArticle
+	database.DB.Preload("Author").Where("status = ?", models.ArticleInReview).Order("updated_at asc").Find(&articles)
+	return articles
+}
@@ -0,0 +1,90 @@
+package article
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDraftFromTicket_ReviewWorkflow(t *testing.T) {
+	db := testutil.SetupTestDB()
+	staff := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	manager := models.User{Email: "manager@example.com", FullName: "Manager", Role: models.RoleManager, IsActive: true}
+	db.Create(&staff)
+	db.Create(&manager)
+
+	open := models.Ticket{Subject: "Mic mati", Location: models.LocationStudio1, RequesterID: staff.ID,
+		Status: models.StatusOpen}
+	db.Create(&open)
+	_, err := DraftFromTicket(open.ID, staff.ID)
+	assert.ErrorIs(t, err, ErrTicketNotDraftable)
+
+	resolvedAt := time.Now()
+	ticket := models.Ticket{Subject: "Mic mati", Description: "Mic 2 tidak bunyi", Solution: "Ganti baterai",
+		Category: "AUDIO", Location: models.LocationStudio1, RequesterID: staff.ID, Status: models.StatusResolved,
+		ResolvedAt: &resolvedAt}
+	db.Create(&ticket)
+
+	draft, err := DraftFromTicket(ticket.ID, staff.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ArticleDraft, draft.Status)
+	assert.False(t, draft.IsVerified)
+	assert.Contains(t, draft.Content, "Mic 2 tidak bunyi")
+	assert.Contains(t, draft.Content, "Ganti baterai")
+
+	again, _ := DraftFromTicket(ticket.ID, staff.ID)
+	assert.Equal(t, draft.ID, again.ID, "one draft per ticket")
+	other := models.User{Email: "other@example.com", FullName: "Other Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&other)
+	_, err = DraftFromTicket(ticket.ID, other.ID)
+	assert.ErrorIs(t, err, ErrAlreadyDrafted)
+	assert.Contains(t, err.Error(), "Staff", "the error names the author")
+	duplicate := models.KnowledgeArticle{Title: "Mic mati", Content: "Ganti baterai", AuthorID: other.ID, SourceTicketID: &ticket.ID}
+	assert.Error(t, db.Create(&duplicate).Error, "the index keeps concurrent drafts out")
+
+	// Only the author edits, and only drafts under review are decided
+	_, err = SaveDraft(draft.ID, manager.ID, Fields{Title: "x", Content: "y"}, "")
+	assert.ErrorIs(t, err, ErrNotAuthor)
+	_, err = Approve(draft.ID, manager.ID)
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+
+	_, err = Submit(draft.ID, staff.ID)
+	assert.NoError(t, err)
+	_, err = RequestChanges(draft.ID, manager.ID, " ")
+	assert.ErrorIs(t, err, ErrReviewNote)
+	back, err := RequestChanges(draft.ID, manager.ID, "Tambahkan langkah cek receiver")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ArticleChangesRequested, back.Status)
+
+	_, err = SaveDraft(draft.ID, staff.ID, Fields{Title: "Mic wireless mati", Category: "AUDIO",
+		Content: "Ganti baterai lalu cek receiver."}, "Sesuai feedback")
+	assert.NoError(t, err)
+	_, err = Submit(draft.ID, staff.ID)
+	assert.NoError(t, err)
+
+	published, err := Approve(draft.ID, manager.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ArticlePublished, published.Status)
+	assert.True(t, published.IsVerified)
+	assert.Empty(t, ReviewQueue())
+	assert.Empty(t, Drafts(staff.ID))
+}
+
+func TestReject_ArchivesWithFeedback(t *testing.T) {
+	db, article, user := seedArticle(t)
+	db.Model(&article).Updates(map[string]interface{}{"status": models.ArticleInReview, "is_verified": false})
+
+	_, err := Reject(article.ID, user.ID, "Sudah ada artikel serupa")
+	assert.NoError(t, err)
+
+	drafts := Drafts(user.ID)
+	assert.Len(t, drafts, 1, "rejected drafts stay visible to their author")
+	assert.Equal(t, "Sudah ada artikel serupa", drafts[0].ReviewNote)
+
+	assert.NoError(t, Restore(article.ID))
+	db.First(&article, "id = ?", article.ID)
+	assert.Equal(t, models.ArticleDraft, article.Status)
+}
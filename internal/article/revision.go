@@ -0,0 +1,204 @@
+package article
+
+import (
+	"errors"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRevisionNotFound = errors.New("Revisi tidak ditemukan")
+	ErrArticleFields    = errors.New("Judul dan isi artikel wajib diisi")
+)
+
+// Fields are the editable parts of an article, the ones every revision snapshots
+type Fields struct {
+	Title    string
+	Category string
+	Content  string
+}
+
+func (f Fields) clean() (Fields, error) {
+	f.Title = strings.TrimSpace(f.Title)
+	f.Category = strings.TrimSpace(f.Category)
+	f.Content = strings.TrimSpace(f.Content)
+	if f.Title == "" || f.Content == "" {
+		return f, ErrArticleFields
+	}
+	return f, nil
+}
+
+// Create stores a new article together with its first revision.
+// article carries everything but the fields (author, status...); it starts as a draft
+// unless a status is given.
+func Create(article *models.KnowledgeArticle, fields Fields, editorID uuid.UUID, note string) error {
+	fields, err := fields.clean()
+	if err != nil {
+		return err
+	}
+	article.Title, article.Category, article.Content = fields.Title, fields.Category, fields.Content
+	article.Revision = 1
+	if article.Status == "" {
+		article.Status = models.ArticleDraft
+	}
+	article.IsVerified = article.Status == models.ArticlePublished
+	if article.CreatedAt.IsZero() {
+		article.CreatedAt = time.Now()
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(article).Error; err != nil {
+			return err
+		}
+		return addRevision(tx, article, editorID, note)
+	})
+}
+
+// Update edits the article and records the result as a new revision.
+// Editing also clears a pending revision flag.
+func Update(articleID uuid.UUID, fields Fields, editorID uuid.UUID, note string) (*models.KnowledgeArticle, error) {
+	fields, err := fields.clean()
+	if err != nil {
+		return nil, err
+	}
+
+	var article models.KnowledgeArticle
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockArticle(tx, articleID, &article); err != nil {
+			return err
+		}
+		return revise(tx, &article, fields, editorID, note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+// Rollback restores the fields of an earlier revision. History is never rewritten:
+// the restored content becomes a new revision on top.
+func Rollback(articleID uuid.UUID, number int, editorID uuid.UUID) (*models.KnowledgeArticle, error) {
+	var article models.KnowledgeArticle
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockArticle(tx, articleID, &article); err != nil {
+			return err
+		}
+		var target models.ArticleRevision
+		if err := tx.Where("article_id = ? AND number = ?", articleID, number).First(&target).Error; err != nil {
+			return ErrRevisionNotFound
+		}
+		fields := Fields{Title: target.Title, Category: target.Category, Content: target.Content}
+		return revise(tx, &article, fields, editorID, fmt.Sprintf("Rollback ke revisi %d", number))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+func lockArticle(tx *gorm.DB, articleID uuid.UUID, article *models.KnowledgeArticle) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(article, "id = ?", articleID).Error; err != nil {
+		return ErrArticleNotFound
+	}
+	return nil
+}
+
+// revise writes fields to the locked article and snapshots them as the next revision
+func revise(tx *gorm.DB, article *models.KnowledgeArticle, fields Fields, editorID uuid.UUID, note string) error {
+	// Articles created outside Create (e.g. seeded) get their current state saved first
+	if article.Revision == 0 {
+		article.Revision = 1
+		if err := addRevision(tx, article, article.AuthorID, "Versi awal"); err != nil {
+			return err
+		}
+	}
+
+	article.Title, article.Category, article.Content = fields.Title, fields.Category, fields.Content
+	article.Revision++
+	article.NeedsRevision = false
+	article.RevisionNote = ""
+	article.FlaggedAt = nil
+	if err := tx.Model(article).Select("title", "category", "content", "revision",
+		"needs_revision", "revision_note", "flagged_at", "updated_at").Updates(article).Error; err != nil {
+		return err
+	}
+	return addRevision(tx, article, editorID, note)
+}
+
+func addRevision(tx *gorm.DB, article *models.KnowledgeArticle, editorID uuid.UUID, note string) error {
+	return tx.Create(&models.ArticleRevision{
+		ArticleID: article.ID,
+		Number:    article.Revision,
+		Title:     article.Title,
+		Category:  article.Category,
+		Content:   article.Content,
+		EditorID:  editorID,
+		Note:      strings.TrimSpace(note),
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// Revisions lists an article's revisions, newest first
+func Revisions(articleID uuid.UUID) []models.ArticleRevision {
+	var revisions []models.ArticleRevision
+	database.DB.Preload("Editor").Where("article_id = ?", articleID).Order("number desc").Find(&revisions)
+	return revisions
+}
+
+// Revision returns one revision of an article
+func Revision(articleID uuid.UUID, number int) (*models.ArticleRevision, error) {
+	var revision models.ArticleRevision
+	if err := database.DB.Preload("Editor").Where("article_id = ? AND number = ?", articleID, number).
+		First(&revision).Error; err != nil {
+		return nil, ErrRevisionNotFound
+	}
+	return &revision, nil
+}
+
+// Archive soft-deletes the article. It disappears from the Big Book but keeps its history.
+func Archive(articleID uuid.UUID) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.KnowledgeArticle{}).Where("id = ?", articleID).
+			UpdateColumn("status", models.ArticleArchived)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrArticleNotFound
+		}
+		return tx.Delete(&models.KnowledgeArticle{}, "id = ?", articleID).Error
+	})
+}
+
+// Restore brings an archived article back, published again if it was published
+// before and as a draft for its author otherwise
+func Restore(articleID uuid.UUID) error {
+	res := database.DB.Unscoped().Model(&models.KnowledgeArticle{}).
+		Where("id = ? AND deleted_at IS NOT NULL", articleID).
+		UpdateColumns(map[string]interface{}{
+			"deleted_at": nil,
+			"status": gorm.Expr("CASE WHEN is_verified THEN ? ELSE ? END",
+				models.ArticlePublished, models.ArticleDraft),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrArticleNotFound
+	}
+	return nil
+}
+
+// Archived lists archived articles, most recently archived first
+func Archived() []models.KnowledgeArticle {
+	var articles []models.KnowledgeArticle
+	database.DB.Unscoped().Preload("Author").Where("deleted_at IS NOT NULL").Order("deleted_at desc").Find(&articles)
+	return articles
+}
@@ -0,0 +1,48 @@
+package article
+
+import (
+	"it-broadcast-ops/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdate_KeepsRevisionsAndRollback(t *testing.T) {
+	db, article, user := seedArticle(t)
+
+	// Seeded articles have no revision yet; the first edit saves a baseline
+	updated, err := Update(article.ID, Fields{Title: "Reset prompter", Category: "SOFTWARE",
+		Content: "Tahan tombol reset 5 detik."}, user.ID, "Perjelas langkah")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated.Revision)
+
+	_, err = Update(article.ID, Fields{Title: "", Content: "x"}, user.ID, "")
+	assert.ErrorIs(t, err, ErrArticleFields)
+
+	revisions := Revisions(article.ID)
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, "Tekan tombol reset.", revisions[1].Content)
+
+	restored, err := Rollback(article.ID, 1, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, restored.Revision)
+	assert.Equal(t, "Rollback ke revisi 1", Revisions(article.ID)[0].Note)
+
+	db.First(&article, "id = ?", article.ID)
+	assert.Equal(t, "Tekan tombol reset.", article.Content)
+
+	_, err = Rollback(article.ID, 9, user.ID)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+}
+
+func TestArchive_Restore(t *testing.T) {
+	db, article, _ := seedArticle(t)
+
+	assert.NoError(t, Archive(article.ID))
+	assert.Error(t, db.First(&models.KnowledgeArticle{}, "id = ?", article.ID).Error)
+	assert.Len(t, Archived(), 1)
+
+	assert.NoError(t, Restore(article.ID))
+	assert.NoError(t, db.First(&models.KnowledgeArticle{}, "id = ?", article.ID).Error)
+	assert.ErrorIs(t, Restore(article.ID), ErrArticleNotFound, "not archived anymore")
+}
@@ -28,8 +28,20 @@ func RegisterRoutes(r *gin.Engine) {
 // @Success      200  {string}  string  "HTML page"
 // @Router       /auth/login [get]
 func ShowLoginForm(c *gin.Context) {
+	next := SafeNextPath(c.Query("next"))
+
+	// Already logged in: skip the form
+	if user, ok := CurrentUser(c); ok {
+		if next == "" {
+			next = HomePath(user)
+		}
+		c.Redirect(http.StatusFound, next)
+		return
+	}
+
 	c.HTML(http.StatusOK, "pages/auth/login.html", gin.H{
 		"title": "Login",
+		"next":  next,
 	})
 }
 
@@ -41,16 +53,19 @@ func ShowLoginForm(c *gin.Context) {
 // @Produce      html
 // @Param        email     formData  string  true  "Email or Username"
 // @Param        password  formData  string  true  "Password"
+// @Param        next      formData  string  false "Local path to return to after login"
 // @Success      302  {string}  string  "Redirect to dashboard"
 // @Failure      401  {string}  string  "Invalid credentials"
 // @Router       /auth/login [post]
 func Login(c *gin.Context) {
 	identifier := c.PostForm("email") // Can be email or username
 	password := c.PostForm("password")
+	next := SafeNextPath(c.PostForm("next"))
 
 	if identifier == "" || password == "" {
 		c.HTML(http.StatusUnauthorized, "pages/auth/login.html", gin.H{
 			"title": "Login",
+			"next":  next,
 			"error": "Email/Username dan password harus diisi",
 		})
 		return
@@ -85,6 +100,7 @@ func Login(c *gin.Context) {
 			// Show error to user
 			c.HTML(http.StatusUnauthorized, "pages/auth/login.html", gin.H{
 				"title": "Login",
+				"next":  next,
 				"error": "Invalid email/username atau password",
 			})
 			return
@@ -113,6 +129,7 @@ func Login(c *gin.Context) {
 				log.Printf("[AUTH] Failed to create user %s in database: %v", username, err)
 				c.HTML(http.StatusInternalServerError, "pages/auth/login.html", gin.H{
 					"title": "Login",
+					"next":  next,
 					"error": "Gagal menyimpan data user",
 				})
 				return
@@ -135,6 +152,7 @@ func Login(c *gin.Context) {
 	if !authenticated {
 		c.HTML(http.StatusUnauthorized, "pages/auth/login.html", gin.H{
 			"title": "Login",
+			"next":  next,
 			"error": "Invalid email/username atau password",
 		})
 		return
@@ -144,38 +162,41 @@ func Login(c *gin.Context) {
 	if !user.IsActive {
 		c.HTML(http.StatusUnauthorized, "pages/auth/login.html", gin.H{
 			"title": "Login",
+			"next":  next,
 			"error": "Akun tidak aktif. Hubungi Admin.",
 		})
 		return
 	}
 
-	// Set Cookie
-	c.SetCookie("user_id", user.ID.String(), 3600*8, "/", "", false, true)
-	c.SetCookie("user_role", string(user.Role), 3600*8, "/", "", false, true)
+	// Start server-side session (signed opaque cookie)
+	if err := StartSession(c, user); err != nil {
+		log.Printf("[AUTH] Failed to create session for %s: %v", user.Email, err)
+		c.HTML(http.StatusInternalServerError, "pages/auth/login.html", gin.H{
+			"title": "Login",
+			"next":  next,
+			"error": "Gagal membuat sesi login",
+		})
+		return
+	}
 
 	log.Printf("[AUTH] User %s logged in successfully (Role: %s)", user.Email, user.Role)
 
-	// Redirect based on role
-	switch user.Role {
-	case models.RoleManager:
-		c.Redirect(http.StatusFound, "/manager")
-	case models.RoleStaff:
-		c.Redirect(http.StatusFound, "/staff")
-	default:
-		c.Redirect(http.StatusFound, "/consumer")
+	// Redirect back to the page that required login, otherwise based on role
+	if next == "" {
+		next = HomePath(&user)
 	}
+	c.Redirect(http.StatusFound, next)
 }
 
 // Logout godoc
 // @Summary      User logout
-// @Description  Log out user and clear session cookies
+// @Description  Revoke the server-side session and clear the session cookie
 // @Tags         Auth
 // @Produce      html
 // @Success      302  {string}  string  "Redirect to login"
 // @Router       /auth/logout [get]
 func Logout(c *gin.Context) {
-	c.SetCookie("user_id", "", -1, "/", "", false, true)
-	c.SetCookie("user_role", "", -1, "/", "", false, true)
+	EndSession(c)
 	c.Redirect(http.StatusFound, "/auth/login")
 }
 
@@ -54,12 +54,12 @@ func TestLogin_Success(t *testing.T) {
 	cookie := w.Result().Cookies()
 	foundCookie := false
 	for _, c := range cookie {
-		if c.Name == "user_id" {
+		if c.Name == SessionCookieName {
 			foundCookie = true
 			break
 		}
 	}
-	assert.True(t, foundCookie, "Cookie user_id must be set")
+	assert.True(t, foundCookie, "Session cookie must be set")
 }
 
 func TestLogin_Failure(t *testing.T) {
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	redisClient "it-broadcast-ops/internal/redis"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SessionCookieName is the only cookie that identifies a logged-in user.
+// It carries an opaque random token plus an HMAC signature, never the user ID.
+const SessionCookieName = "g_session"
+
+// Context key used to cache the resolved user for the rest of the request
+const currentUserKey = "currentUser"
+
+var ErrNoSession = errors.New("session not found or expired")
+
+var (
+	secretOnce sync.Once
+	secret     []byte
+)
+
+// sessionSecret returns the HMAC key from SESSION_SECRET.
+// If unset, a random key is generated so the app still runs, but every restart logs everyone out.
+func sessionSecret() []byte {
+	secretOnce.Do(func() {
+		if s := os.Getenv("SESSION_SECRET"); s != "" {
+			secret = []byte(s)
+			return
+		}
+		log.Println("⚠️  SESSION_SECRET not set. Using a random key; sessions will not survive a restart.")
+		secret = make([]byte, 32)
+		rand.Read(secret)
+	})
+	return secret
+}
+
+// SessionTTL returns the session lifetime (SESSION_TTL, e.g. "8h"). Default 8 hours.
+func SessionTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SESSION_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 8 * time.Hour
+}
+
+type sessionRecord struct {
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	LastSeenAt time.Time
+	CreatedAt  time.Time
+}
+
+// === TOKEN HELPERS ===
+
+func sign(token string) string {
+	mac := hmac.New(sha256.New, sessionSecret())
+	mac.Write([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// storageKey hashes the token so a leaked Redis/DB row cannot be replayed as a cookie
+func storageKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseCookie verifies the signature and returns the storage key
+func parseCookie(value string) (string, bool) {
+	token, sig, ok := strings.Cut(value, ".")
+	if !ok || token == "" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(sig), []byte(sign(token))) {
+		return "", false
+	}
+	return storageKey(token), true
+}
+
+// === STORE ===
+// Redis is the primary store. When Redis is unavailable, sessions go to the `sessions` table.
+// Reads check Redis first and fall back to Postgres so sessions created during an outage keep working.
+
+func saveSession(key string, rec sessionRecord) error {
+	if redisClient.IsConnected() {
+		ttl := time.Until(rec.ExpiresAt)
+		if err := redisClient.Set("session:"+key, rec, ttl); err != nil {
+			return err
+		}
+		return redisClient.AddToSet("session:user:"+rec.UserID.String(), SessionTTL(), key)
+	}
+	return database.DB.Save(&models.Session{
+		ID:         key,
+		UserID:     rec.UserID,
+		ExpiresAt:  rec.ExpiresAt,
+		LastSeenAt: rec.LastSeenAt,
+		CreatedAt:  rec.CreatedAt,
+	}).Error
+}
+
+func loadSession(key string) (sessionRecord, error) {
+	var rec sessionRecord
+	if redisClient.IsConnected() {
+		if err := redisClient.Get("session:"+key, &rec); err == nil {
+			return rec, nil
+		}
+	}
+
+	var row models.Session
+	if err := database.DB.First(&row, "id = ? AND expires_at > ?", key, time.Now()).Error; err != nil {
+		return rec, ErrNoSession
+	}
+	return sessionRecord{
+		UserID:     row.UserID,
+		ExpiresAt:  row.ExpiresAt,
+		LastSeenAt: row.LastSeenAt,
+		CreatedAt:  row.CreatedAt,
+	}, nil
+}
+
+// deleteSession removes the session from both stores: it may have been saved to Postgres during
+// a Redis outage and to Redis after it, and either copy would keep the session alive
+func deleteSession(key string) {
+	if redisClient.Client != nil {
+		if err := redisClient.Delete("session:" + key); err != nil {
+			log.Printf("[Session] ⚠️  Removing session from Redis failed: %v", err)
+		}
+	}
+	if err := database.DB.Delete(&models.Session{}, "id = ?", key).Error; err != nil {
+		log.Printf("[Session] ⚠️  Removing session from Postgres failed: %v", err)
+	}
+}
+
+// PurgeExpiredSessions is the scheduler job dropping expired rows of the Postgres session store.
+// Redis expires its sessions by itself.
+func PurgeExpiredSessions(tx *gorm.DB, now time.Time) error {
+	return tx.Where("expires_at <= ?", now).Delete(&models.Session{}).Error
+}
+
+// RevokeUserSessions logs a user out everywhere (e.g. after deactivation or a role change)
+func RevokeUserSessions(userID uuid.UUID) error {
+	if redisClient.Client != nil {
+		indexKey := "session:user:" + userID.String()
+		if keys, err := redisClient.SetMembers(indexKey); err == nil {
+			for _, k := range keys {
+				redisClient.Delete("session:" + k)
+			}
+		}
+		redisClient.Delete(indexKey)
+	}
+	return database.DB.Delete(&models.Session{}, "user_id = ?", userID).Error
+}
+
+// === PUBLIC API ===
+
+// IssueSession creates a new session for the user and returns the signed cookie value
+func IssueSession(userID uuid.UUID) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	rec := sessionRecord{
+		UserID:     userID,
+		ExpiresAt:  now.Add(SessionTTL()),
+		LastSeenAt: now,
+		CreatedAt:  now,
+	}
+	if err := saveSession(storageKey(token), rec); err != nil {
+		return "", err
+	}
+	return token + "." + sign(token), nil
+}
+
+// StartSession issues a session for the user and sets the session cookie
+func StartSession(c *gin.Context, user models.User) error {
+	value, err := IssueSession(user.ID)
+	if err != nil {
+		return err
+	}
+	setSessionCookie(c, value, int(SessionTTL().Seconds()))
+	c.Set(currentUserKey, &user)
+	return nil
+}
+
+// EndSession revokes the current session server-side and clears the cookie
+func EndSession(c *gin.Context) {
+	if value, err := c.Cookie(SessionCookieName); err == nil {
+		if key, ok := parseCookie(value); ok {
+			deleteSession(key)
+		}
+	}
+	setSessionCookie(c, "", -1)
+}
+
+// CurrentUser returns the logged-in user for this request.
+// The user is resolved once per request (session -> DB) and cached on the context.
+func CurrentUser(c *gin.Context) (*models.User, bool) {
+	if v, exists := c.Get(currentUserKey); exists {
+		user, ok := v.(*models.User)
+		return user, ok && user != nil
+	}
+
+	user, err := resolveSession(c)
+	if err != nil {
+		c.Set(currentUserKey, (*models.User)(nil))
+		return nil, false
+	}
+	c.Set(currentUserKey, user)
+	return user, true
+}
+
+// CurrentUserID is a shorthand for handlers that only need the ID
+func CurrentUserID(c *gin.Context) (uuid.UUID, bool) {
+	user, ok := CurrentUser(c)
+	if !ok {
+		return uuid.Nil, false
+	}
+	return user.ID, true
+}
+
+// resolveSession validates the cookie, renews the session (sliding expiry) and loads the user
+func resolveSession(c *gin.Context) (*models.User, error) {
+	value, err := c.Cookie(SessionCookieName)
+	if err != nil || value == "" {
+		return nil, ErrNoSession
+	}
+	key, ok := parseCookie(value)
+	if !ok {
+		return nil, ErrNoSession
+	}
+
+	rec, err := loadSession(key)
+	if err != nil || time.Now().After(rec.ExpiresAt) {
+		return nil, ErrNoSession
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", rec.UserID).Error; err != nil || !user.IsActive {
+		deleteSession(key)
+		return nil, ErrNoSession
+	}
+
+	// Sliding renewal: extend once less than half of the lifetime is left
+	ttl := SessionTTL()
+	now := time.Now()
+	if rec.ExpiresAt.Sub(now) < ttl/2 {
+		rec.ExpiresAt = now.Add(ttl)
+		rec.LastSeenAt = now
+		if err := saveSession(key, rec); err == nil {
+			setSessionCookie(c, value, int(ttl.Seconds()))
+		}
+	}
+
+	return &user, nil
+}
+
+func setSessionCookie(c *gin.Context, value string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookieName, value, maxAge, "/", "", secure, true)
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCookie_Signature(t *testing.T) {
+	token := "opaque-token-value"
+	valid := token + "." + sign(token)
+
+	// 1. Valid signature -> storage key is the hash, not the token
+	key, ok := parseCookie(valid)
+	assert.True(t, ok)
+	assert.Equal(t, storageKey(token), key)
+	assert.NotContains(t, key, token)
+
+	// 2. Tampered token or signature must be rejected
+	cases := []string{
+		"",
+		token,
+		"." + sign(token),
+		"other-token." + sign(token),
+		token + "." + sign("other-token"),
+	}
+	for _, value := range cases {
+		_, ok := parseCookie(value)
+		assert.False(t, ok, "cookie %q must be rejected", value)
+	}
+}
+
+func TestSessions_LogoutAndPurge(t *testing.T) {
+	db := testutil.SetupTestDB()
+	user := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&user)
+
+	value, err := IssueSession(user.ID)
+	assert.NoError(t, err)
+	key, ok := parseCookie(value)
+	assert.True(t, ok)
+	_, err = loadSession(key)
+	assert.NoError(t, err)
+
+	// Logout removes the stored session
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/auth/logout", nil)
+	c.Request.AddCookie(&http.Cookie{Name: SessionCookieName, Value: value})
+	EndSession(c)
+	_, err = loadSession(key)
+	assert.ErrorIs(t, err, ErrNoSession)
+	var left int64
+	db.Model(&models.Session{}).Where("id = ?", key).Count(&left)
+	assert.Zero(t, left)
+
+	// Expired rows are purged, live ones kept
+	now := time.Now()
+	db.Create(&models.Session{ID: "expired", UserID: user.ID, ExpiresAt: now.Add(-time.Minute)})
+	db.Create(&models.Session{ID: "live", UserID: user.ID, ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(t, PurgeExpiredSessions(db, now))
+	var ids []string
+	db.Model(&models.Session{}).Order("id").Pluck("id", &ids)
+	assert.Equal(t, []string{"live"}, ids)
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+const afterCommitKey = "app:after_commit"
+
+type afterCommitList struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// WithAfterCommit returns tx carrying a list that AfterCommit appends to, and the function
+// running that list. The caller runs it once the transaction has committed.
+func WithAfterCommit(tx *gorm.DB) (*gorm.DB, func()) {
+	list := &afterCommitList{}
+	// A new session, so queries chained on the returned tx do not pile up conditions
+	return tx.Set(afterCommitKey, list).Session(&gorm.Session{}), func() {
+		list.mu.Lock()
+		fns := list.fns
+		list.fns = nil
+		list.mu.Unlock()
+		for _, fn := range fns {
+			fn()
+		}
+	}
+}
+
+// AfterCommit defers fn, e.g. a push, until the transaction of tx has committed. Without a list
+// from WithAfterCommit, tx is not a transaction the caller commits later and fn runs right away.
+func AfterCommit(tx *gorm.DB, fn func()) {
+	v, ok := tx.Get(afterCommitKey)
+	if !ok {
+		fn()
+		return
+	}
+	list := v.(*afterCommitList)
+	list.mu.Lock()
+	list.fns = append(list.fns, fn)
+	list.mu.Unlock()
+}
@@ -41,6 +41,23 @@ func AutoMigrate(db *gorm.DB) {
 		&models.Ticket{},
 		&models.RoutineInstance{}, 
 		&models.TicketActivity{},
+		&models.TicketAttachment{},
+		&models.Session{},
+		&models.TicketHandover{},
+		&models.TicketRating{},
+		&models.SLAPolicy{},
+		&models.SLABreach{},
+		&models.KnowledgeArticle{},
+		&models.SuggestionEvent{},
+		&models.ArticleVote{},
+		&models.ArticleView{},
+		&models.ArticleRevision{},
+		&models.ArticleAttachment{},
+		&models.OutboxEmail{},
+		&models.PushSubscription{},
+		&models.VapidKey{},
+		&models.PushDelivery{},
+		&models.SchedulerRun{},
 		// Add other models here if they change
 	)
 	if err != nil {
@@ -48,4 +65,117 @@ func AutoMigrate(db *gorm.DB) {
 	} else {
 		log.Println("GORM AutoMigrate check completed.")
 	}
+
+	seedSLAPolicies(db)
+	upgradeSearch(db)
+	backfillArticleRevisions(db)
+	backfillArticleStatus(db)
+	uniqueCurrentVapidKey(db)
+	uniqueArticleSourceTicket(db)
+}
+
+// uniqueCurrentVapidKey lets at most one stored VAPID key be current (not retiring)
+func uniqueCurrentVapidKey(db *gorm.DB) {
+	if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_vapid_keys_current ON vapid_keys ((retires_at IS NULL)) WHERE retires_at IS NULL").Error; err != nil {
+		log.Println("Creating current VAPID key index failed: ", err)
+	}
+}
+
+// uniqueArticleSourceTicket lets a ticket be the source of at most one article that is not archived
+func uniqueArticleSourceTicket(db *gorm.DB) {
+	if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_knowledge_articles_source_ticket ON knowledge_articles (source_ticket_id) WHERE deleted_at IS NULL").Error; err != nil {
+		log.Println("Creating article source ticket index failed: ", err)
+		return
+	}
+	// Replaces the plain index of earlier versions
+	db.Exec("DROP INDEX IF EXISTS idx_knowledge_articles_source_ticket_id")
+}
+
+// backfillArticleStatus derives the workflow state of articles written before it existed
+func backfillArticleStatus(db *gorm.DB) {
+	if err := db.Exec(`
+		UPDATE knowledge_articles SET status = CASE
+			WHEN deleted_at IS NOT NULL THEN 'ARCHIVED'
+			WHEN is_verified THEN 'PUBLISHED'
+			ELSE 'IN_REVIEW'
+		END
+		WHERE status IS NULL OR status = ''`).Error; err != nil {
+		log.Println("Backfilling article status failed: ", err)
+	}
+}
+
+// backfillArticleRevisions gives articles written before revisions existed their first revision
+func backfillArticleRevisions(db *gorm.DB) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+			INSERT INTO article_revisions (article_id, number, title, category, content, editor_id, note, created_at)
+			SELECT id, 1, title, category::text, content, author_id, 'Versi awal', COALESCE(updated_at, created_at, NOW())
+			FROM knowledge_articles
+			WHERE revision = 0`).Error; err != nil {
+			return err
+		}
+		return tx.Exec("UPDATE knowledge_articles SET revision = 1 WHERE revision = 0").Error
+	})
+	if err != nil {
+		log.Println("Backfilling article revisions failed: ", err)
+	}
+}
+
+// TicketSearchVector is the full-text document of a solved ticket (subject + solution) in
+// Indonesian and English. Queries must use this exact expression to hit idx_ticket_search.
+const TicketSearchVector = `(setweight(to_tsvector('indonesian', coalesce(subject, '')), 'A') ||
+	setweight(to_tsvector('english', coalesce(subject, '')), 'A') ||
+	setweight(to_tsvector('indonesian', coalesce(solution, '')), 'B') ||
+	setweight(to_tsvector('english', coalesce(solution, '')), 'B'))`
+
+// upgradeSearch makes knowledge_articles.search_vector bilingual (the original trigger only
+// indexed Indonesian) and adds the GIN index used to search ticket solutions.
+func upgradeSearch(db *gorm.DB) {
+	var bilingual bool
+	db.Raw("SELECT EXISTS (SELECT 1 FROM pg_proc WHERE proname = 'kb_search_update' AND prosrc LIKE '%english%')").
+		Scan(&bilingual)
+	if !bilingual {
+		log.Println("Upgrading Big Book search vector to Indonesian + English...")
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`
+				CREATE OR REPLACE FUNCTION kb_search_update() RETURNS trigger AS $$
+				BEGIN
+				  new.search_vector :=
+				    setweight(to_tsvector('indonesian', coalesce(new.title, '')), 'A') ||
+				    setweight(to_tsvector('english', coalesce(new.title, '')), 'A') ||
+				    setweight(to_tsvector('indonesian', coalesce(new.content, '')), 'B') ||
+				    setweight(to_tsvector('english', coalesce(new.content, '')), 'B');
+				  RETURN new;
+				END
+				$$ LANGUAGE plpgsql`).Error; err != nil {
+				return err
+			}
+			// Fire the trigger once for existing rows
+			return tx.Exec("UPDATE knowledge_articles SET title = title").Error
+		})
+		if err != nil {
+			log.Println("Search vector upgrade failed: ", err)
+		}
+	}
+
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_ticket_search ON tickets USING GIN (" + TicketSearchVector + ")").Error; err != nil {
+		log.Println("Creating ticket search index failed: ", err)
+	}
+}
+
+// seedSLAPolicies installs the targets that used to be hard-coded in the dashboard
+// (15 minutes for on-air issues, 8 hours otherwise) the first time the table is empty.
+func seedSLAPolicies(db *gorm.DB) {
+	var count int64
+	if err := db.Model(&models.SLAPolicy{}).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+	defaults := []models.SLAPolicy{
+		{Name: "On Air", Priority: string(models.PriorityUrgentOnAir), ResponseMinutes: 5, ResolutionMinutes: 15},
+		{Name: "High", Priority: string(models.PriorityHigh), ResponseMinutes: 30, ResolutionMinutes: 480},
+		{Name: "Normal", Priority: string(models.PriorityNormal), ResponseMinutes: 60, ResolutionMinutes: 480},
+	}
+	if err := db.Create(&defaults).Error; err != nil {
+		log.Println("Seeding SLA policies failed: ", err)
+	}
 }
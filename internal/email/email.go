@@ -0,0 +1,83 @@
+// Package email sends templated notification emails through a persistent outbox.
+// Messages are rendered and stored when queued; the scheduler delivers them over SMTP
+// and retries failures with backoff.
+package email
+
+import (
+	"errors"
+	"log"
+	netmail "net/mail"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message is a rendered email ready to send
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Sender delivers a message. SMTPSender is the real one, tests plug in their own.
+type Sender interface {
+	Send(msg Message) error
+}
+
+const (
+	defaultFrom    = "IT Broadcast Ops <noreply@localhost>"
+	defaultBaseURL = "http://localhost:8080"
+)
+
+var sender Sender
+
+// Init configures SMTP from SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM.
+// Without SMTP_HOST email is disabled and nothing is queued.
+func Init() error {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Println("[Email] ⚠️  SMTP_HOST not set, email notifications are disabled")
+		return nil
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = defaultFrom
+	}
+	addr, err := netmail.ParseAddress(from)
+	if err != nil {
+		return errors.New("invalid SMTP_FROM: " + err.Error())
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	sender = &SMTPSender{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     addr,
+		Timeout:  30 * time.Second,
+	}
+	log.Printf("[Email] ✅ Sending email through %s:%s as %s", host, port, addr.Address)
+	return nil
+}
+
+// SetSender replaces the sender, nil disables email
+func SetSender(s Sender) {
+	sender = s
+}
+
+// Enabled reports whether emails are queued at all
+func Enabled() bool {
+	return sender != nil
+}
+
+// BaseURL is the public address of the app used for links in emails (APP_BASE_URL)
+func BaseURL() string {
+	if v := os.Getenv("APP_BASE_URL"); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return defaultBaseURL
+}
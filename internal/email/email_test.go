@@ -0,0 +1,160 @@
+package email
+
+import (
+	"errors"
+	"io"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm/clause"
+)
+
+func TestRender_EscapesHTMLOnly(t *testing.T) {
+	msg, err := Render(StaffReply, TicketEmail{
+		RecipientName: "Budi",
+		TicketNumber:  42,
+		Subject:       "Mic <mati>",
+		ActorName:     "Andi",
+		Message:       "Ganti <b>baterai</b>",
+		Link:          "http://localhost:8080/consumer",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "[#42] Balasan baru: Mic <mati>", msg.Subject)
+	assert.Contains(t, msg.Text, "Ganti <b>baterai</b>")
+	assert.Contains(t, msg.HTML, "Ganti &lt;b&gt;baterai&lt;/b&gt;")
+	assert.Contains(t, msg.HTML, `href="http://localhost:8080/consumer"`)
+}
+
+func TestRender_EveryTemplate(t *testing.T) {
+	for _, name := range []string{TicketCreated, FirstResponse, StaffReply, Handover, TicketResolved, Reopened, ReopenReminder} {
+		msg, err := Render(name, TicketEmail{TicketNumber: 7, Subject: "Prompter hang", AutoCloseAt: time.Now()})
+		assert.NoError(t, err, name)
+		assert.True(t, strings.HasPrefix(msg.Subject, "[#7]"), name)
+		assert.NotEmpty(t, msg.Text, name)
+		assert.Contains(t, msg.HTML, "</html>", name)
+	}
+}
+
+func TestBuildMessage_MultipartAlternative(t *testing.T) {
+	from := &netmail.Address{Name: "IT Broadcast Ops", Address: "noreply@example.com"}
+	to := &netmail.Address{Address: "budi@example.com"}
+	data, err := buildMessage(from, to, Message{Subject: "Laporan selesai ✅", Text: "Halo\nBudi", HTML: "<p>Halo</p>"}, time.Now())
+	assert.NoError(t, err)
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(string(data)))
+	assert.NoError(t, err)
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.Equal(t, "Laporan selesai ✅", subject)
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	r := multipart.NewReader(parsed.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(part) // Quoted-printable is decoded by the reader
+		types = append(types, part.Header.Get("Content-Type"))
+		bodies = append(bodies, string(body))
+	}
+	assert.Equal(t, []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}, types)
+	assert.Equal(t, []string{"Halo\r\nBudi", "<p>Halo</p>"}, bodies)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Minute, Backoff(1))
+	assert.Equal(t, 4*time.Minute, Backoff(3))
+	assert.Equal(t, maxBackoff, Backoff(20))
+}
+
+type fakeSender struct {
+	fail int // Number of sends that fail before one succeeds
+	sent []Message
+}
+
+func (f *fakeSender) Send(msg Message) error {
+	if f.fail > 0 {
+		f.fail--
+		return errors.New("connection refused")
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestDeliver_RetriesUntilSent(t *testing.T) {
+	db := testutil.SetupTestDB()
+	fake := &fakeSender{fail: 1}
+	SetSender(fake)
+	defer SetSender(nil)
+
+	assert.NoError(t, Queue(db, "budi@example.com", TicketCreated, TicketEmail{TicketNumber: 1, Subject: "Mic mati"}))
+
+	now := time.Now().Add(time.Second)
+	assert.NoError(t, Deliver(db, now))
+	var e models.OutboxEmail
+	db.First(&e)
+	assert.Equal(t, models.EmailPending, e.Status)
+	assert.Equal(t, 1, e.Attempts)
+	assert.Equal(t, "connection refused", e.LastError)
+
+	// Not due yet
+	assert.NoError(t, Deliver(db, now.Add(30*time.Second)))
+	assert.Empty(t, fake.sent)
+
+	assert.NoError(t, Deliver(db, now.Add(Backoff(1))))
+	db.First(&e)
+	assert.Equal(t, models.EmailSent, e.Status)
+	assert.NotNil(t, e.SentAt)
+	assert.Len(t, fake.sent, 1)
+	assert.Equal(t, "budi@example.com", fake.sent[0].To)
+}
+
+func TestDeliver_GivesUp(t *testing.T) {
+	db := testutil.SetupTestDB()
+	SetSender(&fakeSender{fail: MaxAttempts})
+	defer SetSender(nil)
+
+	assert.NoError(t, Queue(db, "budi@example.com", TicketCreated, TicketEmail{TicketNumber: 1}))
+	now := time.Now()
+	for i := 0; i < MaxAttempts; i++ {
+		now = now.Add(maxBackoff)
+		assert.NoError(t, Deliver(db, now))
+	}
+	var e models.OutboxEmail
+	db.First(&e)
+	assert.Equal(t, models.EmailFailed, e.Status)
+	assert.Equal(t, MaxAttempts, e.Attempts)
+}
+
+func TestDeliver_SkipsEmailsClaimedElsewhere(t *testing.T) {
+	db := testutil.SetupTestDB()
+	fake := &fakeSender{}
+	SetSender(fake)
+	defer SetSender(nil)
+
+	assert.NoError(t, Queue(db, "budi@example.com", TicketCreated, TicketEmail{TicketNumber: 1}))
+	now := time.Now().Add(time.Second)
+
+	// Another instance holds the row
+	other := db.Begin()
+	var e models.OutboxEmail
+	other.Clauses(clause.Locking{Strength: "UPDATE"}).First(&e)
+	assert.NoError(t, Deliver(db, now))
+	assert.Empty(t, fake.sent)
+	other.Rollback()
+
+	assert.NoError(t, Deliver(db, now))
+	assert.Len(t, fake.sent, 1)
+}
@@ -0,0 +1,42 @@
+package email
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	netmail "net/mail"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Runs against a local MailHog, e.g.
+// docker run -p 1025:1025 -p 8025:8025 mailhog/mailhog
+// MAILHOG_SMTP=localhost:1025 go test ./internal/email/
+func TestSMTPSender_MailHog(t *testing.T) {
+	addr := os.Getenv("MAILHOG_SMTP")
+	if addr == "" {
+		t.Skip("MAILHOG_SMTP not set")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	assert.NoError(t, err)
+
+	s := &SMTPSender{Host: host, Port: port, Timeout: 10 * time.Second,
+		From: &netmail.Address{Name: "IT Broadcast Ops", Address: "noreply@example.com"}}
+	msg, err := Render(TicketCreated, TicketEmail{RecipientName: "Budi", TicketNumber: 1, Subject: "Uji MailHog"})
+	assert.NoError(t, err)
+	msg.To = "budi@example.com"
+	assert.NoError(t, s.Send(msg))
+
+	// The web API listens on 8025 next to SMTP
+	resp, err := http.Get("http://" + net.JoinHostPort(host, "8025") + "/api/v2/search?kind=to&query=budi@example.com")
+	if err != nil {
+		t.Skip("MailHog API not reachable: ", err)
+	}
+	defer resp.Body.Close()
+	var found struct{ Total int }
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&found))
+	assert.Positive(t, found.Total)
+}
@@ -0,0 +1,142 @@
+package email
+
+import (
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MaxAttempts is how often delivery is tried before the email is marked FAILED
+	MaxAttempts = 8
+	// deliverBatch bounds the emails claimed at once
+	deliverBatch = 50
+	// sendLease is how long claimed emails are skipped by other instances; a crash mid-batch
+	// makes them due again after it
+	sendLease      = 10 * time.Minute
+	outboxInterval = 15 * time.Second
+	maxBackoff     = 6 * time.Hour
+)
+
+// Queue renders a template and stores the email in the outbox with tx, so it is only sent
+// if the surrounding change commits. Nothing is queued while email is disabled.
+func Queue(tx *gorm.DB, to, template string, data any) error {
+	if !Enabled() || to == "" {
+		return nil
+	}
+	msg, err := Render(template, data)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxEmail{
+		ToAddress:     to,
+		Template:      template,
+		Subject:       msg.Subject,
+		TextBody:      msg.Text,
+		HTMLBody:      msg.HTML,
+		Status:        models.EmailPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// Backoff is the wait before the next try after the given number of failed attempts:
+// one minute, doubling up to maxBackoff
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 10 {
+		return maxBackoff
+	}
+	if d := time.Minute << (attempts - 1); d < maxBackoff {
+		return d
+	}
+	return maxBackoff
+}
+
+// Deliver sends due outbox emails. A batch is claimed with FOR UPDATE SKIP LOCKED and leased
+// for sendLease, so other instances skip it, and the claim commits before anything is sent.
+// Each result is then recorded on its own. A failed send is retried with Backoff; after
+// MaxAttempts the email is marked FAILED and kept for inspection.
+func Deliver(db *gorm.DB, now time.Time) error {
+	if !Enabled() {
+		return nil
+	}
+	emails, err := claim(db, now)
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	for _, e := range emails {
+		updates := map[string]interface{}{"attempts": e.Attempts + 1}
+		err := sender.Send(Message{To: e.ToAddress, Subject: e.Subject, Text: e.TextBody, HTML: e.HTMLBody})
+		switch {
+		case err == nil:
+			updates["status"] = models.EmailSent
+			updates["sent_at"] = now
+			updates["last_error"] = ""
+			sent++
+		case e.Attempts+1 >= MaxAttempts:
+			updates["status"] = models.EmailFailed
+			updates["last_error"] = err.Error()
+			log.Printf("[Email] ❌ Giving up on %s to %s: %v", e.ID, e.ToAddress, err)
+		default:
+			updates["last_error"] = err.Error()
+			updates["next_attempt_at"] = now.Add(Backoff(e.Attempts + 1))
+			log.Printf("[Email] ⚠️  Sending %s to %s failed (attempt %d): %v", e.ID, e.ToAddress, e.Attempts+1, err)
+		}
+		if err := db.Model(&models.OutboxEmail{}).Where("id = ?", e.ID).Updates(updates).Error; err != nil {
+			// The lease runs out and the email is sent again: better twice than never
+			log.Printf("[Email] ❌ Recording delivery of %s failed: %v", e.ID, err)
+		}
+	}
+	if sent > 0 {
+		log.Printf("[Email] 📧 Sent %d email(s)", sent)
+	}
+	return nil
+}
+
+// claim leases a batch of due emails to this instance
+func claim(db *gorm.DB, now time.Time) ([]models.OutboxEmail, error) {
+	var emails []models.OutboxEmail
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.EmailPending, now).
+			Order("next_attempt_at asc").Limit(deliverBatch).
+			Find(&emails).Error; err != nil {
+			return err
+		}
+		if len(emails) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(emails))
+		for i, e := range emails {
+			ids[i] = e.ID
+		}
+		return tx.Model(&models.OutboxEmail{}).Where("id IN ?", ids).Update("next_attempt_at", now.Add(sendLease)).Error
+	})
+	return emails, err
+}
+
+// StartOutbox sends the outbox every outboxInterval on its own goroutine, so slow SMTP
+// does not hold up the scheduler jobs
+func StartOutbox() {
+	if !Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(outboxInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if err := Deliver(database.DB, now); err != nil {
+				log.Printf("[Email] ❌ Outbox delivery failed: %v", err)
+			}
+		}
+	}()
+}
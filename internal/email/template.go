@@ -0,0 +1,72 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Email templates. Each has an HTML body (rendered inside layout.html) and a text
+// version whose "subject" block gives the subject line.
+const (
+	TicketCreated  = "ticket_created"
+	FirstResponse  = "first_response"
+	StaffReply     = "staff_reply"
+	Handover       = "handover"
+	TicketResolved = "resolved"
+	Reopened       = "reopened"
+	ReopenReminder = "reopen_reminder"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// TicketEmail is the data of the ticket templates
+type TicketEmail struct {
+	RecipientName string
+	TicketNumber  int
+	Subject       string
+	Location      string
+	ActorName     string // Who replied or resolved
+	Message       string // Reply or solution
+	Link          string // Where the requester follows the ticket
+	CanReopen     bool   // Logged-in requesters reopen from the dashboard, public reporters cannot
+	AutoCloseAt   time.Time
+}
+
+// Render produces the subject, text and HTML of a template
+func Render(name string, data any) (Message, error) {
+	text, err := texttemplate.New(name+".txt").Funcs(texttemplate.FuncMap(funcs)).
+		ParseFS(templateFS, "templates/"+name+".txt")
+	if err != nil {
+		return Message{}, err
+	}
+	html, err := htmltemplate.New("layout.html").Funcs(htmltemplate.FuncMap(funcs)).
+		ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html")
+	if err != nil {
+		return Message{}, err
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := text.Execute(&textBody, data); err != nil {
+		return Message{}, err
+	}
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Message{}, err
+	}
+	return Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(textBody.String()) + "\n",
+		HTML:    htmlBody.String(),
+	}, nil
+}
+
+var funcs = map[string]any{
+	"date": func(t time.Time) string { return t.Format("02 Jan 2006 15:04") },
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Enums
@@ -46,6 +47,14 @@ const (
 	LocationOBVan       LocationEnum = "OB_VAN"
 )
 
+// Routine instance statuses (routine_instances.status is a plain VARCHAR)
+const (
+	RoutinePending   = "PENDING"
+	RoutineCompleted = "COMPLETED"
+	RoutineOverdue   = "OVERDUE" // Past DueAt, still within the grace period
+	RoutineMissed    = "MISSED"  // Past DueAt + grace period, escalated to managers
+)
+
 // JSONB Helper
 type JSONB []byte
 
@@ -89,23 +98,103 @@ type Shift struct {
 	StartTime time.Time `gorm:"not null"`
 	EndTime   time.Time `gorm:"not null"`
 	Label     string
+	// Standby shift: not paged for routine work, only for urgent tickets nobody on shift picked up
+	OnCall    bool `gorm:"default:false"`
 	User      User `gorm:"foreignKey:UserID"`
 }
 
+// Article workflow states. Staff drafts go DRAFT -> IN_REVIEW, a manager then publishes,
+// sends them back (CHANGES_REQUESTED) or rejects them (ARCHIVED).
+const (
+	ArticleDraft            = "DRAFT"
+	ArticleInReview         = "IN_REVIEW"
+	ArticleChangesRequested = "CHANGES_REQUESTED"
+	ArticlePublished        = "PUBLISHED"
+	ArticleArchived         = "ARCHIVED"
+)
+
 type KnowledgeArticle struct {
 	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 	Title        string    `gorm:"not null"`
 	Content      string    `gorm:"not null"`
 	Category     string    // Simplified for now, mapped to enum in DB
 	AuthorID     uuid.UUID
-	IsVerified   bool `gorm:"default:false"`
+	IsVerified   bool `gorm:"default:false"` // Published, kept in sync with Status
+	Status       string `gorm:"type:varchar(20);index"` // ArticleDraft, ArticlePublished...
+	// Review workflow: the ticket a staff draft was written from and the manager's last decision
+	SourceTicketID *uuid.UUID `gorm:"type:uuid"` // Unique among live articles, see database.AutoMigrate
+	ReviewNote     string     // Feedback given with "request changes" or "reject"
+	ReviewerID     *uuid.UUID `gorm:"type:uuid"`
+	ReviewedAt     *time.Time
 	ViewsCount   int  `gorm:"default:0"`
 	HelpfulCount int  `gorm:"default:0"`
+	NotHelpfulCount int `gorm:"default:0"`
+	// Set by a manager when the article needs rework, cleared by the next edit
+	NeedsRevision bool `gorm:"default:false"`
+	RevisionNote  string
+	FlaggedAt     *time.Time
+	Revision      int            `gorm:"default:0"` // Number of the latest ArticleRevision
+	DeletedAt     gorm.DeletedAt `gorm:"index"`     // Archived, restorable by a manager
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	Author       User `gorm:"foreignKey:AuthorID"`
 }
 
+// ArticleRevision is an immutable snapshot of an article, written on every create, edit and rollback
+type ArticleRevision struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ArticleID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_article_revision_number"`
+	Number    int       `gorm:"uniqueIndex:idx_article_revision_number"` // 1, 2, 3... per article
+	Title     string
+	Category  string
+	Content   string
+	EditorID  uuid.UUID `gorm:"type:uuid"`
+	Note      string    // Why the revision was made, e.g. "Rollback ke revisi 2"
+	CreatedAt time.Time
+
+	Editor User `gorm:"foreignKey:EditorID"`
+}
+
+// ArticleAttachment is an image or document uploaded for an article. Images are
+// referenced inline from the content as ![caption](attachment:<ID>).
+type ArticleAttachment struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ArticleID    uuid.UUID `gorm:"type:uuid;index"`
+	FileName     string    // Original name, for display only
+	ContentType  string    // Sniffed from the content, never taken from the extension
+	Size         int64
+	URL          string
+	ThumbnailURL string // Empty for documents
+	UploaderID   uuid.UUID `gorm:"type:uuid"`
+	CreatedAt    time.Time
+
+	Article KnowledgeArticle `gorm:"foreignKey:ArticleID;constraint:OnDelete:CASCADE"`
+}
+
+// ArticleVote is a reader's "was this helpful" answer, one per user per article
+type ArticleVote struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ArticleID uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_article_vote_user"`
+	UserID    uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_article_vote_user"`
+	Helpful   bool
+	Comment   string // Only kept for "not helpful" votes
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Article KnowledgeArticle `gorm:"foreignKey:ArticleID;constraint:OnDelete:CASCADE"`
+	User    User             `gorm:"foreignKey:UserID"`
+}
+
+// ArticleView marks that a user opened an article on a given day, so views_count counts
+// each reader once per day
+type ArticleView struct {
+	ArticleID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ViewedOn  time.Time `gorm:"type:date;primaryKey"`
+
+	Article KnowledgeArticle `gorm:"foreignKey:ArticleID;constraint:OnDelete:CASCADE"`
+}
+
 type RoutineTemplate struct {
 	ID              uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 	Title           string    `gorm:"not null"`
@@ -117,14 +206,18 @@ type RoutineTemplate struct {
 }
 
 type RoutineInstance struct {
-	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	TemplateID     uuid.UUID
-	AssignedUserID uuid.UUID
-	ChecklistState JSONB     `gorm:"type:jsonb"`
-	GeneratedAt    time.Time `gorm:"default:now()"`
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TemplateID     uuid.UUID  `gorm:"uniqueIndex:idx_routine_template_slot"`
+	AssignedUserID *uuid.UUID // NULL when no shift was active at generation time
+	ChecklistState JSONB      `gorm:"type:jsonb"`
+	// Cron slot this instance was generated for; unique per template so the scheduler is idempotent
+	ScheduledFor   *time.Time `gorm:"uniqueIndex:idx_routine_template_slot"`
+	GeneratedAt    time.Time  `gorm:"default:now()"`
 	DueAt          time.Time `gorm:"not null"`
 	CompletedAt    *time.Time
 	Status         string `gorm:"default:'PENDING'"`
+	ReminderSentAt *time.Time // Pre-deadline push sent to the assignee
+	EscalatedAt    *time.Time // MISSED escalation sent to managers
 
 	Template       RoutineTemplate `gorm:"foreignKey:TemplateID"`
 }
@@ -147,13 +240,53 @@ type Ticket struct {
 	ResolvedAt        *time.Time
 	ClosedAt          *time.Time
 	IsHandover        bool `gorm:"default:false"`
+
+	// Staff member currently owning the ticket (NULL = unassigned pool)
+	CurrentAssigneeID *uuid.UUID `gorm:"type:uuid;index"`
+	ClaimedAt         *time.Time // First time anyone claimed the ticket, start of MTTA
+	ReopenCount       int        `gorm:"default:0"` // Times the requester rejected a resolution (FCR)
+	// SLA targets computed at creation from the matching SLAPolicy (NULL = no policy matched)
+	SLAPolicyID       *uuid.UUID `gorm:"type:uuid"`
+	ResponseDueAt     *time.Time `gorm:"index"`
+	ResolutionDueAt   *time.Time `gorm:"index"`
+	// Set when the SLA watcher warned that a target is about to be breached
+	ResponseAtRiskAt   *time.Time
+	ResolutionAtRiskAt *time.Time
+	// Set when the requester was reminded that the RESOLVED ticket is about to be closed
+	ReopenReminderAt   *time.Time
+	// Paging ladder of URGENT_ON_AIR tickets, repeated and escalated until someone acknowledges
+	AcknowledgedAt     *time.Time
+	AcknowledgedByID   *uuid.UUID `gorm:"type:uuid"`
+	LastPagedAt        *time.Time
+	PageCount          int    `gorm:"default:0"`
+	PageTier           string // Highest tier paged so far (ON_SHIFT, ON_CALL, MANAGERS)
+
+	// Secret of the /report/rate/:token link given to public (QR) reporters, empty for logged-in requesters
+	PublicToken       string `gorm:"index"`
 	
 	// NEW FIELD: Mencegah tiket yang sama muncul terus di saran artikel
 	IsConvertedToArticle bool `gorm:"default:false"` 
 
 	Requester         User `gorm:"foreignKey:RequesterID"`
+	CurrentAssignee   *User `gorm:"foreignKey:CurrentAssigneeID"`
 }
 
+// Ticket activity action types (ticket_activities.action_type is a plain VARCHAR)
+const (
+	ActivityReply        = "REPLY"
+	ActivityStatusChange = "STATUS_CHANGE" // Generic status move, PreviousValue/NewValue hold statuses
+	ActivityHandover     = "HANDOVER"
+	ActivityHandoverAck  = "HANDOVER_ACK" // Recipient accepted a handover, ticket back to IN_PROGRESS
+	ActivityResolve      = "RESOLVE"
+	ActivityClose        = "CLOSE"
+	ActivityReopen       = "REOPEN"
+	ActivityClaim        = "CLAIM"    // Staff took an unassigned ticket
+	ActivityAssign       = "ASSIGN"   // Ticket assigned/reassigned to someone, PreviousValue/NewValue hold user IDs
+	ActivityUnassign     = "UNASSIGN" // Ticket returned to the pool
+	ActivityPage         = "PAGE"     // Urgent ticket paged to staff, NewValue holds the tier
+	ActivityAcknowledge  = "ACK"      // Staff answered the page of an urgent ticket
+)
+
 type TicketActivity struct {
 	ID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 	TicketID      uuid.UUID
@@ -167,10 +300,214 @@ type TicketActivity struct {
 	Actor         User `gorm:"foreignKey:ActorID"`
 }
 
+// TicketAttachment is a photo or video sent with the report or with a chat message
+type TicketAttachment struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TicketID    uuid.UUID  `gorm:"type:uuid;index"`
+	ActivityID  *uuid.UUID `gorm:"type:uuid;index"` // NULL = sent with the report itself
+	FileName    string     // Original name, for display only
+	ContentType string     // Sniffed from the content
+	Size        int64
+	URL         string
+	UploaderID  uuid.UUID `gorm:"type:uuid"`
+	CreatedAt   time.Time
+
+	Ticket   *Ticket         `gorm:"foreignKey:TicketID;constraint:OnDelete:CASCADE" json:"-"`
+	Activity *TicketActivity `gorm:"foreignKey:ActivityID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Handover targets
+const (
+	HandoverToUser      = "USER"       // A named colleague
+	HandoverToNextShift = "NEXT_SHIFT" // Whoever is on the next shift
+	HandoverToPool      = "POOL"       // General pool, any staff may pick it up
+)
+
+type TicketHandover struct {
+	ID               uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TicketID         uuid.UUID  `gorm:"type:uuid;index"`
+	FromUserID       uuid.UUID  `gorm:"type:uuid"`
+	ToUserID         *uuid.UUID `gorm:"type:uuid;index"` // NULL means "To Pool"
+	Target           string     `gorm:"not null;default:'POOL'"`
+	HandoverNote     string     `gorm:"not null"`
+	AcknowledgedAt   *time.Time // NULL = still waiting for the recipient
+	AcknowledgedByID *uuid.UUID `gorm:"type:uuid"`
+	CreatedAt        time.Time
+
+	Ticket         Ticket `gorm:"foreignKey:TicketID"`
+	FromUser       User   `gorm:"foreignKey:FromUserID"`
+	ToUser         *User  `gorm:"foreignKey:ToUserID"`
+	AcknowledgedBy *User  `gorm:"foreignKey:AcknowledgedByID"`
+}
+
+// ActiveSLADue returns the SLA target the ticket is currently running against:
+// the response due time until someone claims or replies, then the resolution due time.
+func (t Ticket) ActiveSLADue() *time.Time {
+	if t.Status == StatusResolved || t.Status == StatusClosed {
+		return nil
+	}
+	if t.ClaimedAt == nil && t.FirstResponseAt == nil && t.ResponseDueAt != nil {
+		return t.ResponseDueAt
+	}
+	return t.ResolutionDueAt
+}
+
+// SLAPolicy sets response and resolution targets for tickets. Empty Priority, Category or
+// Location match any value; the most specific active policy wins.
+type SLAPolicy struct {
+	ID                uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name              string    `gorm:"not null"`
+	Priority          string
+	Category          string
+	Location          string
+	ResponseMinutes   int  `gorm:"not null"` // Until first claim or reply
+	ResolutionMinutes int  `gorm:"not null"` // Until RESOLVED
+	BusinessHoursOnly bool `gorm:"default:false"` // Count only Mon-Fri between the business hours below
+	BusinessStartHour int  `gorm:"default:8"`
+	BusinessEndHour   int  `gorm:"default:17"`
+	IsActive          bool `gorm:"default:true"`
+	CreatedAt         time.Time
+}
+
+// SLA target kinds
+const (
+	SLAResponse   = "RESPONSE"
+	SLAResolution = "RESOLUTION"
+)
+
+// SLABreach records a ticket missing one of its SLA targets, at most one per kind
+type SLABreach struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TicketID   uuid.UUID  `gorm:"type:uuid;uniqueIndex:idx_sla_breach_ticket_kind"`
+	PolicyID   *uuid.UUID `gorm:"type:uuid"`
+	Kind       string     `gorm:"not null;uniqueIndex:idx_sla_breach_ticket_kind"`
+	DueAt      time.Time  `gorm:"index"` // When the target was missed
+	CreatedAt  time.Time  // When the watcher noticed
+
+	Ticket Ticket `gorm:"foreignKey:TicketID"`
+}
+
+// TicketRating is the requester's satisfaction score (CSAT) for a finished ticket, one per ticket
+type TicketRating struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TicketID    uuid.UUID  `gorm:"type:uuid;uniqueIndex"`
+	RequesterID uuid.UUID  `gorm:"type:uuid"`
+	ResolverID  *uuid.UUID `gorm:"type:uuid;index"` // Staff who resolved the ticket, NULL if unknown
+	Score       int        `gorm:"not null"`        // 1-5
+	Comment     string
+	CreatedAt   time.Time
+
+	Ticket   Ticket `gorm:"foreignKey:TicketID"`
+	Resolver *User  `gorm:"foreignKey:ResolverID"`
+}
+
+// Suggestion outcomes: the requester either took the suggested article instead of filing
+// a ticket, or filed the ticket anyway
+const (
+	SuggestionDeflected = "DEFLECTED"
+	SuggestionIgnored   = "IGNORED"
+)
+
+// SuggestionEvent records what happened to an article suggested while a ticket was being written
+type SuggestionEvent struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ArticleID uuid.UUID  `gorm:"type:uuid;index"`
+	Category  string     `gorm:"index"` // Ticket category picked in the form
+	Subject   string     // What the requester had typed
+	Outcome   string     `gorm:"not null"` // SuggestionDeflected or SuggestionIgnored
+	Source    string     // "consumer" or "public"
+	UserID    *uuid.UUID `gorm:"type:uuid"` // NULL for public reports
+	TicketID  *uuid.UUID `gorm:"type:uuid"` // Ticket filed anyway (SuggestionIgnored only)
+	CreatedAt time.Time  `gorm:"index"`
+
+	Article KnowledgeArticle `gorm:"foreignKey:ArticleID;constraint:OnDelete:CASCADE"`
+}
+
+// Outbox email states
+const (
+	EmailPending = "PENDING"
+	EmailSent    = "SENT"
+	EmailFailed  = "FAILED" // Gave up after the last retry
+)
+
+// OutboxEmail is a rendered email waiting for, or done with, delivery. Rows are written in the
+// same transaction as the change they report, so a failing mail server never loses a message.
+type OutboxEmail struct {
+	ID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ToAddress     string    `gorm:"not null"`
+	Template      string    // Template name, for troubleshooting
+	Subject       string    `gorm:"not null"`
+	TextBody      string    `gorm:"type:text"`
+	HTMLBody      string    `gorm:"type:text"`
+	Status        string    `gorm:"not null;default:'PENDING';index:idx_outbox_due,priority:1"`
+	Attempts      int       `gorm:"default:0"`
+	LastError     string
+	NextAttemptAt time.Time `gorm:"index:idx_outbox_due,priority:2"`
+	SentAt        *time.Time
+	CreatedAt     time.Time
+}
+
 type PushSubscription struct {
 	ID       uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 	UserID   uuid.UUID
 	Endpoint string `gorm:"not null"`
 	P256dh   string `gorm:"not null"`
 	Auth     string `gorm:"not null"`
-}
\ No newline at end of file
+	// VAPID key the browser subscribed with. Pushes must be signed with the same key,
+	// so after a rotation the device keeps working on the old key until it resubscribes.
+	VapidKeyID *uuid.UUID `gorm:"type:uuid;index"`
+}
+
+// Outcomes of a push delivery attempt
+const (
+	PushSent   = "SENT"
+	PushRetry  = "RETRY"  // 429, 5xx or network error: tried again after a backoff
+	PushGone   = "GONE"   // 404 or 410: the subscription was deleted
+	PushFailed = "FAILED" // Rejected, or out of retries
+)
+
+// PushDelivery logs one attempt to deliver a web push to a subscription
+type PushDelivery struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	SubscriptionID uuid.UUID `gorm:"type:uuid;index"`
+	UserID         uuid.UUID `gorm:"type:uuid;index"`
+	PushService    string    // Host of the endpoint; the full URL is a credential and is not logged
+	Title          string
+	Topic          string
+	Urgency        string
+	TTL            int
+	Attempt        int
+	StatusCode     int
+	Outcome        string `gorm:"not null;index"`
+	Error          string
+	DurationMS     int64
+	CreatedAt      time.Time `gorm:"index"`
+}
+
+// VapidKey is a web push signing key pair. The private key is stored encrypted (AES-GCM).
+// The current key has no RetiresAt; a rotated key keeps signing pushes to the devices
+// subscribed with it until RetiresAt, when those subscriptions are dropped.
+type VapidKey struct {
+	ID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	PublicKey     string    `gorm:"not null;uniqueIndex"`
+	PrivateKeyEnc string    `gorm:"not null"`
+	RetiresAt     *time.Time
+	CreatedAt     time.Time
+}
+// SchedulerRun records that a job ran for a tick, so a tick is never run twice even when
+// replicas are late or their clocks disagree
+type SchedulerRun struct {
+	Job       string    `gorm:"primaryKey"`
+	Tick      time.Time `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+// Session is the Postgres fallback store for login sessions (used when Redis is down).
+// ID is the SHA-256 hash of the opaque token, never the token itself.
+type Session struct {
+	ID         string    `gorm:"primaryKey"`
+	UserID     uuid.UUID `gorm:"type:uuid;index"`
+	ExpiresAt  time.Time `gorm:"index"`
+	LastSeenAt time.Time
+	CreatedAt  time.Time
+}
@@ -64,8 +64,10 @@ CREATE TABLE knowledge_articles (
 CREATE FUNCTION kb_search_update() RETURNS trigger AS $$
 BEGIN
   new.search_vector :=
-    setweight(to_tsvector('indonesian', new.title), 'A') ||
-    setweight(to_tsvector('indonesian', new.content), 'B');
+    setweight(to_tsvector('indonesian', coalesce(new.title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(new.title, '')), 'A') ||
+    setweight(to_tsvector('indonesian', coalesce(new.content, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(new.content, '')), 'B');
   RETURN new;
 END
 $$ LANGUAGE plpgsql;
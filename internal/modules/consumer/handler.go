@@ -2,15 +2,19 @@ package consumer
 
 import (
 	"io"
+	articlesvc "it-broadcast-ops/internal/article"
 	"it-broadcast-ops/internal/auth"
 	"it-broadcast-ops/internal/database"
 	"it-broadcast-ops/internal/models"
 	"it-broadcast-ops/internal/notification"
 	redisClient "it-broadcast-ops/internal/redis"
+	"it-broadcast-ops/internal/search"
+	"it-broadcast-ops/internal/sla"
+	"it-broadcast-ops/internal/ticket"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
+	"strconv"
 	"time"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -19,16 +23,23 @@ import (
 
 func RegisterRoutes(r *gin.Engine) {
 	consumerGroup := r.Group("/consumer")
-	consumerGroup.Use(auth.AuthRequired(), auth.RoleRequired(models.RoleConsumer))
+	// Every employee can report problems, including IT staff and managers
+	consumerGroup.Use(auth.AuthRequired(), auth.RoleRequired(models.RoleConsumer, models.RoleStaff, models.RoleManager))
 	{
 		consumerGroup.GET("", Dashboard)
 		consumerGroup.GET("/bigbook", SearchBigBook)
+		consumerGroup.GET("/suggest", SuggestArticles)
+		consumerGroup.POST("/suggest/deflect", DeflectTicket)
 		consumerGroup.GET("/articles/:id", ArticleDetail)
+		consumerGroup.POST("/articles/:id/vote", VoteArticle)
 		consumerGroup.POST("/ticket", CreateTicket)
 
 		// Endpoints for Ticket Chat
 		consumerGroup.GET("/tickets/:id/details", GetTicketDetailJSON)
 		consumerGroup.POST("/tickets/:id/reply", ReplyTicket)
+		consumerGroup.POST("/tickets/:id/confirm", ConfirmResolution)
+		consumerGroup.POST("/tickets/:id/reopen", ReopenTicket)
+		consumerGroup.POST("/tickets/:id/rate", RateTicket)
 		consumerGroup.GET("/tickets/:id/stream", TicketChatStream) // SSE for real-time
 	}
 }
@@ -44,68 +55,98 @@ func RegisterRoutes(r *gin.Engine) {
 func Dashboard(c *gin.Context) {
 	var tickets []models.Ticket
 	
-	// Get User ID from cookie
-	userIDStr, err := c.Cookie("user_id")
-	if err == nil && userIDStr != "" {
-		// Verify UUID format to prevent SQL errors
-		if _, err := uuid.Parse(userIDStr); err == nil {
-			database.DB.Where("requester_id = ?", userIDStr).Order("created_at desc").Limit(5).Find(&tickets)
-		}
+	// Get current user from session
+	if userID, ok := auth.CurrentUserID(c); ok {
+		database.DB.Where("requester_id = ?", userID).Order("created_at desc").Limit(5).Find(&tickets)
 	}
 
 	c.HTML(http.StatusOK, "consumer/dashboard.html", gin.H{
 		"title": "Home",
 		"tickets": tickets,
+		"error": c.Query("error"),
 	})
 }
 
 // SearchBigBook godoc
 // @Summary      Search knowledge base
-// @Description  Search Big Book articles with Redis caching
+// @Description  Full-text search over Big Book articles and solved tickets
 // @Tags         Consumer
 // @Produce      json
 // @Security     CookieAuth
 // @Param        q  query  string  false  "Search query"
-// @Success      200  {array}  models.KnowledgeArticle
+// @Success      200  {array}  search.Result
 // @Router       /consumer/bigbook [get]
 func SearchBigBook(c *gin.Context) {
-	query := c.Query("q")
-	cacheKey := "cache:bigbook:" + query
-	
-	// Try cache first (if Redis is connected)
-	var articles []models.KnowledgeArticle
-	if redisClient.IsConnected() {
-		if err := redisClient.Get(cacheKey, &articles); err == nil {
-			log.Println("[Cache] HIT for bigbook:", query)
-			c.JSON(200, articles)
-			return
-		}
-		log.Println("[Cache] MISS for bigbook:", query)
+	// Not cached: results follow article publishing, archiving and votes right away
+	results, err := search.Search(c.Query("q"), search.Limit)
+	if err != nil {
+		log.Println("Big Book search failed: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Pencarian gagal"})
+		return
 	}
-	
-	// Cache miss or Redis unavailable - query database
-	db := database.DB.Model(&models.KnowledgeArticle{}).Where("is_verified = ?", true)
+	c.JSON(200, results)
+}
 
-	if query != "" {
-		db = db.Where("title ILIKE ? OR content ILIKE ?", "%"+query+"%", "%"+query+"%")
-	} else {
-		db = db.Order("title ASC")
+
+// SuggestArticles godoc
+// @Summary      Suggest articles for a ticket
+// @Description  Top verified Big Book articles for a partially typed ticket subject
+// @Tags         Consumer
+// @Produce      json
+// @Security     CookieAuth
+// @Param        subject   query  string  false  "Ticket subject typed so far"
+// @Param        category  query  string  false  "Ticket category"
+// @Success      200  {array}  search.Result
+// @Router       /consumer/suggest [get]
+func SuggestArticles(c *gin.Context) {
+	results, err := search.Suggest(c.Query("subject"), c.Query("category"), search.SuggestLimit)
+	if err != nil {
+		log.Println("Article suggestion failed: ", err)
+		results = []search.Result{}
 	}
+	c.JSON(200, results)
+}
 
-	db.Limit(50).Find(&articles)
-	
-	// Store in cache (5 minutes TTL)
-	if redisClient.IsConnected() {
-		redisClient.Set(cacheKey, articles, 5*time.Minute)
+// DeflectTicket godoc
+// @Summary      Record a deflected ticket
+// @Description  The requester solved the problem with a suggested article instead of filing a ticket
+// @Tags         Consumer
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Security     CookieAuth
+// @Param        article_id  formData  string  true   "Accepted article ID"
+// @Param        category    formData  string  false  "Ticket category"
+// @Param        subject     formData  string  false  "Ticket subject typed so far"
+// @Success      200  {object}  object  "Recorded"
+// @Failure      404  {object}  object  "Article not found"
+// @Router       /consumer/suggest/deflect [post]
+func DeflectTicket(c *gin.Context) {
+	userID, _ := auth.CurrentUserID(c)
+	articleID, err := uuid.Parse(c.PostForm("article_id"))
+	if err == nil {
+		err = search.RecordDeflection(articleID, suggestionContext(c, userID))
+	} else {
+		err = articlesvc.ErrArticleNotFound
 	}
-	
-	c.JSON(200, articles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"status": "ok"})
 }
 
+func suggestionContext(c *gin.Context, userID uuid.UUID) search.Suggestion {
+	return search.Suggestion{
+		Category: c.PostForm("category"),
+		Subject:  c.PostForm("subject"),
+		Source:   "consumer",
+		UserID:   &userID,
+	}
+}
 
 // ArticleDetail godoc
 // @Summary      View article detail
-// @Description  Display knowledge article details and increment view count
+// @Description  Display a published knowledge article and increment its view count
 // @Tags         Consumer
 // @Produce      html
 // @Security     CookieAuth
@@ -116,20 +157,71 @@ func SearchBigBook(c *gin.Context) {
 func ArticleDetail(c *gin.Context) {
 	id := c.Param("id")
 	var article models.KnowledgeArticle
-	if result := database.DB.Preload("Author").First(&article, "id = ?", id); result.Error != nil {
+	// Drafts and articles still in review are not visible to consumers
+	if result := database.DB.Preload("Author").First(&article, "id = ? AND status = ?", id, models.ArticlePublished); result.Error != nil {
 		c.Data(404, "text/html; charset=utf-8", []byte("<h1>Article not found</h1>"))
 		return
 	}
 
-	// Increment View Count
-	database.DB.Model(&article).UpdateColumn("views_count", article.ViewsCount+1)
+	// Count the view once per reader per day
+	userID, _ := auth.CurrentUserID(c)
+	if counted, err := articlesvc.RecordView(article.ID, userID, time.Now()); err != nil {
+		log.Println("Recording article view failed: ", err)
+	} else if counted {
+		article.ViewsCount++
+	}
 
 	c.HTML(http.StatusOK, "consumer/article_detail.html", gin.H{
-		"title":   article.Title,
-		"article": article,
+		"title":       article.Title,
+		"article":     article,
+		"rendered":    articlesvc.Render(&article),
+		"attachments": articlesvc.Attachments(article.ID),
+		"vote":        articlesvc.VoteOf(article.ID, userID),
+		"voted":       c.Query("voted") != "",
+		"error":       c.Query("error"),
 	})
 }
 
+// VoteArticle godoc
+// @Summary      Vote on an article
+// @Description  Answer "was this helpful" for a Big Book article. One vote per user; voting again replaces it.
+// @Tags         Consumer
+// @Accept       x-www-form-urlencoded
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id       path      string  true   "Article ID"
+// @Param        helpful  formData  bool    true   "true if the article helped"
+// @Param        comment  formData  string  false  "What was missing (not helpful votes only)"
+// @Success      302  {string}  string  "Redirect back to the article"
+// @Failure      404  {object}  object  "Article not found"
+// @Router       /consumer/articles/{id}/vote [post]
+func VoteArticle(c *gin.Context) {
+	back := "/consumer/articles/" + c.Param("id")
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		userID, _ := auth.CurrentUserID(c)
+		helpful, _ := strconv.ParseBool(c.PostForm("helpful"))
+		_, err = articlesvc.Vote(articleID, userID, helpful, c.PostForm("comment"))
+	} else {
+		err = articlesvc.ErrArticleNotFound
+	}
+
+	if auth.WantsJSON(c) {
+		if err != nil {
+			c.JSON(articlesvc.ErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+		return
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, back+"?error="+url.QueryEscape(err.Error()))
+		return
+	}
+	c.Redirect(http.StatusFound, back+"?voted=1")
+}
+
+
 // CreateTicket godoc
 // @Summary      Create new ticket
 // @Description  Submit a new support ticket
@@ -142,38 +234,22 @@ func ArticleDetail(c *gin.Context) {
 // @Param        subject      formData  string  true   "Subject"
 // @Param        description  formData  string  true   "Description"
 // @Param        urgency      formData  string  false  "Urgency"
-// @Param        proof_image  formData  file    false  "Proof image"
+// @Param        proof_image  formData  file    false  "Proof image (max 20MB, PNG/JPEG/GIF/WebP)"
+// @Param        attachments  formData  file    false  "Up to 5 photos or videos (max 20MB each)"
 // @Success      302  {string}  string  "Redirect to dashboard"
 // @Failure      500  {object}  object  "Server error"
 // @Router       /consumer/ticket [post]
 func CreateTicket(c *gin.Context) {
-	userIDStr, _ := c.Cookie("user_id")
-	userID, _ := uuid.Parse(userIDStr)
-
-	var proofURL string
-    file, err := c.FormFile("proof_image")
-    if err == nil {
-        // 1. Validasi Ukuran (Max 5MB)
-        if file.Size > 5*1024*1024 {
-             // Opsional: Handle error file terlalu besar
-        } else {
-            // 2. Buat nama file unik: evidence_<timestamp>.<ext>
-            ext := filepath.Ext(file.Filename)
-            filename := fmt.Sprintf("evidence_%d%s", time.Now().UnixNano(), ext)
-            
-            // 3. Pastikan folder uploads/tickets ada
-            saveDir := "web/uploads/tickets"
-            os.MkdirAll(saveDir, 0755)
-            
-            savePath := filepath.Join(saveDir, filename)
-            
-            // 4. Simpan file
-            if err := c.SaveUploadedFile(file, savePath); err == nil {
-                // Simpan URL relatif untuk akses web
-                proofURL = "/uploads/tickets/" + filename
-            }
-        }
-    }
+	userID, _ := auth.CurrentUserID(c)
+
+	// Photos and videos are optional, but a rejected one is reported instead of dropped
+	form, _ := c.MultipartForm()
+	files, err := ticket.ReadFiles(ticket.FormFiles(form, "proof_image", "attachments"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/consumer?error="+url.QueryEscape(err.Error()))
+		return
+	}
+
 	category := c.PostForm("category")
 	validCategories := map[string]bool{
 		"AUDIO": true, "VIDEO": true, "IT_NETWORK": true, "SOFTWARE": true, "ELECTRICAL": true,
@@ -182,44 +258,49 @@ func CreateTicket(c *gin.Context) {
 		category = "IT_NETWORK" // Default fallback jika invalid
 	}
 	
-	ticket := models.Ticket{
+	t := models.Ticket{
 		Location:    models.LocationEnum(c.PostForm("location")), // Simplified mapping
 		// Urgency -> Priority mapping needs care, for now assume compatible strings or map manually
 		Priority:    models.PriorityNormal, // Default
 		Category:    category,
 		Subject:     c.PostForm("subject"),
 		Description: c.PostForm("description"),
-		ProofImageURL: proofURL, // <--- Masukkan URL gambar di sini
 		RequesterID:   userID,
 		Status:        models.StatusOpen,
         CreatedAt:     time.Now(), // Pastikan created_at terisi
 	}
 	
 	if c.PostForm("urgency") == "ON_AIR_EMERGENCY" {
-		ticket.Priority = models.PriorityUrgentOnAir
+		t.Priority = models.PriorityUrgentOnAir
 	} else if c.PostForm("urgency") == "PRE_PRODUCTION" { // Tambahan jika ada opsi ini
-        ticket.Priority = models.PriorityHigh
+        t.Priority = models.PriorityHigh
     }
 
-	if err := database.DB.Create(&ticket).Error; err != nil {
+	sla.Apply(&t)
+	if err := database.DB.Create(&t).Error; err != nil {
 		c.JSON(500, gin.H{"error": "Failed to create ticket: " + err.Error()})
 		return
 	}
+	if _, err := ticket.AttachToReport(&t, userID, files); err != nil {
+		log.Printf("[Ticket] Storing attachments of %s failed: %v", t.ID, err)
+	}
+	ticket.NotifyCreated(&t)
+
+	// Articles offered while typing did not help
+	search.RecordIgnored(t.ID, c.PostFormArray("suggested_ids"), suggestionContext(c, userID))
 
 	
 	// [PUSH NOTIFICATION TRIGGER]
-	if ticket.Priority == models.PriorityUrgentOnAir {
-		go notification.SendBroadcastToStaff(
-			"🔥 URGENT: " + string(ticket.Location),
-			ticket.Subject + " (ON AIR ISSUE)",
-			"/staff/tickets/" + ticket.ID.String(),
-		)
+	if t.Priority == models.PriorityUrgentOnAir {
+		// Paged again and escalated until someone acknowledges
+		ticket.StartPaging(t.ID)
 	} else {
 		// Notif biasa (Opsional)
 		go notification.SendBroadcastToStaff(
-			"New Ticket: " + string(ticket.Location),
-			ticket.Subject,
-			"/staff/tickets/" + ticket.ID.String(),
+			"New Ticket: " + string(t.Location),
+			t.Subject,
+			"/staff/tickets/" + t.ID.String(),
+			notification.ForTicket(t.ID, t.Priority),
 		)
 	}
 
@@ -236,25 +317,27 @@ func CreateTicket(c *gin.Context) {
 // @Security     CookieAuth
 // @Param        id  path  string  true  "Ticket ID"
 // @Success      200  {object}  object  "Ticket and activities"
+// @Failure      403  {object}  object  "Not the requester"
 // @Failure      404  {object}  object  "Ticket not found"
 // @Router       /consumer/tickets/{id}/details [get]
 func GetTicketDetailJSON(c *gin.Context) {
-	id := c.Param("id")
-	var ticket models.Ticket
-	
-	// Fetch Ticket
-	if err := database.DB.Preload("Requester").First(&ticket, "id = ?", id).Error; err != nil {
-		c.JSON(404, gin.H{"error": "Ticket not found"})
+	user, tk, ok := participantTicket(c)
+	if !ok {
 		return
 	}
+	t := *tk
+	id := t.ID
 
 	// Fetch Activities (Chat History)
 	var activities []models.TicketActivity
 	database.DB.Preload("Actor").
-		Where("ticket_id = ?", id).
+		// Pages to staff are internal, recorded on behalf of the requester
+		Where("ticket_id = ? AND action_type <> ?", id, models.ActivityPage).
 		Order("created_at asc").
 		Find(&activities)
 
+	reportFiles, chatFiles := ticket.Attachments(t.ID)
+
 	// Custom JSON Response to simplify frontend handling
 	type ActivityView struct {
 		ActorName   string
@@ -263,19 +346,21 @@ func GetTicketDetailJSON(c *gin.Context) {
 		Note        string
 		Time        string
 		IsMe        bool
+		Attachments []models.TicketAttachment
 	}
 	
 	var activityViews []ActivityView
-	userIDStr, _ := c.Cookie("user_id")
+	userID := user.ID
 
 	// Add Initial Description as first "Chat"
 	activityViews = append(activityViews, ActivityView{
-		ActorName:   ticket.Requester.FullName,
-		ActorAvatar: ticket.Requester.AvatarURL,
+		ActorName:   t.Requester.FullName,
+		ActorAvatar: t.Requester.AvatarURL,
 		ActionType:  "CREATED",
-		Note:        ticket.Description,
-		Time:        ticket.CreatedAt.Format("15:04"),
-		IsMe:        ticket.RequesterID.String() == userIDStr,
+		Note:        t.Description,
+		Time:        t.CreatedAt.Format("15:04"),
+		IsMe:        t.RequesterID == userID,
+		Attachments: reportFiles,
 	})
 
 	for _, act := range activities {
@@ -285,69 +370,256 @@ func GetTicketDetailJSON(c *gin.Context) {
 			ActionType:  act.ActionType,
 			Note:        act.Note,
 			Time:        act.CreatedAt.Format("02 Jan 15:04"),
-			IsMe:        act.ActorID.String() == userIDStr,
+			IsMe:        act.ActorID == userID,
+			Attachments: chatFiles[act.ID],
 		})
 	}
 
+	rating := ticket.RatingFor(t.ID)
+
 	c.JSON(200, gin.H{
-		"ticket": ticket,
+		"ticket": t,
 		"activities": activityViews,
+		"currentUserId": userID,
+		// Only the requester confirms or rejects a resolution
+		"canConfirm": t.RequesterID == userID && t.Status == models.StatusResolved,
+		"rating":     rating,
+		"canRate":    t.RequesterID == userID && rating == nil && ticket.CanRate(&t),
 	})
 }
 
 // ReplyTicket godoc
 // @Summary      Reply to ticket
-// @Description  Add a reply message to a ticket
+// @Description  Add a reply message with optional photos or videos to a ticket
 // @Tags         Consumer
-// @Accept       x-www-form-urlencoded
+// @Accept       multipart/form-data
 // @Produce      html
 // @Security     CookieAuth
-// @Param        id       path      string  true  "Ticket ID"
-// @Param        message  formData  string  true  "Reply message"
+// @Param        id           path      string  true   "Ticket ID"
+// @Param        message      formData  string  false  "Reply message"
+// @Param        attachments  formData  file    false  "Up to 5 photos or videos (max 20MB each)"
 // @Success      302  {string}  string  "Redirect to dashboard"
+// @Failure      403  {object}  map[string]string
 // @Router       /consumer/tickets/{id}/reply [post]
 func ReplyTicket(c *gin.Context) {
-	id := c.Param("id")
-	message := c.PostForm("message")
-	userIDStr, _ := c.Cookie("user_id")
-	userID, _ := uuid.Parse(userIDStr)
-
-	if message == "" {
-		c.Redirect(http.StatusFound, "/consumer")
+	user, t, ok := participantTicket(c)
+	if !ok {
+		return
+	}
+	ticketID := t.ID
+
+	form, _ := c.MultipartForm()
+	files, err := ticket.ReadFiles(ticket.FormFiles(form, "attachments"))
+	var activity *models.TicketActivity
+	var attachments []models.TicketAttachment
+	if err == nil {
+		activity, attachments, err = ticket.Reply(ticketID, user.ID, c.PostForm("message"), files)
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/consumer?error="+url.QueryEscape(err.Error()))
 		return
 	}
 
-	// Get user info for the activity view
-	var user models.User
-	database.DB.First(&user, "id = ?", userID)
+	// Publish to Redis for real-time updates
+	publishActivity(ticketID.String(), user, *activity, attachments, "")
+
+	c.Redirect(http.StatusFound, "/consumer")
+}
+
+// ConfirmResolution godoc
+// @Summary      Confirm resolution
+// @Description  Requester confirms a RESOLVED ticket is fixed, which closes it. A CSAT score can be sent along.
+// @Tags         Consumer
+// @Accept       x-www-form-urlencoded
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id       path      string  true   "Ticket ID"
+// @Param        score    formData  int     false  "Score 1-5"
+// @Param        comment  formData  string  false  "Comment"
+// @Success      302  {string}  string  "Redirect to dashboard"
+// @Failure      409  {object}  map[string]string
+// @Router       /consumer/tickets/{id}/confirm [post]
+func ConfirmResolution(c *gin.Context) {
+	user, t, ok := requesterTicket(c)
+	if !ok {
+		return
+	}
 
-	activity := models.TicketActivity{
-		TicketID:   uuid.MustParse(id),
-		ActorID:    userID,
-		ActionType: "REPLY",
-		Note:       message,
-		CreatedAt:  time.Now(),
+	closed, err := ticket.Close(t.ID, user.ID, "Dikonfirmasi selesai oleh pelapor")
+	if err != nil {
+		respondTicketAction(c, err)
+		return
 	}
-	database.DB.Create(&activity)
+	publishLastActivity(closed, user)
 
-	// Publish to Redis for real-time updates
-	if redisClient.IsConnected() {
-		activityView := map[string]interface{}{
-			"ActorName":   user.FullName,
-			"ActorAvatar": user.AvatarURL,
-			"ActionType":  "REPLY",
-			"Note":        message,
-			"Time":        activity.CreatedAt.Format("02 Jan 15:04"),
-			"IsMe":        false, // Will be determined client-side
-			"ActorID":     userID.String(),
+	if score, err := strconv.Atoi(c.PostForm("score")); err == nil {
+		if _, err := ticket.Rate(t.ID, user.ID, score, c.PostForm("comment")); err != nil {
+			log.Println("[CSAT] Rating on confirm failed:", err)
 		}
-		redisClient.Publish("chat:"+id, activityView)
-		log.Println("[Redis] Published chat message for ticket:", id)
 	}
 
+	if resolverID, ok := ticket.ResolverID(t.ID); ok {
+		go notification.SendNotificationToUser(
+			resolverID.String(),
+			fmt.Sprintf("✅ Tiket #%d dikonfirmasi selesai", t.TicketNumber),
+			user.FullName+" mengonfirmasi: "+t.Subject,
+			"/staff/tickets/"+t.ID.String(),
+			notification.ForTicket(t.ID, t.Priority),
+		)
+	}
+	respondTicketAction(c, nil)
+}
+
+// ReopenTicket godoc
+// @Summary      Reopen ticket
+// @Description  Requester rejects a resolution; the ticket goes back to IN_PROGRESS and the resolver is notified
+// @Tags         Consumer
+// @Accept       x-www-form-urlencoded
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id      path      string  true  "Ticket ID"
+// @Param        reason  formData  string  true  "Why the problem is not solved"
+// @Success      302  {string}  string  "Redirect to dashboard"
+// @Failure      409  {object}  map[string]string
+// @Router       /consumer/tickets/{id}/reopen [post]
+func ReopenTicket(c *gin.Context) {
+	user, t, ok := requesterTicket(c)
+	if !ok {
+		return
+	}
+
+	reason := c.PostForm("reason")
+	reopened, err := ticket.Reopen(t.ID, user.ID, reason)
+	if err != nil {
+		respondTicketAction(c, err)
+		return
+	}
+	publishLastActivity(reopened, user)
+
+	if resolverID, ok := ticket.ResolverID(t.ID); ok {
+		go notification.SendNotificationToUser(
+			resolverID.String(),
+			fmt.Sprintf("🔁 Tiket #%d dibuka kembali", t.TicketNumber),
+			user.FullName+": "+reason,
+			"/staff/tickets/"+t.ID.String(),
+			notification.ForTicket(t.ID, t.Priority),
+		)
+	}
+	respondTicketAction(c, nil)
+}
+
+// RateTicket godoc
+// @Summary      Rate ticket
+// @Description  Requester gives a CSAT score (1-5) and optional comment for a finished ticket
+// @Tags         Consumer
+// @Accept       x-www-form-urlencoded
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id       path      string  true   "Ticket ID"
+// @Param        score    formData  int     true   "Score 1-5"
+// @Param        comment  formData  string  false  "Comment"
+// @Success      302  {string}  string  "Redirect to dashboard"
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /consumer/tickets/{id}/rate [post]
+func RateTicket(c *gin.Context) {
+	user, t, ok := requesterTicket(c)
+	if !ok {
+		return
+	}
+
+	score, _ := strconv.Atoi(c.PostForm("score"))
+	_, err := ticket.Rate(t.ID, user.ID, score, c.PostForm("comment"))
+	respondTicketAction(c, err)
+}
+
+// requesterTicket loads the ticket in :id and checks the session user reported it
+func requesterTicket(c *gin.Context) (*models.User, *models.Ticket, bool) {
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		c.Redirect(http.StatusFound, "/auth/login")
+		return nil, nil, false
+	}
+	var t models.Ticket
+	if err := database.DB.First(&t, "id = ?", c.Param("id")).Error; err != nil {
+		respondTicketAction(c, ticket.ErrTicketNotFound)
+		return nil, nil, false
+	}
+	if t.RequesterID != user.ID {
+		respondTicketAction(c, ticket.ErrNotRequester)
+		return nil, nil, false
+	}
+	return user, &t, true
+}
+
+// participantTicket loads the ticket in :id for its requester, or for any staff member or manager
+func participantTicket(c *gin.Context) (*models.User, *models.Ticket, bool) {
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		c.Redirect(http.StatusFound, "/auth/login")
+		return nil, nil, false
+	}
+	var t models.Ticket
+	if err := database.DB.Preload("Requester").First(&t, "id = ?", c.Param("id")).Error; err != nil {
+		respondTicketAction(c, ticket.ErrTicketNotFound)
+		return nil, nil, false
+	}
+	if t.RequesterID != user.ID && !auth.HasRole(user, models.RoleStaff, models.RoleManager) {
+		respondTicketAction(c, ticket.ErrNotRequester)
+		return nil, nil, false
+	}
+	return user, &t, true
+}
+
+// respondTicketAction answers confirm/reopen: JSON for fetch calls, otherwise back to the dashboard
+func respondTicketAction(c *gin.Context, err error) {
+	if auth.WantsJSON(c) {
+		if err != nil {
+			c.JSON(ticket.ErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+		return
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/consumer?error="+url.QueryEscape(err.Error()))
+		return
+	}
 	c.Redirect(http.StatusFound, "/consumer")
 }
 
+// publishLastActivity pushes the activity written by a status change to TicketChatStream viewers
+func publishLastActivity(t *models.Ticket, actor *models.User) {
+	var activity models.TicketActivity
+	if err := database.DB.Where("ticket_id = ?", t.ID).Order("created_at desc").First(&activity).Error; err != nil {
+		return
+	}
+	publishActivity(t.ID.String(), actor, activity, nil, t.Status)
+}
+
+// publishActivity sends an activity on the chat:<id> channel in the same shape as GetTicketDetailJSON.
+// status is set when the activity changed the ticket status, so open chats can update their header.
+func publishActivity(ticketID string, actor *models.User, activity models.TicketActivity, attachments []models.TicketAttachment, status models.TicketStatus) {
+	if !redisClient.IsConnected() {
+		return
+	}
+	activityView := map[string]interface{}{
+		"ActorName":   actor.FullName,
+		"ActorAvatar": actor.AvatarURL,
+		"ActionType":  activity.ActionType,
+		"Note":        activity.Note,
+		"Time":        activity.CreatedAt.Format("02 Jan 15:04"),
+		"IsMe":        false, // Will be determined client-side
+		"ActorID":     actor.ID.String(),
+		"Attachments": attachments,
+	}
+	if status != "" {
+		activityView["Status"] = status
+	}
+	redisClient.Publish("chat:"+ticketID, activityView)
+	log.Println("[Redis] Published", activity.ActionType, "for ticket:", ticketID)
+}
+
 // TicketChatStream godoc
 // @Summary      Ticket chat SSE stream
 // @Description  Server-Sent Events stream for real-time chat updates
@@ -356,9 +628,14 @@ func ReplyTicket(c *gin.Context) {
 // @Security     CookieAuth
 // @Param        id  path  string  true  "Ticket ID"
 // @Success      200  {string}  string  "SSE stream"
+// @Failure      403  {object}  object  "Not the requester"
 // @Router       /consumer/tickets/{id}/stream [get]
 func TicketChatStream(c *gin.Context) {
-	tickemID := c.Param("id")
+	_, t, ok := participantTicket(c)
+	if !ok {
+		return
+	}
+	ticketID := t.ID.String()
 
 	// Set SSE headers
 	c.Header("Content-Type", "text/event-stream")
@@ -373,7 +650,7 @@ func TicketChatStream(c *gin.Context) {
 	}
 
 	// Subscribe to ticket channel
-	sub := redisClient.Subscribe("chat:" + tickemID)
+	sub := redisClient.Subscribe("chat:" + ticketID)
 	defer sub.Close()
 
 	ch := sub.Channel()
@@ -390,7 +667,7 @@ func TicketChatStream(c *gin.Context) {
 			c.Writer.Flush()
 		case <-c.Request.Context().Done():
 			// Client disconnected
-			log.Println("[SSE] Client disconnected from ticket:", tickemID)
+			log.Println("[SSE] Client disconnected from ticket:", ticketID)
 			return
 		case <-time.After(30 * time.Second):
 			// Send heartbeat to keep connection alive
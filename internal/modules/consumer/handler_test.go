@@ -1,10 +1,12 @@
 package consumer
 
 import (
+	"it-broadcast-ops/internal/auth"
 	"it-broadcast-ops/internal/models"
 	"it-broadcast-ops/internal/testutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
     "time"
 
@@ -46,10 +48,12 @@ func TestDashboard_Access(t *testing.T) {
     r.GET("/consumer", Dashboard)
 
 	req, _ := http.NewRequest("GET", "/consumer", nil)
-	// Inject Cookie manually
+	// Inject Session Cookie manually
+	sessionValue, err := auth.IssueSession(userID)
+	assert.NoError(t, err)
 	cookie := &http.Cookie{
-		Name:  "user_id",
-		Value: userID.String(),
+		Name:  auth.SessionCookieName,
+		Value: sessionValue,
 	}
 	req.AddCookie(cookie)
 	
@@ -93,3 +97,122 @@ func TestSearchBigBook(t *testing.T) {
     assert.Equal(t, http.StatusOK, wEmpty.Code)
     assert.Contains(t, wEmpty.Body.String(), "Wifi Troubleshooting")
 }
+
+func TestReopenTicket_OnlyRequester(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	other := models.User{Email: "other@example.com", FullName: "Other", Role: models.RoleConsumer, IsActive: true}
+	db.Create(&requester)
+	db.Create(&other)
+
+	resolvedAt := time.Now()
+	ticket := models.Ticket{RequesterID: requester.ID, Subject: "Mic mati", Status: models.StatusResolved, ResolvedAt: &resolvedAt}
+	db.Create(&ticket)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/consumer/tickets/:id/reopen", ReopenTicket)
+
+	reopen := func(userID uuid.UUID) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/consumer/tickets/"+ticket.ID.String()+"/reopen", strings.NewReader("reason=Masih+mati"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		sessionValue, err := auth.IssueSession(userID)
+		assert.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: sessionValue})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, reopen(other.ID).Code)
+	assert.Equal(t, http.StatusOK, reopen(requester.ID).Code)
+
+	var reloaded models.Ticket
+	db.First(&reloaded, "id = ?", ticket.ID)
+	assert.Equal(t, models.StatusInProgress, reloaded.Status)
+	assert.Equal(t, 1, reloaded.ReopenCount)
+	assert.Nil(t, reloaded.ResolvedAt)
+}
+
+func TestTicketChat_OnlyRequesterOrStaff(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	other := models.User{Email: "other@example.com", FullName: "Other", Role: models.RoleConsumer, IsActive: true}
+	staff := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&requester)
+	db.Create(&other)
+	db.Create(&staff)
+
+	ticket := models.Ticket{RequesterID: requester.ID, Subject: "Mic mati", Status: models.StatusInProgress}
+	db.Create(&ticket)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/consumer/tickets/:id/details", GetTicketDetailJSON)
+	r.POST("/consumer/tickets/:id/reply", ReplyTicket)
+
+	send := func(userID uuid.UUID, method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		sessionValue, err := auth.IssueSession(userID)
+		assert.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: sessionValue})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	base := "/consumer/tickets/" + ticket.ID.String()
+
+	// Someone else's ticket: no chat history, no replies
+	assert.Equal(t, http.StatusForbidden, send(other.ID, "GET", base+"/details", "").Code)
+	assert.Equal(t, http.StatusForbidden, send(other.ID, "POST", base+"/reply", "message=Halo").Code)
+	var replies int64
+	db.Model(&models.TicketActivity{}).Where("ticket_id = ? AND action_type = ?", ticket.ID, "REPLY").Count(&replies)
+	assert.Zero(t, replies)
+
+	assert.Equal(t, http.StatusOK, send(requester.ID, "GET", base+"/details", "").Code)
+	assert.Equal(t, http.StatusFound, send(requester.ID, "POST", base+"/reply", "message=Masih+mati").Code)
+	assert.Equal(t, http.StatusOK, send(staff.ID, "GET", base+"/details", "").Code)
+	assert.Equal(t, http.StatusFound, send(staff.ID, "POST", base+"/reply", "message=Sedang+dicek").Code)
+	db.Model(&models.TicketActivity{}).Where("ticket_id = ? AND action_type = ?", ticket.ID, "REPLY").Count(&replies)
+	assert.Equal(t, int64(2), replies)
+
+	assert.Equal(t, http.StatusNotFound, send(requester.ID, "GET", "/consumer/tickets/"+uuid.New().String()+"/details", "").Code)
+}
+
+func TestArticleDetail_PublishedOnly(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	reader := models.User{Email: "reader@example.com", FullName: "Reader", Role: models.RoleConsumer, IsActive: true}
+	db.Create(&reader)
+	published := models.KnowledgeArticle{Title: "Reset intercom", Content: "Cabut kabel power", Status: models.ArticlePublished, IsVerified: true}
+	draft := models.KnowledgeArticle{Title: "Draft prompter", Content: "Belum selesai", Status: models.ArticleDraft}
+	db.Create(&published)
+	db.Create(&draft)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.LoadHTMLGlob("../../../web/templates/**/*")
+	r.GET("/consumer/articles/:id", ArticleDetail)
+
+	view := func(id uuid.UUID) int {
+		req, _ := http.NewRequest("GET", "/consumer/articles/"+id.String(), nil)
+		sessionValue, err := auth.IssueSession(reader.ID)
+		assert.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: sessionValue})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, view(published.ID))
+	assert.Equal(t, http.StatusNotFound, view(draft.ID))
+
+	var reloaded models.KnowledgeArticle
+	db.First(&reloaded, "id = ?", draft.ID)
+	assert.Zero(t, reloaded.ViewsCount, "unpublished articles are not counted")
+}
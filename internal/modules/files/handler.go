@@ -0,0 +1,129 @@
+package files
+
+import (
+	"errors"
+	"io"
+	"it-broadcast-ops/internal/auth"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/upload"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func RegisterRoutes(r *gin.Engine) {
+	r.GET("/files/*key", auth.AuthRequired(), Serve)
+	// Files uploaded before the upload service existed, read-only
+	r.GET("/uploads/*key", auth.AuthRequired(), ServeLegacy)
+}
+
+var legacyStorage upload.Storage = upload.NewLocalStorage("web/uploads")
+
+// Serve godoc
+// @Summary      Download an uploaded file
+// @Description  Serve an uploaded file if the current user may see it
+// @Tags         Files
+// @Security     CookieAuth
+// @Param        key  path  string  true  "File key"
+// @Success      200  {file}    file    "File content"
+// @Failure      404  {object}  object  "File not found"
+// @Router       /files/{key} [get]
+func Serve(c *gin.Context) {
+	serve(c, upload.Current(), upload.URLPrefix)
+}
+
+func ServeLegacy(c *gin.Context) {
+	serve(c, legacyStorage, "/uploads/")
+}
+
+// Types shown in the browser; anything else is downloaded
+var inlineTypes = map[string]bool{
+	"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true, "application/pdf": true,
+	"video/mp4": true, "video/webm": true,
+}
+
+func serve(c *gin.Context, store upload.Storage, prefix string) {
+	user, ok := auth.CurrentUser(c)
+	key, err := upload.CleanKey(c.Param("key"))
+	// Files the user may not see do not exist for them
+	if !ok || err != nil || !CanRead(user, key, prefix+key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": upload.ErrNotFound.Error()})
+		return
+	}
+
+	rc, obj, err := store.Get(c.Request.Context(), key)
+	if errors.Is(err, upload.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": upload.ErrNotFound.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[Files] Reading %s failed: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membaca file"})
+		return
+	}
+	defer rc.Close()
+
+	contentType := obj.ContentType
+	disposition := "inline"
+	if !inlineTypes[contentType] {
+		contentType = "application/octet-stream"
+		disposition = "attachment"
+	}
+	h := c.Writer.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Disposition", disposition)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	h.Set("Cache-Control", "private, max-age=86400")
+
+	// Both backends can seek, which gives videos the range requests players need
+	if rs, ok := rc.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, "", obj.ModTime, rs)
+		return
+	}
+	c.DataFromReader(http.StatusOK, obj.Size, contentType, rc, nil)
+}
+
+// CanRead decides whether the user may download the file stored under key, url is
+// the address the file is referenced by in the database
+func CanRead(user *models.User, key, url string) bool {
+	if auth.HasRole(user, models.RoleStaff, models.RoleManager) {
+		return true
+	}
+	folder, rest, _ := strings.Cut(key, "/")
+	switch folder {
+	case "avatars":
+		// Profile pictures are shown to every signed-in user
+		return true
+	case "articles":
+		// Big Book attachments (articles/<article id>/) only of published, not archived articles
+		dir, _, _ := strings.Cut(rest, "/")
+		articleID, err := uuid.Parse(dir)
+		if err != nil {
+			return false
+		}
+		var count int64
+		database.DB.Model(&models.KnowledgeArticle{}).
+			Where("id = ? AND status = ?", articleID, models.ArticlePublished).
+			Count(&count)
+		return count > 0
+	case "tickets":
+		// Photos and videos of a ticket only to its requester. Current files live in
+		// tickets/<ticket id>/, older ones are known by the ticket's ProofImageURL.
+		query := database.DB.Model(&models.Ticket{}).Where("requester_id = ?", user.ID)
+		dir, _, nested := strings.Cut(rest, "/")
+		if ticketID, err := uuid.Parse(dir); err == nil && nested {
+			query = query.Where("id = ?", ticketID)
+		} else {
+			query = query.Where("proof_image_url = ?", url)
+		}
+		var count int64
+		query.Count(&count)
+		return count > 0
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package files
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"it-broadcast-ops/internal/auth"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"it-broadcast-ops/internal/upload"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe_OnlyToAllowedUsers(t *testing.T) {
+	db := testutil.SetupTestDB()
+	upload.SetStorage(upload.NewLocalStorage(t.TempDir()))
+	defer upload.SetStorage(upload.NewLocalStorage("data/uploads"))
+
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))))
+	f, err := upload.Read(&buf, upload.ProofImagePolicy)
+	assert.NoError(t, err)
+	key, err := upload.Store("tickets", f)
+	assert.NoError(t, err)
+
+	owner := models.User{Email: "owner@example.com", FullName: "Owner", Role: models.RoleConsumer, IsActive: true}
+	other := models.User{Email: "other@example.com", FullName: "Other", Role: models.RoleConsumer, IsActive: true}
+	staff := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&owner)
+	db.Create(&other)
+	db.Create(&staff)
+	db.Create(&models.Ticket{Subject: "Kamera mati", Location: models.LocationStudio1, RequesterID: owner.ID,
+		Status: models.StatusOpen, ProofImageURL: upload.URL(key)})
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterRoutes(r)
+
+	get := func(user *models.User, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		if user != nil {
+			session, err := auth.IssueSession(user.ID)
+			assert.NoError(t, err)
+			req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session})
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get(&owner, upload.URL(key))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+
+	assert.Equal(t, http.StatusOK, get(&staff, upload.URL(key)).Code)
+	assert.Equal(t, http.StatusNotFound, get(&other, upload.URL(key)).Code)
+	assert.Equal(t, http.StatusUnauthorized, get(nil, upload.URL(key)).Code)
+
+	// Big Book attachments only of published articles
+	published := models.KnowledgeArticle{Title: "Reset prompter", Content: "-", Category: "SOFTWARE", AuthorID: staff.ID,
+		Status: models.ArticlePublished, IsVerified: true}
+	draft := models.KnowledgeArticle{Title: "Draft", Content: "-", Category: "SOFTWARE", AuthorID: staff.ID,
+		Status: models.ArticleInReview}
+	db.Create(&published)
+	db.Create(&draft)
+	publishedKey := "articles/" + published.ID.String() + "/a.png"
+	draftKey := "articles/" + draft.ID.String() + "/a.png"
+	assert.True(t, CanRead(&other, publishedKey, "/files/"+publishedKey))
+	assert.False(t, CanRead(&other, draftKey, "/files/"+draftKey))
+	assert.True(t, CanRead(&staff, draftKey, "/files/"+draftKey))
+	assert.False(t, CanRead(&other, "articles/not-an-id.png", "/files/articles/not-an-id.png"))
+
+	db.Delete(&published)
+	assert.False(t, CanRead(&other, publishedKey, "/files/"+publishedKey), "archived articles are hidden")
+}
@@ -7,14 +7,20 @@ import (
 	"encoding/csv"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"net/http"
 	"strings"
 	"time"
 	"strconv"
 	"fmt"
 	"log"
+	"net/url"
 	"encoding/json"
+	articlesvc "it-broadcast-ops/internal/article"
 	"it-broadcast-ops/internal/auth"
+	"it-broadcast-ops/internal/notification"
+	"it-broadcast-ops/internal/ticket"
+	"it-broadcast-ops/internal/upload"
 )
 
 func RegisterRoutes(r *gin.Engine) {
@@ -36,17 +42,35 @@ func RegisterRoutes(r *gin.Engine) {
 		managerGroup.POST("/routines/:id/delete", DeleteRoutine)
 		managerGroup.POST("/routines/:id/toggle-active", ToggleRoutine)
 
+		// SLA Policy Routes
+		managerGroup.POST("/sla/create", CreateSLAPolicy)
+		managerGroup.POST("/sla/:id/delete", DeleteSLAPolicy)
+		managerGroup.POST("/sla/:id/toggle-active", ToggleSLAPolicy)
+
+		// Push Notification Keys
+		managerGroup.POST("/notifications/rotate-keys", RotatePushKeys)
+		managerGroup.GET("/notifications/deliveries", PushDeliveries)
+
 		// Big Book Routes
 		managerGroup.GET("/articles/:id/json", GetArticleJSON) 
 		managerGroup.POST("/articles/create", CreateArticle)
 		managerGroup.POST("/articles/:id/verify", VerifyArticle)
 		managerGroup.POST("/articles/:id/deny", DenyArticle)
+		managerGroup.POST("/articles/:id/changes", RequestArticleChanges)
 		managerGroup.POST("/articles/:id/update", UpdateArticle)
 		managerGroup.POST("/articles/:id/delete", DeleteArticle)
+		managerGroup.POST("/articles/:id/flag", FlagArticle)
+		managerGroup.POST("/articles/:id/unflag", UnflagArticle)
+		managerGroup.GET("/articles/:id/history", ArticleHistory)
+		managerGroup.POST("/articles/:id/rollback", RollbackArticle)
+		managerGroup.POST("/articles/:id/restore", RestoreArticle)
+		managerGroup.POST("/articles/:id/purge", PurgeArticle)
+		managerGroup.POST("/articles/:id/attachments", UploadArticleAttachment)
 		
 		// Ticket Conversion
 		managerGroup.POST("/tickets/:id/convert", ConvertTicketToArticle)
 		managerGroup.POST("/tickets/:id/deny", DenyTicket) 
+		managerGroup.POST("/tickets/:id/assign", AssignTicket)
 	}
 }
 // ExportReport godoc
@@ -91,6 +115,7 @@ func ExportReport(c *gin.Context) {
 		"Ticket No", "Subject", "Category", "Location", 
 		"Status", "Priority", "Requester", "Processed By", // Updated: Menampilkan siapa yang resolve/handover
 		"Created At", "Resolved At", "Response Time (Mins)", "Duration (Mins)", "Solution",
+		"CSAT Score", "CSAT Comment",
 	}
 	if err := writer.Write(headers); err != nil {
 		c.JSON(500, gin.H{"error": "Failed to write header"})
@@ -104,6 +129,18 @@ func ExportReport(c *gin.Context) {
 		Order("created_at desc").
 		Find(&tickets)
 
+	// CSAT ratings of these tickets, keyed by ticket
+	ticketIDs := make([]uuid.UUID, 0, len(tickets))
+	for _, t := range tickets {
+		ticketIDs = append(ticketIDs, t.ID)
+	}
+	var ratings []models.TicketRating
+	database.DB.Where("ticket_id IN ?", ticketIDs).Find(&ratings)
+	ratingByTicket := make(map[uuid.UUID]models.TicketRating, len(ratings))
+	for _, r := range ratings {
+		ratingByTicket[r.TicketID] = r
+	}
+
 	// 5. Loop dan Tulis Baris Data
 	for _, t := range tickets {
 		// Format Waktu
@@ -118,8 +155,9 @@ func ExportReport(c *gin.Context) {
 			duration = fmt.Sprintf("%.0f", dur)
 		}
 
-		if t.FirstResponseAt != nil {
-			res := t.FirstResponseAt.Sub(t.CreatedAt).Minutes()
+		// Response = first claim, or first reply for tickets never claimed
+		if acked := firstAck(t.ClaimedAt, t.FirstResponseAt); acked != nil {
+			res := acked.Sub(t.CreatedAt).Minutes()
 			responseTime = fmt.Sprintf("%.0f", res)
 		}
 
@@ -149,6 +187,12 @@ func ExportReport(c *gin.Context) {
 		}
 		// Skenario 3: Open/In-Progress -> processedBy tetap "-"
 
+		csatScore, csatComment := "-", ""
+		if r, ok := ratingByTicket[t.ID]; ok {
+			csatScore = fmt.Sprintf("%d", r.Score)
+			csatComment = r.Comment
+		}
+
 		record := []string{
 			fmt.Sprintf("#%d", t.TicketNumber),
 			t.Subject,
@@ -163,6 +207,8 @@ func ExportReport(c *gin.Context) {
 			responseTime,
 			duration,
 			t.Solution,
+			csatScore,
+			csatComment,
 		}
 
 		if err := writer.Write(record); err != nil {
@@ -189,13 +235,14 @@ func DownloadShiftTemplate(c *gin.Context) {
 	writer := csv.NewWriter(c.Writer)
 	
 	// Header Wajib
-	writer.Write([]string{"email", "label", "start_time", "end_time"})
+	writer.Write([]string{"email", "label", "start_time", "end_time", "on_call"})
 	
 	// Contoh Data (Agar user paham formatnya)
 	// Format Time: 2006-01-02 15:04 (Sesuai parser di ImportSchedule)
 	tomorrow := time.Now().Add(24 * time.Hour).Format("2006-01-02")
 	writer.Write([]string{"staff@example.com", "Shift Pagi", tomorrow + " 07:00", tomorrow + " 15:00"})
 	writer.Write([]string{"staff2@example.com", "Shift Siang", tomorrow + " 14:00", tomorrow + " 22:00"})
+	writer.Write([]string{"staff3@example.com", "On-call Malam", tomorrow + " 22:00", tomorrow + " 23:59", "yes"})
 	
 	writer.Flush()
 }
@@ -211,6 +258,7 @@ func DownloadShiftTemplate(c *gin.Context) {
 // @Param        label       formData  string  false "Shift label"
 // @Param        start_time  formData  string  true  "Start time (datetime-local)"
 // @Param        end_time    formData  string  true  "End time (datetime-local)"
+// @Param        on_call     formData  bool    false "Standby shift, only paged for unanswered urgent tickets"
 // @Success      302  {string}  string  "Redirect to dashboard"
 // @Router       /manager/shifts/create [post]
 func CreateShift(c *gin.Context) {
@@ -246,6 +294,7 @@ func CreateShift(c *gin.Context) {
 		StartTime: startTime,
 		EndTime:   endTime,
 		Label:     label,
+		OnCall:    c.PostForm("on_call") != "",
 	}
 
 	if err := database.DB.Create(&shift).Error; err != nil {
@@ -305,11 +354,11 @@ func Dashboard(c *gin.Context) {
 		}
 	}
 	
-	// MTTA
+	// MTTA (acknowledged = first claim, falling back to first reply for tickets never claimed)
 	var mttaPtr *float64
 	database.DB.Model(&models.Ticket{}).
-		Select("AVG(EXTRACT(EPOCH FROM (first_response_at - created_at))/60)").
-		Where("first_response_at IS NOT NULL AND created_at >= ? AND created_at < ?", startDate, endDate).
+		Select("AVG(EXTRACT(EPOCH FROM (COALESCE(claimed_at, first_response_at) - created_at))/60)").
+		Where("COALESCE(claimed_at, first_response_at) IS NOT NULL AND created_at >= ? AND created_at < ?", startDate, endDate).
 		Scan(&mttaPtr)
 	mtta := 0.0
 	if mttaPtr != nil { mtta = *mttaPtr }
@@ -323,11 +372,14 @@ func Dashboard(c *gin.Context) {
 	mttr := 0.0
 	if mttrPtr != nil { mttr = *mttrPtr }
 
-	// FCR Calculation
+	// FCR Calculation: resolved without handover and never reopened by the requester
+	finished := []models.TicketStatus{models.StatusResolved, models.StatusClosed}
 	var totalResolved int64
 	var fcrCount int64
-	database.DB.Model(&models.Ticket{}).Where("status = ? AND created_at >= ? AND created_at < ?", models.StatusResolved, startDate, endDate).Count(&totalResolved)
-	database.DB.Model(&models.Ticket{}).Where("status = ? AND is_handover = ? AND created_at >= ? AND created_at < ?", models.StatusResolved, false, startDate, endDate).Count(&fcrCount)
+	var reopenedCount int64
+	database.DB.Model(&models.Ticket{}).Where("status IN ? AND created_at >= ? AND created_at < ?", finished, startDate, endDate).Count(&totalResolved)
+	database.DB.Model(&models.Ticket{}).Where("status IN ? AND is_handover = ? AND reopen_count = 0 AND created_at >= ? AND created_at < ?", finished, false, startDate, endDate).Count(&fcrCount)
+	database.DB.Model(&models.Ticket{}).Where("reopen_count > 0 AND created_at >= ? AND created_at < ?", startDate, endDate).Count(&reopenedCount)
 	
 	fcrRate := 0.0
 	if totalResolved > 0 {
@@ -370,48 +422,59 @@ func Dashboard(c *gin.Context) {
 		weekNum++
 	}
 
-	// SLA Compliance Stats
+	// SLA Compliance Stats - per policy, from the breaches recorded by the SLA watcher.
+	// Counts finished tickets plus open ones whose resolution target already passed.
 	type SLAMetric struct {
-		Priority         string
-		TotalTickets     int
-		CompliantTickets int
-		ComplianceRate   float64
+		Policy            string
+		ResponseMinutes   int
+		ResolutionMinutes int
+		BusinessHoursOnly bool
+		TotalTickets      int
+		ResponseCompliant int
+		CompliantTickets  int
+		ResponseRate      float64
+		ComplianceRate    float64
 	}
-	
-    var slaMetrics []interface{}
-	// Removed redeclared variables here (allStaff, routineTemplates) to fix error
-
-	// Query for Urgent (15 mins) - FILTERED BY SELECTED MONTH
-	var urgentStats SLAMetric
+	var slaMetrics []SLAMetric
 	database.DB.Raw(`
-		SELECT 
-			'URGENT_ON_AIR' as priority,
+		SELECT
+			p.name as policy, p.response_minutes, p.resolution_minutes, p.business_hours_only,
 			COUNT(*) as total_tickets,
-			COUNT(*) FILTER (WHERE EXTRACT(EPOCH FROM (resolved_at - created_at))/60 <= 15) as compliant_tickets
-		FROM tickets 
-		WHERE priority = 'URGENT_ON_AIR' AND status IN ('RESOLVED', 'CLOSED')
-		AND created_at >= ? AND created_at < ?
-	`, startDate, endDate).Scan(&urgentStats)
-	if urgentStats.TotalTickets > 0 {
-		urgentStats.ComplianceRate = (float64(urgentStats.CompliantTickets) / float64(urgentStats.TotalTickets)) * 100
-	}
-	slaMetrics = append(slaMetrics, urgentStats)
-
-	// Query for Normal/High (8 Hours = 480 mins) - FILTERED BY SELECTED MONTH
-	var normalStats SLAMetric
-	database.DB.Raw(`
-		SELECT 
-			'NORMAL' as priority,
-			COUNT(*) as total_tickets,
-			COUNT(*) FILTER (WHERE EXTRACT(EPOCH FROM (resolved_at - created_at))/60 <= 480) as compliant_tickets
-		FROM tickets 
-		WHERE priority IN ('NORMAL', 'HIGH') AND status IN ('RESOLVED', 'CLOSED')
-		AND created_at >= ? AND created_at < ?
-	`, startDate, endDate).Scan(&normalStats)
-	if normalStats.TotalTickets > 0 {
-		normalStats.ComplianceRate = (float64(normalStats.CompliantTickets) / float64(normalStats.TotalTickets)) * 100
+			COUNT(*) FILTER (WHERE NOT EXISTS (
+				SELECT 1 FROM sla_breaches b WHERE b.ticket_id = t.id AND b.kind = ?
+			)) as response_compliant,
+			COUNT(*) FILTER (WHERE NOT EXISTS (
+				SELECT 1 FROM sla_breaches b WHERE b.ticket_id = t.id AND b.kind = ?
+			)) as compliant_tickets
+		FROM tickets t
+		JOIN sla_policies p ON p.id = t.sla_policy_id
+		WHERE (t.status IN ? OR t.resolution_due_at <= ?)
+		AND t.created_at >= ? AND t.created_at < ?
+		GROUP BY p.id, p.name, p.response_minutes, p.resolution_minutes, p.business_hours_only
+		ORDER BY p.resolution_minutes
+	`, models.SLAResponse, models.SLAResolution, finished, now, startDate, endDate).Scan(&slaMetrics)
+	for i := range slaMetrics {
+		if m := &slaMetrics[i]; m.TotalTickets > 0 {
+			m.ResponseRate = float64(m.ResponseCompliant) / float64(m.TotalTickets) * 100
+			m.ComplianceRate = float64(m.CompliantTickets) / float64(m.TotalTickets) * 100
+		}
 	}
-	slaMetrics = append(slaMetrics, normalStats)
+
+	// Live SLA state of active tickets
+	var slaBreachedNow, slaAtRiskNow int64
+	database.DB.Model(&models.Ticket{}).
+		Where("status NOT IN ? AND EXISTS (SELECT 1 FROM sla_breaches b WHERE b.ticket_id = tickets.id)", finished).
+		Count(&slaBreachedNow)
+	database.DB.Model(&models.Ticket{}).
+		Where("status NOT IN ? AND (response_at_risk_at IS NOT NULL OR resolution_at_risk_at IS NOT NULL)", finished).
+		Where("NOT EXISTS (SELECT 1 FROM sla_breaches b WHERE b.ticket_id = tickets.id)").
+		Count(&slaAtRiskNow)
+
+	// Time to acknowledge urgent pages
+	urgentAck := ticket.UrgentAckStats(startDate, endDate)
+
+	var slaPolicies []models.SLAPolicy
+	database.DB.Order("is_active desc, resolution_minutes asc").Find(&slaPolicies)
 
 	// CATEGORY DISTRIBUTION (Pie Chart Data)
 	type CategoryCount struct {
@@ -477,11 +540,10 @@ func Dashboard(c *gin.Context) {
 	// 5. BIG BOOK DATA
 	var articleCount, newArticlesCount int64
 	database.DB.Model(&models.KnowledgeArticle{}).Count(&articleCount)
-	database.DB.Model(&models.KnowledgeArticle{}).Where("is_verified = ?", false).Count(&newArticlesCount)
 
-	// List Pending (Approval Queue)
-	var pendingArticles []models.KnowledgeArticle
-	database.DB.Preload("Author").Where("is_verified = ?", false).Find(&pendingArticles)
+	// List Pending (Approval Queue): staff drafts submitted for review
+	pendingArticles := articlesvc.ReviewQueue()
+	newArticlesCount = int64(len(pendingArticles))
 
 	// List Published
 	var publishedArticles []models.KnowledgeArticle
@@ -491,7 +553,7 @@ func Dashboard(c *gin.Context) {
 	// Ambil tiket yang: 1. Resolved, 2. Punya solusi, 3. BELUM diconvert jadi artikel
 	var candidateTickets []models.Ticket
 	database.DB.Preload("Requester").
-		Where("status = ? AND solution != '' AND is_converted_to_article = ?", models.StatusResolved, false).
+		Where("status IN ? AND solution != '' AND is_converted_to_article = ?", finished, false).
 		Order("resolved_at desc").
 		Limit(5).
 		Find(&candidateTickets)
@@ -503,6 +565,7 @@ func Dashboard(c *gin.Context) {
 		TimeStr     string // "07:00 - 15:00"
 		StaffName   string
 		Label       string // "Shift Pagi", "WFH"
+		OnCall      bool
 		AvatarURL   string
 		StatusClass string // For styling
 	}
@@ -523,7 +586,9 @@ func Dashboard(c *gin.Context) {
 	for _, shift := range dbShifts {
 		// Determine styling based on Label or Time
 		statusClass := "bg-blue-100 text-blue-600" // Default On Duty
-		if strings.Contains(strings.ToUpper(shift.Label), "WFH") {
+		if shift.OnCall {
+			statusClass = "bg-amber-100 text-amber-700"
+		} else if strings.Contains(strings.ToUpper(shift.Label), "WFH") {
 			statusClass = "bg-purple-100 text-purple-600"
 		} else if strings.Contains(strings.ToUpper(shift.Label), "OFF") {
 			statusClass = "bg-slate-100 text-slate-500"
@@ -542,6 +607,7 @@ func Dashboard(c *gin.Context) {
 			TimeStr:     startTimeWIB.Format("15:04") + " - " + endTimeWIB.Format("15:04"),
 			StaffName:   shift.User.FullName,
 			Label:       shift.Label,
+			OnCall:      shift.OnCall,
 			AvatarURL:   shift.User.AvatarURL,
 			StatusClass: statusClass,
 		})
@@ -552,7 +618,7 @@ func Dashboard(c *gin.Context) {
 	nowTime := time.Now()
 	// Check strictly active now
 	database.DB.Preload("User").
-		Where("start_time <= ? AND end_time >= ?", nowTime, nowTime).
+		Where("start_time <= ? AND end_time >= ? AND on_call = ?", nowTime, nowTime, false).
 		Order("start_time desc").
 		First(&activeShift)
 
@@ -567,7 +633,13 @@ func Dashboard(c *gin.Context) {
 		MTTA          string
 		MTTR          string
 		BigBookContrib int64
-		Rating        float64
+		Rating        float64 // Average CSAT score (1-5) of tickets this staff resolved
+		RatingCount   int64
+		// Routine compliance (instances due in the selected month)
+		RoutineOnTime     int64
+		RoutineLate       int64
+		RoutineMissed     int64
+		RoutineCompliance float64 // on-time / (on-time + late + missed) * 100
 	}
 	var staffPerformance []StaffStat
 	
@@ -588,22 +660,20 @@ func Dashboard(c *gin.Context) {
 			Count(&bbCount)
 
 		// Calculate MTTA for this staff IN SELECTED MONTH
-		// MTTA = Average time from ticket creation to first response by THIS staff
+		// MTTA = Average time from ticket creation to the first claim, for tickets THIS staff claimed first
 		var mttaMinutes *float64
 		database.DB.Raw(`
-			SELECT AVG(EXTRACT(EPOCH FROM (ta.created_at - t.created_at))/60) as mtta_minutes
-			FROM ticket_activities ta
-			JOIN tickets t ON ta.ticket_id = t.id
-			WHERE ta.actor_id = ?
-			AND ta.action_type IN ('REPLY', 'IN_PROGRESS', 'STATUS_CHANGE')
-			AND ta.created_at >= ? AND ta.created_at < ?
-			AND ta.created_at = (
-				SELECT MIN(ta2.created_at) 
-				FROM ticket_activities ta2 
-				WHERE ta2.ticket_id = t.id 
-				AND ta2.actor_id = ?
-			)
-		`, user.ID, startDate, endDate, user.ID).Scan(&mttaMinutes)
+			SELECT AVG(EXTRACT(EPOCH FROM (fc.created_at - t.created_at))/60) as mtta_minutes
+			FROM (
+				SELECT DISTINCT ON (ticket_id) ticket_id, actor_id, created_at
+				FROM ticket_activities
+				WHERE action_type = ?
+				ORDER BY ticket_id, created_at
+			) fc
+			JOIN tickets t ON fc.ticket_id = t.id
+			WHERE fc.actor_id = ?
+			AND fc.created_at >= ? AND fc.created_at < ?
+		`, models.ActivityClaim, user.ID, startDate, endDate).Scan(&mttaMinutes)
 
 		mttaStr := "N/A"
 		if mttaMinutes != nil && *mttaMinutes > 0 {
@@ -636,23 +706,35 @@ func Dashboard(c *gin.Context) {
 			}
 		}
 
-		// Rating calculation - based on performance metrics
-		// Simple formula: Base 3.0 + bonuses for good metrics
-		rating := 3.0
-		if solvedCount > 0 {
-			rating += 0.5 // Bonus for having solved tickets
-		}
-		if solvedCount >= 10 {
-			rating += 0.5 // Bonus for solving 10+ tickets
+		// Rating = average CSAT given by requesters IN SELECTED MONTH
+		var csat struct {
+			Avg   float64
+			Count int64
 		}
-		if bbCount > 0 {
-			rating += 0.5 // Bonus for Big Book contributions
+		database.DB.Model(&models.TicketRating{}).
+			Select("COALESCE(AVG(score), 0) as avg, COUNT(*) as count").
+			Where("resolver_id = ? AND created_at >= ? AND created_at < ?", user.ID, startDate, endDate).
+			Scan(&csat)
+
+		// Routine compliance IN SELECTED MONTH (by due date)
+		var routineStats struct {
+			OnTime int64
+			Late   int64
+			Missed int64
 		}
-		if mttaMinutes != nil && *mttaMinutes < 15 {
-			rating += 0.5 // Bonus for fast response
-		}
-		if rating > 5.0 {
-			rating = 5.0 // Cap at 5.0
+		database.DB.Raw(`
+			SELECT
+				COUNT(*) FILTER (WHERE status = ? AND completed_at <= due_at) as on_time,
+				COUNT(*) FILTER (WHERE status = ? AND completed_at > due_at) as late,
+				COUNT(*) FILTER (WHERE status = ?) as missed
+			FROM routine_instances
+			WHERE assigned_user_id = ?
+			AND due_at >= ? AND due_at < ?
+		`, models.RoutineCompleted, models.RoutineCompleted, models.RoutineMissed, user.ID, startDate, endDate).Scan(&routineStats)
+
+		routineCompliance := 0.0
+		if total := routineStats.OnTime + routineStats.Late + routineStats.Missed; total > 0 {
+			routineCompliance = float64(routineStats.OnTime) / float64(total) * 100
 		}
 
 		staffPerformance = append(staffPerformance, StaffStat{
@@ -663,14 +745,103 @@ func Dashboard(c *gin.Context) {
 			MTTA:          mttaStr,
 			MTTR:          mttrStr,
 			BigBookContrib: bbCount,
-			Rating:        rating,
+			Rating:        csat.Avg,
+			RatingCount:   csat.Count,
+			RoutineOnTime:     routineStats.OnTime,
+			RoutineLate:       routineStats.Late,
+			RoutineMissed:     routineStats.Missed,
+			RoutineCompliance: routineCompliance,
 		})
 	}
 	
+	// CSAT per category IN SELECTED MONTH (by rating date)
+	type CategoryCSAT struct {
+		Category string
+		Avg      float64
+		Count    int64
+	}
+	var csatByCategory []CategoryCSAT
+	database.DB.Raw(`
+		SELECT t.category, AVG(r.score) as avg, COUNT(*) as count
+		FROM ticket_ratings r
+		JOIN tickets t ON t.id = r.ticket_id
+		WHERE r.created_at >= ? AND r.created_at < ?
+		GROUP BY t.category
+		ORDER BY avg DESC
+	`, startDate, endDate).Scan(&csatByCategory)
+
+	var csatOverall struct {
+		Avg   float64
+		Count int64
+	}
+	database.DB.Model(&models.TicketRating{}).
+		Select("COALESCE(AVG(score), 0) as avg, COUNT(*) as count").
+		Where("created_at >= ? AND created_at < ?", startDate, endDate).
+		Scan(&csatOverall)
+
+	// Smart suggestion deflection IN SELECTED MONTH: how often a suggested article was taken
+	// instead of filing a ticket. Per article every suggestion counts; per category a ticket
+	// filed after seeing several suggestions counts once.
+	type DeflectionStat struct {
+		Name      string
+		Category  string
+		Deflected int64
+		Ignored   int64
+		Rate      float64
+	}
+	var deflectionByArticle, deflectionByCategory []DeflectionStat
+	database.DB.Raw(`
+		SELECT a.title as name, a.category::text as category,
+			COUNT(*) FILTER (WHERE e.outcome = ?) as deflected,
+			COUNT(*) FILTER (WHERE e.outcome = ?) as ignored
+		FROM suggestion_events e
+		JOIN knowledge_articles a ON a.id = e.article_id
+		WHERE e.created_at >= ? AND e.created_at < ?
+		GROUP BY a.id, a.title, a.category
+		ORDER BY deflected DESC, ignored ASC
+		LIMIT 10
+	`, models.SuggestionDeflected, models.SuggestionIgnored, startDate, endDate).Scan(&deflectionByArticle)
+	database.DB.Raw(`
+		SELECT e.category as name, e.category as category,
+			COUNT(*) FILTER (WHERE e.outcome = ?) as deflected,
+			COUNT(DISTINCT e.ticket_id) FILTER (WHERE e.outcome = ?) as ignored
+		FROM suggestion_events e
+		WHERE e.created_at >= ? AND e.created_at < ?
+		GROUP BY e.category
+		ORDER BY deflected DESC
+	`, models.SuggestionDeflected, models.SuggestionIgnored, startDate, endDate).Scan(&deflectionByCategory)
+
+	var deflectedTotal, suggestedTotal int64
+	for _, stats := range [][]DeflectionStat{deflectionByArticle, deflectionByCategory} {
+		for i := range stats {
+			if total := stats[i].Deflected + stats[i].Ignored; total > 0 {
+				stats[i].Rate = float64(stats[i].Deflected) / float64(total) * 100
+			}
+		}
+	}
+	for _, st := range deflectionByCategory {
+		deflectedTotal += st.Deflected
+		suggestedTotal += st.Deflected + st.Ignored
+	}
+	deflectionRate := 0.0
+	if suggestedTotal > 0 {
+		deflectionRate = float64(deflectedTotal) / float64(suggestedTotal) * 100
+	}
+
+	// Big Book analytics: what gets read, what does not help, what is getting old
+	mostViewedArticles := articlesvc.MostViewed(5)
+	leastHelpfulArticles := articlesvc.LeastHelpful(5)
+	staleArticles := articlesvc.Stale(time.Now(), 5)
+	var flaggedArticles []models.KnowledgeArticle
+	database.DB.Preload("Author").Where("needs_revision = ?", true).Order("flagged_at desc").Find(&flaggedArticles)
+
 	// 7. ALL STAFF (For New Shift Modal Dropdown)
 	var allStaff []models.User
 	database.DB.Where("role = ?", models.RoleStaff).Find(&allStaff)
 
+	// Staff and managers a ticket can be assigned to
+	assignableStaff := ticket.AssignableStaff()
+
 	// 8. ROUTINE TEMPLATES
 	var routineTemplates []models.RoutineTemplate
 	database.DB.Find(&routineTemplates)
@@ -686,14 +857,17 @@ func Dashboard(c *gin.Context) {
 		Category     string
 		Status       string
 		RequesterName string
+		AssigneeID   *uuid.UUID
+		AssigneeName string
 		CreatedAt    time.Time
 	}
 	var incomingTickets []IncomingTicketView
 	database.DB.Raw(`
 		SELECT t.id, t.ticket_number, t.subject, t.location, t.priority, t.category, t.status, 
-		       u.full_name as requester_name, t.created_at
+		       u.full_name as requester_name, t.current_assignee_id as assignee_id, a.full_name as assignee_name, t.created_at
 		FROM tickets t
 		LEFT JOIN users u ON t.requester_id = u.id
+		LEFT JOIN users a ON t.current_assignee_id = a.id
 		WHERE t.status IN ('OPEN', 'IN_PROGRESS', 'HANDOVER')
 		ORDER BY t.created_at DESC
 		LIMIT 50
@@ -745,12 +919,13 @@ func Dashboard(c *gin.Context) {
 		Category      string
 		RequesterName string
 		CreatedAt     time.Time
+		ClaimedAt     *time.Time
 		FirstResponseAt *time.Time
 		ResolvedAt    *time.Time
 	}
 	database.DB.Raw(`
 		SELECT t.id, t.ticket_number, t.subject, t.category, 
-		       u.full_name as requester_name, t.created_at, t.first_response_at, t.resolved_at
+		       u.full_name as requester_name, t.created_at, t.claimed_at, t.first_response_at, t.resolved_at
 		FROM tickets t
 		LEFT JOIN users u ON t.requester_id = u.id
 		WHERE t.status IN ('RESOLVED', 'CLOSED')
@@ -775,8 +950,8 @@ func Dashboard(c *gin.Context) {
 
 		// Calculate response time
 		responseTime := "-"
-		if rt.FirstResponseAt != nil {
-			mins := rt.FirstResponseAt.Sub(rt.CreatedAt).Minutes()
+		if acked := firstAck(rt.ClaimedAt, rt.FirstResponseAt); acked != nil {
+			mins := acked.Sub(rt.CreatedAt).Minutes()
 			if mins < 60 {
 				responseTime = fmt.Sprintf("%.0f menit", mins)
 			} else {
@@ -820,6 +995,7 @@ func Dashboard(c *gin.Context) {
 		"mtta":              int(mtta),
 		"mttr":              int(mttr),
 		"fcr":               int(fcrRate),
+		"reopenedCount":     reopenedCount,
 		"selectedMonth":     monthParam, // Current selected month (YYYY-MM)
 		"monthOptions":      monthOptions, // List of last 12 months for dropdown
 		"chartData":         chartData, // Data Chart
@@ -834,8 +1010,28 @@ func Dashboard(c *gin.Context) {
 		"activeShift":       activeShift,
 		"hasActiveShift":    hasActiveShift,
 		"staffPerformance":  staffPerformance,
+		"csatByCategory":    csatByCategory,
+		"csatAvg":           csatOverall.Avg,
+		"csatCount":         csatOverall.Count,
+		"deflectionByArticle":  deflectionByArticle,
+		"deflectionByCategory": deflectionByCategory,
+		"deflectionRate":       deflectionRate,
+		"deflectedTotal":       deflectedTotal,
+		"mostViewedArticles":   mostViewedArticles,
+		"leastHelpfulArticles": leastHelpfulArticles,
+		"staleArticles":        staleArticles,
+		"flaggedArticles":      flaggedArticles,
+		"archivedArticles":     articlesvc.Archived(),
 		"allStaff":          allStaff,
+		"assignableStaff":   assignableStaff,
 		"slaMetrics":        slaMetrics,
+		"slaBreachedNow":    slaBreachedNow,
+		"slaAtRiskNow":      slaAtRiskNow,
+		"slaPolicies":       slaPolicies,
+		"urgentAck":         urgentAck,
+		"pushKeys":          notification.StoredKeys(),
+		"pushKeysPersisted": notification.KeysPersisted(),
+		"pushKeyGraceDays":  int(notification.RotationGrace().Hours() / 24),
 		"routineTemplates":  routineTemplates,
 		// Ticket History Data
 		"incomingTickets":    incomingTickets,
@@ -878,7 +1074,7 @@ func ImportSchedule(c *gin.Context) {
 	}
 
 	// 3. Process Records
-	// Format: email, label, start_time, end_time
+	// Format: email, label, start_time, end_time[, on_call]
 	// Time Format: 2006-01-02 15:04
 	layout := "02/01/2006 15:04"
 	var successCount int
@@ -901,6 +1097,11 @@ func ImportSchedule(c *gin.Context) {
 		label := strings.TrimSpace(record[1])
 		startStr := strings.TrimSpace(record[2])
 		endStr := strings.TrimSpace(record[3])
+		onCall := false
+		if len(record) > 4 {
+			v := strings.ToLower(strings.TrimSpace(record[4]))
+			onCall = v == "yes" || v == "true" || v == "1"
+		}
 
 		// Find User
 		var user models.User
@@ -924,6 +1125,7 @@ func ImportSchedule(c *gin.Context) {
 			StartTime: startTime,
 			EndTime:   endTime,
 			Label:     label,
+			OnCall:    onCall,
 		}
 		if err := database.DB.Create(&shift).Error; err != nil {
 			log.Printf("Import failed for row %d: DB Error %v", i, err)
@@ -952,6 +1154,8 @@ func GetArticleJSON(c *gin.Context) {
 		"title":    article.Title,
 		"category": article.Category,
 		"content":  article.Content,
+		"html":     articlesvc.Render(&article).HTML,
+		"revision": article.Revision,
 		"author":   article.Author.FullName,
 		"date":     article.CreatedAt.Format("02 Jan 2006"),
 	})
@@ -980,72 +1184,102 @@ func ConvertTicketToArticle(c *gin.Context) {
 
 	// Buat Artikel Baru
 	article := models.KnowledgeArticle{
-		Title:      ticket.Subject,
-		Category:   ticket.Category,
-		Content:    ticket.Solution, // Solusi tiket otomatis jadi konten artikel
-		AuthorID:   authorID,        // Author = Staff who resolved the ticket
-		IsVerified: true,            // Langsung verified karena masuk jalur cepat
-		CreatedAt:  time.Now(),
+		AuthorID:       authorID,                // Author = Staff who resolved the ticket
+		Status:         models.ArticlePublished, // Langsung publish karena masuk jalur cepat
+		SourceTicketID: &ticket.ID,
+	}
+	fields := articlesvc.Fields{
+		Title:    ticket.Subject,
+		Category: ticket.Category,
+		Content:  ticket.Solution, // Solusi tiket otomatis jadi konten artikel
 	}
+	managerID, _ := auth.CurrentUserID(c)
 
-	if err := database.DB.Create(&article).Error; err != nil {
-		// handle error
-	} else {
-		// PENTING: Tandai tiket sudah dikonversi agar hilang dari list kandidat
-		database.DB.Model(&ticket).Update("is_converted_to_article", true)
+	if err := articlesvc.Create(&article, fields, managerID, fmt.Sprintf("Dari tiket #%d", ticket.TicketNumber)); err != nil {
+		log.Printf("[Manager] Convert ticket %s failed: %v", ticketID, err)
+		c.Redirect(http.StatusFound, "/manager?error=ConvertFailed")
+		return
 	}
+	// PENTING: Tandai tiket sudah dikonversi agar hilang dari list kandidat
+	database.DB.Model(&ticket).Update("is_converted_to_article", true)
 
 	c.Redirect(http.StatusFound, "/manager")
 }
 // 3. Create Manual Article
 func CreateArticle(c *gin.Context) {
-	title := c.PostForm("title")
-	category := c.PostForm("category")
-	content := c.PostForm("content")
-	userIDStr, _ := c.Cookie("user_id")
-	userID, _ := uuid.Parse(userIDStr)
+	userID, _ := auth.CurrentUserID(c)
 
 	article := models.KnowledgeArticle{
-		Title:      title,
-		Category:   category,
-		Content:    content,
-		AuthorID:   userID,
-		IsVerified: true, // Artikel buatan manager langsung publish
-		CreatedAt:  time.Now(),
-	}
-	database.DB.Create(&article)
+		AuthorID: userID,
+		Status:   models.ArticlePublished, // Artikel buatan manager langsung publish
+	}
+	if err := articlesvc.Create(&article, articleFields(c), userID, "Dibuat"); err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=CreateFailed")
+		return
+	}
 	c.Redirect(http.StatusFound, "/manager")
 }
 
-// 4. Update Article
+// 4. Update Article (every edit is kept as a revision)
 func UpdateArticle(c *gin.Context) {
-	id := c.Param("id")
-	var article models.KnowledgeArticle
-	if err := database.DB.First(&article, "id = ?", id).Error; err == nil {
-		article.Title = c.PostForm("title")
-		article.Category = c.PostForm("category")
-		article.Content = c.PostForm("content")
-		database.DB.Save(&article)
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		userID, _ := auth.CurrentUserID(c)
+		_, err = articlesvc.Update(articleID, articleFields(c), userID, c.PostForm("note"))
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=UpdateFailed")
+		return
 	}
 	c.Redirect(http.StatusFound, "/manager")
 }
 
+func articleFields(c *gin.Context) articlesvc.Fields {
+	return articlesvc.Fields{
+		Title:    c.PostForm("title"),
+		Category: c.PostForm("category"),
+		Content:  c.PostForm("content"),
+	}
+}
+
 // 5. Verify (Approve) Article from Staff
 func VerifyArticle(c *gin.Context) {
-	id := c.Param("id")
-	database.DB.Model(&models.KnowledgeArticle{}).Where("id = ?", id).Update("is_verified", true)
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		userID, _ := auth.CurrentUserID(c)
+		_, err = articlesvc.Approve(articleID, userID)
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=ApproveFailed")
+		return
+	}
 	c.Redirect(http.StatusFound, "/manager")
 }
 
-
-// DenyArticle: Menolak artikel yang masih draft/pending. 
-// Logikanya adalah menghapus artikel tersebut dari tabel knowledge_articles.
+// DenyArticle: Menolak draft yang sedang direview, dengan feedback untuk penulis.
+// Artikel diarsipkan (soft delete), masih bisa di-restore dari Archive.
 func DenyArticle(c *gin.Context) {
-	id := c.Param("id")
-	// Hapus artikel berdasarkan ID
-	if err := database.DB.Delete(&models.KnowledgeArticle{}, "id = ?", id).Error; err != nil {
-		// Anda bisa menambahkan logging error di sini
-		c.Redirect(http.StatusFound, "/manager?error=DeleteFailed")
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		userID, _ := auth.CurrentUserID(c)
+		_, err = articlesvc.Reject(articleID, userID, c.PostForm("note"))
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=DenyFailed")
+		return
+	}
+	c.Redirect(http.StatusFound, "/manager")
+}
+
+// RequestArticleChanges: Mengembalikan draft ke penulis untuk diperbaiki
+func RequestArticleChanges(c *gin.Context) {
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		userID, _ := auth.CurrentUserID(c)
+		_, err = articlesvc.RequestChanges(articleID, userID, c.PostForm("note"))
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=RequestChangesFailed")
 		return
 	}
 	c.Redirect(http.StatusFound, "/manager")
@@ -1065,17 +1299,206 @@ func DenyTicket(c *gin.Context) {
 }
 
 
-// DeleteArticle: Menghapus artikel yang sudah dipublikasikan.
-// Logikanya sama dengan Deny, yaitu menghapus dari database.
+// AssignTicket: Manager meng-assign tiket masuk ke staff dari dashboard.
+func AssignTicket(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=TicketNotFound")
+		return
+	}
+	assigneeID, err := uuid.Parse(c.PostForm("assignee_id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=InvalidAssignee")
+		return
+	}
+	userID, _ := auth.CurrentUserID(c)
+
+	if _, err := ticket.Assign(ticketID, assigneeID, userID); err != nil {
+		log.Printf("[Manager] Assign ticket %s failed: %v", ticketID, err)
+		c.Redirect(http.StatusFound, "/manager?error=AssignFailed#manager-content-history")
+		return
+	}
+	c.Redirect(http.StatusFound, "/manager#manager-content-history")
+}
+
+// firstAck returns when a ticket was acknowledged: its first claim, or its first reply if never claimed
+func firstAck(claimedAt, firstResponseAt *time.Time) *time.Time {
+	if claimedAt != nil {
+		return claimedAt
+	}
+	return firstResponseAt
+}
+
+// DeleteArticle: Mengarsipkan artikel yang sudah dipublikasikan.
+// Soft delete: riwayat revisi tetap ada dan artikel bisa di-restore dari Archive.
 func DeleteArticle(c *gin.Context) {
-	id := c.Param("id")
-	if err := database.DB.Delete(&models.KnowledgeArticle{}, "id = ?", id).Error; err != nil {
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		err = articlesvc.Archive(articleID)
+	}
+	if err != nil {
 		c.Redirect(http.StatusFound, "/manager?error=DeleteFailed")
 		return
 	}
 	c.Redirect(http.StatusFound, "/manager")
 }
 
+// RestoreArticle brings an archived article back into the Big Book
+func RestoreArticle(c *gin.Context) {
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		err = articlesvc.Restore(articleID)
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=RestoreFailed")
+		return
+	}
+	c.Redirect(http.StatusFound, "/manager")
+}
+
+// PurgeArticle deletes an archived article for good, attachment files included
+func PurgeArticle(c *gin.Context) {
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		err = articlesvc.Purge(articleID)
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=PurgeFailed")
+		return
+	}
+	c.Redirect(http.StatusFound, "/manager")
+}
+
+// UploadArticleAttachment godoc
+// @Summary      Attach a file to an article
+// @Description  Upload an image (PNG, JPEG, GIF, WebP) or PDF, max 10MB. The type is checked from the file content. Reference it in the content with the returned Markdown.
+// @Tags         Manager
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     CookieAuth
+// @Param        id    path      string  true  "Article ID"
+// @Param        file  formData  file    true  "Image or PDF"
+// @Success      200  {object}  object  "Attachment with its Markdown snippet"
+// @Failure      400  {object}  object  "Unsupported file"
+// @Router       /manager/articles/{id}/attachments [post]
+func UploadArticleAttachment(c *gin.Context) {
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": articlesvc.ErrArticleNotFound.Error()})
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": upload.ErrMissing.Error()})
+		return
+	}
+
+	userID, _ := auth.CurrentUserID(c)
+	attachment, err := articlesvc.AddFormAttachment(articleID, userID, header)
+	if err != nil {
+		c.JSON(articlesvc.ErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":            attachment.ID,
+		"url":           attachment.URL,
+		"thumbnail_url": attachment.ThumbnailURL,
+		"markdown":      articlesvc.Snippet(*attachment),
+	})
+}
+
+// ArticleHistory godoc
+// @Summary      Article revision history
+// @Description  List an article's revisions and show a line diff between two of them
+// @Tags         Manager
+// @Produce      html
+// @Security     CookieAuth
+// @Param        id    path   string  true   "Article ID"
+// @Param        from  query  int     false  "Older revision (default: the one before to)"
+// @Param        to    query  int     false  "Newer revision (default: latest)"
+// @Success      200  {string}  string  "HTML page"
+// @Failure      404  {string}  string  "Article not found"
+// @Router       /manager/articles/{id}/history [get]
+func ArticleHistory(c *gin.Context) {
+	articleID, err := uuid.Parse(c.Param("id"))
+	var article models.KnowledgeArticle
+	// Archived articles keep a read-only history
+	if err != nil || database.DB.Unscoped().First(&article, "id = ?", articleID).Error != nil {
+		c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte("<h1>Article not found</h1>"))
+		return
+	}
+
+	revisions := articlesvc.Revisions(articleID)
+	to, _ := strconv.Atoi(c.Query("to"))
+	if to == 0 {
+		to = article.Revision
+	}
+	from, _ := strconv.Atoi(c.Query("from"))
+	if from == 0 {
+		from = to - 1
+	}
+
+	var diff []articlesvc.DiffLine
+	fromRev, errFrom := articlesvc.Revision(articleID, from)
+	toRev, errTo := articlesvc.Revision(articleID, to)
+	if errFrom == nil && errTo == nil {
+		diff = articlesvc.Diff(fromRev.Content, toRev.Content)
+	}
+
+	c.HTML(http.StatusOK, "manager/article_history.html", gin.H{
+		"title":     "Riwayat: " + article.Title,
+		"article":   article,
+		"revisions": revisions,
+		"from":      fromRev,
+		"to":        toRev,
+		"fromNumber": from,
+		"toNumber":   to,
+		"diff":      diff,
+		"error":     c.Query("error"),
+	})
+}
+
+// RollbackArticle restores an earlier revision as a new revision
+func RollbackArticle(c *gin.Context) {
+	back := "/manager/articles/" + c.Param("id") + "/history"
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		number, _ := strconv.Atoi(c.PostForm("revision"))
+		userID, _ := auth.CurrentUserID(c)
+		_, err = articlesvc.Rollback(articleID, number, userID)
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, back+"?error="+url.QueryEscape(err.Error()))
+		return
+	}
+	c.Redirect(http.StatusFound, back)
+}
+
+// FlagArticle marks a published article for revision and notifies its author
+func FlagArticle(c *gin.Context) {
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		err = articlesvc.FlagForRevision(articleID, c.PostForm("note"))
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=FlagFailed#manager-content-bigbook")
+		return
+	}
+	c.Redirect(http.StatusFound, "/manager#manager-content-bigbook")
+}
+
+// UnflagArticle clears the revision flag without editing the article
+func UnflagArticle(c *gin.Context) {
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		err = articlesvc.ClearRevisionFlag(database.DB, articleID)
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=UpdateFailed#manager-content-bigbook")
+		return
+	}
+	c.Redirect(http.StatusFound, "/manager#manager-content-bigbook")
+}
 
 func CreateRoutine(c *gin.Context) {
 	title := c.PostForm("title")
@@ -1091,8 +1514,8 @@ func CreateRoutine(c *gin.Context) {
 	checklistItems := c.PostFormArray("checklist_items")
 
 	// Get User (Manager)
-	userIDStr, err := c.Cookie("user_id")
-	if err != nil {
+	userID, ok := auth.CurrentUserID(c)
+	if !ok {
 		c.Redirect(http.StatusFound, "/auth/login")
 		return
 	}
@@ -1140,7 +1563,7 @@ func CreateRoutine(c *gin.Context) {
 		CronSchedule:    cron,
 		DeadlineMinutes: deadline,
 		IsActive:        true,
-		CreatedBy:       uuid.MustParse(userIDStr),
+		CreatedBy:       userID,
 		ChecklistItems:  checklistJSON,
 	}
 	
@@ -1178,3 +1601,98 @@ func ToggleRoutine(c *gin.Context) {
 	database.DB.Model(&models.RoutineTemplate{}).Where("id = ?", parsedID).Update("is_active", newActiveState)
 	c.Redirect(http.StatusFound, "/manager")
 }
+
+// CreateSLAPolicy adds a policy; empty priority/category/location match any ticket.
+// Only tickets created afterwards use it, existing due times are kept.
+func CreateSLAPolicy(c *gin.Context) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	response, errResp := strconv.Atoi(c.PostForm("response_minutes"))
+	resolution, errRes := strconv.Atoi(c.PostForm("resolution_minutes"))
+	if name == "" || errResp != nil || errRes != nil || response <= 0 || resolution <= 0 {
+		c.Redirect(http.StatusFound, "/manager?error=MissingFields")
+		return
+	}
+
+	policy := models.SLAPolicy{
+		Name:              name,
+		Priority:          c.PostForm("priority"),
+		Category:          c.PostForm("category"),
+		Location:          c.PostForm("location"),
+		ResponseMinutes:   response,
+		ResolutionMinutes: resolution,
+		BusinessHoursOnly: c.PostForm("business_hours_only") == "true",
+		BusinessStartHour: 8,
+		BusinessEndHour:   17,
+		IsActive:          true,
+	}
+	if policy.BusinessHoursOnly {
+		start, errStart := strconv.Atoi(c.PostForm("business_start_hour"))
+		end, errEnd := strconv.Atoi(c.PostForm("business_end_hour"))
+		if errStart != nil || errEnd != nil || start < 0 || end > 24 || start >= end {
+			c.Redirect(http.StatusFound, "/manager?error=InvalidTime")
+			return
+		}
+		policy.BusinessStartHour, policy.BusinessEndHour = start, end
+	}
+
+	database.DB.Create(&policy)
+	c.Redirect(http.StatusFound, "/manager")
+}
+
+// DeleteSLAPolicy removes a policy. Tickets keep their due times and recorded breaches.
+func DeleteSLAPolicy(c *gin.Context) {
+	database.DB.Delete(&models.SLAPolicy{}, "id = ?", c.Param("id"))
+	c.Redirect(http.StatusFound, "/manager")
+}
+
+// ToggleSLAPolicy switches a policy on or off for new tickets
+func ToggleSLAPolicy(c *gin.Context) {
+	parsedID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/manager?error=InvalidID")
+		return
+	}
+	database.DB.Model(&models.SLAPolicy{}).Where("id = ?", parsedID).Update("is_active", gorm.Expr("NOT is_active"))
+	c.Redirect(http.StatusFound, "/manager")
+}
+
+// RotatePushKeys replaces the web push signing key. Devices on the old key keep receiving
+// notifications during the grace period and are asked to resubscribe.
+func RotatePushKeys(c *gin.Context) {
+	if _, err := notification.RotateKeys(time.Now()); err != nil {
+		log.Printf("[Manager] Rotating VAPID keys failed: %v", err)
+		c.Redirect(http.StatusFound, "/manager?error=RotateKeysFailed")
+		return
+	}
+	c.Redirect(http.StatusFound, "/manager")
+}
+
+// PushDeliveries returns the push delivery log as JSON, newest first.
+// Query: user_id, outcome (SENT, RETRY, GONE, FAILED), since (RFC3339), limit (max 500).
+func PushDeliveries(c *gin.Context) {
+	filter := notification.DeliveryFilter{Outcome: strings.ToUpper(c.Query("outcome"))}
+	if v := c.Query("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		filter.UserID = &id
+	}
+	if v := c.Query("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since, use RFC3339"})
+			return
+		}
+		filter.Since = since
+	}
+	filter.Limit, _ = strconv.Atoi(c.Query("limit"))
+
+	entries, err := notification.Deliveries(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": entries})
+}
@@ -11,17 +11,13 @@ import (
 	notifService "it-broadcast-ops/internal/notification"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"log"
 )
 
 func RegisterRoutes(r *gin.Engine) {
-	// Panggil fungsi dari package service menggunakan alias
-	notifService.InitKeys()
-
 	// Public endpoint untuk get VAPID Key (agar frontend tahu key mana yg dipakai)
 	r.GET("/notifications/vapid-public-key", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"publicKey": notifService.VapidPublicKey})
+		c.JSON(http.StatusOK, gin.H{"publicKey": notifService.CurrentPublicKey()})
 	})
 
 	// Endpoint Subscribe (Butuh Login)
@@ -33,6 +29,8 @@ func RegisterRoutes(r *gin.Engine) {
 
 type SubscribeRequest struct {
 	Endpoint string `json:"endpoint"`
+	// VapidKey is the application server key the browser subscribed with
+	VapidKey string `json:"vapidKey"`
 	Keys     struct {
 		P256dh string `json:"p256dh"`
 		Auth   string `json:"auth"`
@@ -49,8 +47,7 @@ type SubscribeRequest struct {
 // @Failure      500  {object}  object  "Failed to send"
 // @Router       /notifications/test [post]
 func SendTestNotification(c *gin.Context) {
-    userIDStr, _ := c.Cookie("user_id")
-    userID, _ := uuid.Parse(userIDStr)
+    userID, _ := auth.CurrentUserID(c)
 
    err := notifService.SendNotificationToUser(
 		userID.String(), 
@@ -85,10 +82,9 @@ func Subscribe(c *gin.Context) {
 		return
 	}
 
-	userIDStr, _ := c.Cookie("user_id")
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid User ID"})
+	userID, ok := auth.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not logged in"})
 		return
 	}
 
@@ -100,6 +96,7 @@ func Subscribe(c *gin.Context) {
 		existing.UserID = userID
 		existing.P256dh = req.Keys.P256dh
 		existing.Auth = req.Keys.Auth
+		existing.VapidKeyID = notifService.KeyIDFor(req.VapidKey)
 		database.DB.Save(&existing)
 	} else {
 		// Create Baru
@@ -109,6 +106,7 @@ func Subscribe(c *gin.Context) {
 			Endpoint: req.Endpoint,
 			P256dh:   req.Keys.P256dh,
 			Auth:     req.Keys.Auth,
+			VapidKeyID: notifService.KeyIDFor(req.VapidKey),
 		}
 		if result := database.DB.Create(&newSub); result.Error != nil {
 			log.Printf("ERROR saving subscription: %v", result.Error)
@@ -3,31 +3,41 @@ package public
 import (
 	"crypto/rand"
 	"encoding/hex"
-	"fmt"
+	articlesvc "it-broadcast-ops/internal/article"
 	"it-broadcast-ops/internal/database"
 	"it-broadcast-ops/internal/models"
 	"it-broadcast-ops/internal/notification"
 	redisClient "it-broadcast-ops/internal/redis"
+	"it-broadcast-ops/internal/search"
+	"it-broadcast-ops/internal/sla"
+	ticketsvc "it-broadcast-ops/internal/ticket"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // Rate limit: max tickets per hour per IP
 const maxTicketsPerHour = 3
 
+// Rate limit: max deflections per hour per IP; each article counts once per IP and hour
+const maxDeflectionsPerHour = 10
+
 func RegisterRoutes(r *gin.Engine) {
 	r.GET("/report", ShowReportForm)
 	r.POST("/report", SubmitReport)
+	r.GET("/report/suggest", SuggestArticles)
+	r.POST("/report/suggest/deflect", DeflectReport)
 	r.GET("/report/success", ShowSuccess)
 	r.GET("/report/qrcode", ShowQRCode)
 	r.GET("/report/history/:token", ShowTicketHistory)
+	r.GET("/report/rate/:token", ShowRateForm)
+	r.POST("/report/rate/:token", SubmitRating)
 }
 
 // ShowReportForm godoc
@@ -57,7 +67,8 @@ func ShowReportForm(c *gin.Context) {
 // @Param        urgency      formData  string  false  "Urgency level"
 // @Param        subject      formData  string  true   "Subject"
 // @Param        description  formData  string  true   "Description"
-// @Param        proof_image  formData  file    false  "Proof image (max 5MB)"
+// @Param        proof_image  formData  file    false  "Proof image (max 20MB, PNG/JPEG/GIF/WebP)"
+// @Param        attachments  formData  file    false  "Up to 5 photos or videos (max 20MB each)"
 // @Success      302  {string}  string  "Redirect to success page"
 // @Failure      400  {string}  string  "Validation error"
 // @Failure      429  {string}  string  "Rate limit exceeded"
@@ -94,6 +105,11 @@ func SubmitReport(c *gin.Context) {
 
 	// Validation
 	errors := validateReportForm(name, email, phone, subject, description)
+	form, _ := c.MultipartForm()
+	files, err := ticketsvc.ReadFiles(ticketsvc.FormFiles(form, "proof_image", "attachments"))
+	if err != nil {
+		errors = append(errors, "Lampiran: "+err.Error())
+	}
 	if len(errors) > 0 {
 		log.Printf("[Public Report] Validation errors: %v", errors)
 		c.HTML(http.StatusBadRequest, "public/report.html", gin.H{
@@ -108,20 +124,6 @@ func SubmitReport(c *gin.Context) {
 		return
 	}
 
-	// Handle file upload
-	var proofURL string
-	file, err := c.FormFile("proof_image")
-	if err == nil && file.Size <= 5*1024*1024 {
-		ext := filepath.Ext(file.Filename)
-		filename := fmt.Sprintf("public_%d%s", time.Now().UnixNano(), ext)
-		saveDir := "web/uploads/tickets"
-		os.MkdirAll(saveDir, 0755)
-		savePath := filepath.Join(saveDir, filename)
-		if err := c.SaveUploadedFile(file, savePath); err == nil {
-			proofURL = "/uploads/tickets/" + filename
-		}
-	}
-
 	// Find or create guest user by email
 	var guestUser models.User
 	result := database.DB.Where("email = ?", email).First(&guestUser)
@@ -136,7 +138,7 @@ func SubmitReport(c *gin.Context) {
 		database.DB.Create(&guestUser)
 	}
 
-	// Generate history access token
+	// Generate access token for the reporter's status & rating link
 	historyToken := generateToken()
 
 	// Create ticket
@@ -151,12 +153,21 @@ func SubmitReport(c *gin.Context) {
 		Category:      category, // From form selection
 		Subject:       subject,
 		Description:   description + "\n\n---\nReported by: " + name + "\nPhone: " + phone + "\nEmail: " + email,
-		ProofImageURL: proofURL,
 		RequesterID:   guestUser.ID,
 		Status:        models.StatusOpen,
+		PublicToken:   historyToken,
 		CreatedAt:     time.Now(),
 	}
+	sla.Apply(&ticket)
 	database.DB.Create(&ticket)
+	// An emergency report is not lost because storing its files failed
+	if _, err := ticketsvc.AttachToReport(&ticket, guestUser.ID, files); err != nil {
+		log.Printf("[Public Report] Storing attachments failed: %v", err)
+	}
+	ticketsvc.NotifyCreated(&ticket)
+
+	// Articles offered while typing did not help
+	search.RecordIgnored(ticket.ID, c.PostFormArray("suggested_ids"), suggestionContext(c))
 
 	// Increment rate limit counter
 	if redisClient.IsConnected() {
@@ -168,16 +179,14 @@ func SubmitReport(c *gin.Context) {
 
 	// Send notification to staff
 	if priority == models.PriorityUrgentOnAir {
-		go notification.SendBroadcastToStaff(
-			"🔥 URGENT PUBLIC: "+string(ticket.Location),
-			ticket.Subject+" (QR EMERGENCY)",
-			"/staff/tickets/"+ticket.ID.String(),
-		)
+		// Paged again and escalated until someone acknowledges
+		ticketsvc.StartPaging(ticket.ID)
 	} else {
 		go notification.SendBroadcastToStaff(
 			"📱 Public Report: "+string(ticket.Location),
 			ticket.Subject,
 			"/staff/tickets/"+ticket.ID.String(),
+			notification.ForTicket(ticket.ID, ticket.Priority),
 		)
 	}
 
@@ -187,12 +196,89 @@ func SubmitReport(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/report/success?token="+historyToken+"&email="+email)
 }
 
+// SuggestArticles godoc
+// @Summary      Suggest articles for a public report
+// @Description  Top verified Big Book articles for a partially typed subject (no auth required)
+// @Tags         Public
+// @Produce      json
+// @Param        subject   query  string  false  "Subject typed so far"
+// @Param        category  query  string  false  "Category"
+// @Success      200  {array}  search.Result
+// @Router       /report/suggest [get]
+func SuggestArticles(c *gin.Context) {
+	results, err := search.Suggest(c.Query("subject"), c.Query("category"), search.SuggestLimit)
+	if err != nil {
+		log.Println("Article suggestion failed: ", err)
+		results = []search.Result{}
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// DeflectReport godoc
+// @Summary      Record a deflected public report
+// @Description  The reporter solved the problem with a suggested article instead of submitting
+// @Tags         Public
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        article_id  formData  string  true   "Accepted article ID"
+// @Param        category    formData  string  false  "Category"
+// @Param        subject     formData  string  false  "Subject typed so far"
+// @Success      200  {object}  object  "Recorded"
+// @Failure      404  {object}  object  "Article not found"
+// @Failure      429  {object}  object  "Too many deflections from this IP"
+// @Router       /report/suggest/deflect [post]
+func DeflectReport(c *gin.Context) {
+	articleID, err := uuid.Parse(c.PostForm("article_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": articlesvc.ErrArticleNotFound.Error()})
+		return
+	}
+
+	// Rate limiting check (using Redis), so the anonymous endpoint cannot inflate the stats
+	clientIP := c.ClientIP()
+	rateLimitKey := "ratelimit:deflect:" + clientIP
+	seenKey := rateLimitKey + ":" + articleID.String()
+	var count int
+	if redisClient.IsConnected() {
+		var seen bool
+		if redisClient.Get(seenKey, &seen) == nil && seen {
+			c.JSON(http.StatusOK, gin.H{"status": "ok"})
+			return
+		}
+		if err := redisClient.Get(rateLimitKey, &count); err == nil && count >= maxDeflectionsPerHour {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Terlalu banyak permintaan dari IP ini. Coba lagi dalam 1 jam."})
+			return
+		}
+	}
+
+	if err := search.RecordDeflection(articleID, suggestionContext(c)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Increment rate limit counter
+	if redisClient.IsConnected() {
+		redisClient.Set(rateLimitKey, count+1, time.Hour)
+		redisClient.Set(seenKey, true, time.Hour)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func suggestionContext(c *gin.Context) search.Suggestion {
+	return search.Suggestion{
+		Category: c.PostForm("category"),
+		Subject:  c.PostForm("subject"),
+		Source:   "public",
+	}
+}
+
 // ShowSuccess godoc
 // @Summary      Show success page
 // @Description  Display confirmation page after ticket submission
 // @Tags         Public
 // @Produce      html
 // @Param        email  query  string  false  "Reporter email"
+// @Param        token  query  string  false  "Status & rating link token"
 // @Success      200  {string}  string  "HTML page"
 // @Router       /report/success [get]
 func ShowSuccess(c *gin.Context) {
@@ -200,6 +286,59 @@ func ShowSuccess(c *gin.Context) {
 	c.HTML(http.StatusOK, "public/success.html", gin.H{
 		"title": "Laporan Terkirim",
 		"email": email,
+		"token": c.Query("token"),
+	})
+}
+
+// ShowRateForm godoc
+// @Summary      Show ticket status & rating form
+// @Description  Public reporters follow their tokenized link to see the ticket status and rate it once finished
+// @Tags         Public
+// @Produce      html
+// @Param        token  path  string  true  "Access token"
+// @Success      200  {string}  string  "HTML page"
+// @Failure      404  {string}  string  "Invalid link"
+// @Router       /report/rate/{token} [get]
+func ShowRateForm(c *gin.Context) {
+	renderRatePage(c, http.StatusOK, "")
+}
+
+// SubmitRating godoc
+// @Summary      Submit rating
+// @Description  Store a CSAT score (1-5) and comment for the ticket behind the token
+// @Tags         Public
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Param        token    path      string  true   "Access token"
+// @Param        score    formData  int     true   "Score 1-5"
+// @Param        comment  formData  string  false  "Comment"
+// @Success      200  {string}  string  "HTML page"
+// @Failure      400  {string}  string  "Validation error"
+// @Router       /report/rate/{token} [post]
+func SubmitRating(c *gin.Context) {
+	score, _ := strconv.Atoi(c.PostForm("score"))
+	if _, err := ticketsvc.RateByToken(c.Param("token"), score, c.PostForm("comment")); err != nil {
+		renderRatePage(c, ticketsvc.ErrorStatus(err), err.Error())
+		return
+	}
+	renderRatePage(c, http.StatusOK, "")
+}
+
+func renderRatePage(c *gin.Context, status int, errMsg string) {
+	t, err := ticketsvc.TicketByToken(c.Param("token"))
+	if err != nil {
+		c.HTML(http.StatusNotFound, "public/rate.html", gin.H{
+			"title": "Penilaian Layanan",
+			"error": err.Error(),
+		})
+		return
+	}
+	c.HTML(status, "public/rate.html", gin.H{
+		"title":   "Penilaian Layanan",
+		"ticket":  t,
+		"rating":  ticketsvc.RatingFor(t.ID),
+		"canRate": ticketsvc.CanRate(t),
+		"error":   errMsg,
 	})
 }
 
@@ -2,14 +2,18 @@ package staff
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
-	"path/filepath"
+	"net/url"
+	articlesvc "it-broadcast-ops/internal/article"
 	"it-broadcast-ops/internal/auth"
 	"it-broadcast-ops/internal/database"
 	"it-broadcast-ops/internal/models"
 	redisClient "it-broadcast-ops/internal/redis"
+	"it-broadcast-ops/internal/search"
+	"it-broadcast-ops/internal/ticket"
+	"it-broadcast-ops/internal/upload"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -25,16 +29,15 @@ import (
 // @Success      200  {string}  string  "HTML page"
 // @Router       /staff/history [get]
 func History(c *gin.Context) {
-	userIDStr, err := c.Cookie("user_id")
-    if err != nil || userIDStr == "" {
-        c.Redirect(http.StatusFound, "/auth/login")
-        return
-    }
-    userID, _ := uuid.Parse(userIDStr)
+	userID, ok := auth.CurrentUserID(c)
+	if !ok {
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
 
 	var urgentCount int64
 	database.DB.Model(&models.Ticket{}).
-		Where("priority = ? AND status != ?", models.PriorityUrgentOnAir, models.StatusResolved).
+		Where("priority = ? AND status IN ?", models.PriorityUrgentOnAir, activeStatuses).
 		Count(&urgentCount)
 	
 	var ticketIDs []uuid.UUID
@@ -59,19 +62,32 @@ func History(c *gin.Context) {
 
 func RegisterRoutes(r *gin.Engine) {
 	staffGroup := r.Group("/staff")
-	staffGroup.Use(auth.AuthRequired(), auth.RoleRequired(models.RoleStaff))
+	// Managers can open staff pages too (e.g. to follow up a ticket)
+	staffGroup.Use(auth.AuthRequired(), auth.RoleRequired(models.RoleStaff, models.RoleManager))
 	{
 		staffGroup.GET("", Dashboard)
 		staffGroup.GET("/history", History)
 		staffGroup.GET("/tickets/list", TicketList) // HTMX Partial
 		staffGroup.GET("/tickets/:id", TicketDetail)
+		staffGroup.POST("/tickets/:id/claim", ClaimTicket)
+		staffGroup.POST("/tickets/:id/assign", AssignTicket)
+		staffGroup.POST("/tickets/:id/unassign", UnassignTicket)
 		staffGroup.POST("/tickets/:id/handover", HandoverTicket)
+		staffGroup.POST("/tickets/:id/handover/ack", AcknowledgeHandover)
+		staffGroup.POST("/tickets/:id/ack", AcknowledgePage)
+		staffGroup.GET("/handover/report", HandoverReport)
 		staffGroup.POST("/tickets/:id/resolve", ResolveTicket)
 		staffGroup.POST("/routine/:id/toggle", ToggleRoutineItem)
 		staffGroup.POST("/tickets/:id/reply", ReplyTicket) 
 		staffGroup.GET("/bigbook", BigBook)
 		staffGroup.GET("/bigbook/search", SearchBigBookJSON)
 		staffGroup.GET("/articles/:id", ArticleDetail)
+		staffGroup.POST("/articles/:id/vote", VoteArticle)
+		staffGroup.POST("/tickets/:id/draft", DraftFromTicket)
+		staffGroup.GET("/articles/:id/edit", EditDraft)
+		staffGroup.POST("/articles/:id/draft", SaveDraft)
+		staffGroup.POST("/articles/:id/attachments", UploadAttachment)
+		staffGroup.POST("/articles/:id/attachments/:attachmentID/delete", DeleteAttachment)
 		staffGroup.GET("/profile", Profile)
 		staffGroup.GET("/alerts", Alerts)
 		staffGroup.POST("/profile/update", UpdateProfile)
@@ -91,31 +107,38 @@ func Dashboard(c *gin.Context) {
 	database.DB.Model(&models.Ticket{}).Where("status IN ?", []models.TicketStatus{models.StatusOpen, models.StatusInProgress}).Count(&openTicketsCount)
 
 	var urgentCount int64
-	database.DB.Model(&models.Ticket{}).Where("priority = ? AND status != ?", models.PriorityUrgentOnAir, models.StatusResolved).Count(&urgentCount)
+	database.DB.Model(&models.Ticket{}).Where("priority = ? AND status IN ?", models.PriorityUrgentOnAir, activeStatuses).Count(&urgentCount)
+
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
 
 	// Fetch Active Tickets (Sama logic dengan TicketList)
-	var activeTickets []models.Ticket
-	database.DB.Where("status IN ?", []models.TicketStatus{models.StatusOpen, models.StatusInProgress, models.StatusHandover}).
-		Preload("Requester").
-		Order("case when priority = 'URGENT_ON_AIR' then 1 else 2 end, created_at asc").
-		Find(&activeTickets)
+	filter := ticketFilter(c.Query("filter"))
+	activeTickets := activeTickets(filter, user.ID)
 
-	userIDStr, err := c.Cookie("user_id")
-    if err != nil || userIDStr == "" {
-        c.Redirect(http.StatusFound, "/auth/login")
-        return
-    }
-var routineInstances []models.RoutineInstance
-    database.DB.Preload("Template").Where("assigned_user_id = ? AND status = 'PENDING'", userIDStr).Find(&routineInstances)
+	var myTicketsCount, unassignedCount int64
+	database.DB.Model(&models.Ticket{}).Where("status IN ? AND current_assignee_id = ?", activeStatuses, user.ID).Count(&myTicketsCount)
+	database.DB.Model(&models.Ticket{}).Where("status IN ? AND current_assignee_id IS NULL", activeStatuses).Count(&unassignedCount)
 
-	var user models.User
-	database.DB.First(&user, "id = ?", userIDStr)
+	var pendingHandovers int64
+	database.DB.Model(&models.TicketHandover{}).
+		Where("acknowledged_at IS NULL AND from_user_id <> ? AND (to_user_id = ? OR to_user_id IS NULL)", user.ID, user.ID).
+		Count(&pendingHandovers)
+	var routineInstances []models.RoutineInstance
+	database.DB.Preload("Template").
+		Where("assigned_user_id = ? AND status IN ?", user.ID, []string{models.RoutinePending, models.RoutineOverdue}).
+		Order("due_at asc").
+		Find(&routineInstances)
 
 	type RoutineView struct {
-		ID       uuid.UUID
-		Title    string
-		DueTime  string
-		Items    map[string]bool
+		ID        uuid.UUID
+		Title     string
+		DueTime   string
+		IsOverdue bool
+		Items     map[string]bool
 	}
 	var routineViews []RoutineView
 	
@@ -128,8 +151,9 @@ var routineInstances []models.RoutineInstance
 		routineViews = append(routineViews, RoutineView{
 			ID:      r.ID,
 			Title:   r.Template.Title,
-			DueTime: r.DueAt.Format("15:04"),
-			Items:   items,
+			DueTime:   r.DueAt.Format("15:04"),
+			IsOverdue: r.Status == models.RoutineOverdue,
+			Items:     items,
 		})
 	}
 
@@ -138,6 +162,10 @@ var routineInstances []models.RoutineInstance
 		"ticketCount":  openTicketsCount,
 		"urgentCount":  urgentCount,
 		"tickets":      activeTickets, // Kirim tiket awal agar tidak kosong saat load pertama
+		"filter":       filter,
+		"myCount":      myTicketsCount,
+		"poolCount":    unassignedCount,
+		"handoverCount": pendingHandovers,
 		"routines":     routineViews,
 		"user":         user,
 	})
@@ -149,46 +177,306 @@ var routineInstances []models.RoutineInstance
 // @Tags         Staff
 // @Produce      html
 // @Security     CookieAuth
+// @Param        filter  query  string  false  "all (default), mine or unassigned"
 // @Success      200  {string}  string  "HTML partial"
 // @Router       /staff/tickets/list [get]
 func TicketList(c *gin.Context) {
-	var activeTickets []models.Ticket
-	database.DB.Where("status IN ?", []models.TicketStatus{models.StatusOpen, models.StatusInProgress, models.StatusHandover}).
-		Preload("Requester").
-		Order("case when priority = 'URGENT_ON_AIR' then 1 else 2 end, created_at asc").
-		Find(&activeTickets)
+	userID, _ := auth.CurrentUserID(c)
 
     // Render file partial yang baru dibuat
     c.HTML(http.StatusOK, "staff/ticket_list_partial.html", gin.H{
-        "tickets": activeTickets,
+        "tickets": activeTickets(ticketFilter(c.Query("filter")), userID),
     })
 }
 
-func HandoverTicket(c *gin.Context) {
-	id := c.Param("id")
-	note := c.PostForm("note")
+var activeStatuses = []models.TicketStatus{models.StatusOpen, models.StatusInProgress, models.StatusHandover}
 
-	userIDStr, _ := c.Cookie("user_id")
-	userID, _ := uuid.Parse(userIDStr)
+// finishedStatuses are tickets whose solution can be reused in the Big Book
+var finishedStatuses = []models.TicketStatus{models.StatusResolved, models.StatusClosed}
 
-	// Create Ticket Activity (Handover)
-	activity := models.TicketActivity{
-		TicketID:   uuid.MustParse(id),
-		ActorID:    userID,
-		ActionType: "HANDOVER",
-		Note:       note,
+// ticketFilter normalises the ?filter= tab of the ticket list
+func ticketFilter(filter string) string {
+	switch filter {
+	case "mine", "unassigned":
+		return filter
+	default:
+		return "all"
 	}
-	database.DB.Create(&activity)
+}
 
-	// Update Ticket Status only
-	database.DB.Model(&models.Ticket{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":      models.StatusHandover,
-		"is_handover": true,
-	})
+// activeTickets returns open tickets for the dashboard list, urgent first.
+// "mine" = assigned to userID, "unassigned" = nobody has claimed it yet.
+func activeTickets(filter string, userID uuid.UUID) []models.Ticket {
+	db := database.DB.Where("status IN ?", activeStatuses)
+	switch filter {
+	case "mine":
+		db = db.Where("current_assignee_id = ?", userID)
+	case "unassigned":
+		db = db.Where("current_assignee_id IS NULL")
+	}
+
+	var tickets []models.Ticket
+	db.Preload("Requester").
+		Preload("CurrentAssignee").
+		Order("case when priority = 'URGENT_ON_AIR' then 1 else 2 end, created_at asc").
+		Find(&tickets)
+	return tickets
+}
+
+// ClaimTicket godoc
+// @Summary      Claim ticket
+// @Description  Take an unassigned ticket. Fails with 409 if someone else claimed it first.
+// @Tags         Staff
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id  path  string  true  "Ticket ID"
+// @Success      302  {string}  string  "Redirect to ticket detail"
+// @Failure      409  {object}  map[string]string
+// @Router       /staff/tickets/{id}/claim [post]
+func ClaimTicket(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ticketActionResponse(c, nil, ticket.ErrTicketNotFound)
+		return
+	}
+	userID, _ := auth.CurrentUserID(c)
+
+	t, err := ticket.Claim(ticketID, userID)
+	ticketActionResponse(c, t, err)
+}
+
+// AssignTicket godoc
+// @Summary      Assign ticket
+// @Description  Assign or reassign a ticket to a staff member (managers, or the current assignee)
+// @Tags         Staff
+// @Accept       x-www-form-urlencoded
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id           path      string  true  "Ticket ID"
+// @Param        assignee_id  formData  string  true  "Staff user ID"
+// @Success      302  {string}  string  "Redirect to ticket detail"
+// @Failure      403  {object}  map[string]string
+// @Router       /staff/tickets/{id}/assign [post]
+func AssignTicket(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ticketActionResponse(c, nil, ticket.ErrTicketNotFound)
+		return
+	}
+	assigneeID, err := uuid.Parse(c.PostForm("assignee_id"))
+	if err != nil {
+		ticketActionResponse(c, nil, ticket.ErrInvalidAssignee)
+		return
+	}
+	userID, _ := auth.CurrentUserID(c)
+
+	t, err := ticket.Assign(ticketID, assigneeID, userID)
+	ticketActionResponse(c, t, err)
+}
+
+// UnassignTicket godoc
+// @Summary      Unassign ticket
+// @Description  Return a ticket to the unassigned pool (current assignee or manager)
+// @Tags         Staff
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id  path  string  true  "Ticket ID"
+// @Success      302  {string}  string  "Redirect to ticket detail"
+// @Failure      403  {object}  map[string]string
+// @Router       /staff/tickets/{id}/unassign [post]
+func UnassignTicket(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ticketActionResponse(c, nil, ticket.ErrTicketNotFound)
+		return
+	}
+	userID, _ := auth.CurrentUserID(c)
+
+	t, err := ticket.Unassign(ticketID, userID)
+	ticketActionResponse(c, t, err)
+}
+
+// ticketActionResponse answers ticket actions (claim, assign, handover, ...): JSON for fetch/HTMX, otherwise back to the ticket page
+func ticketActionResponse(c *gin.Context, t *models.Ticket, err error) {
+	back := "/staff/tickets/" + c.Param("id")
+	if err != nil {
+		if auth.WantsJSON(c) {
+			c.JSON(ticket.ErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Redirect(http.StatusFound, back+"?error="+url.QueryEscape(err.Error()))
+		return
+	}
+
+	if auth.WantsJSON(c) {
+		c.JSON(http.StatusOK, gin.H{"id": t.ID, "current_assignee_id": t.CurrentAssigneeID})
+		return
+	}
+	c.Redirect(http.StatusFound, back)
+}
+
+// HandoverTicket godoc
+// @Summary      Handover ticket
+// @Description  Pass a ticket to a colleague, the next shift or the general pool. The recipient must acknowledge it.
+// @Tags         Staff
+// @Accept       x-www-form-urlencoded
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id          path      string  true   "Ticket ID"
+// @Param        target      formData  string  true   "USER, NEXT_SHIFT or POOL"
+// @Param        to_user_id  formData  string  false  "Colleague ID (target USER)"
+// @Param        note        formData  string  true   "Handover note"
+// @Success      302  {string}  string  "Redirect to dashboard"
+// @Failure      409  {object}  map[string]string
+// @Router       /staff/tickets/{id}/handover [post]
+func HandoverTicket(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ticketActionResponse(c, nil, ticket.ErrTicketNotFound)
+		return
+	}
+	req := ticket.HandoverRequest{
+		Target: c.DefaultPostForm("target", models.HandoverToPool),
+		Note:   c.PostForm("note"),
+	}
+	if req.Target == models.HandoverToUser {
+		toUserID, err := uuid.Parse(c.PostForm("to_user_id"))
+		if err != nil {
+			ticketActionResponse(c, nil, ticket.ErrHandoverTarget)
+			return
+		}
+		req.ToUserID = &toUserID
+	}
+
+	userID, _ := auth.CurrentUserID(c)
+	if _, err := ticket.Handover(ticketID, userID, req); err != nil {
+		ticketActionResponse(c, nil, err)
+		return
+	}
 
+	if auth.WantsJSON(c) {
+		c.JSON(http.StatusOK, gin.H{"status": models.StatusHandover})
+		return
+	}
 	c.Redirect(http.StatusFound, "/staff")
 }
 
+// AcknowledgePage godoc
+// @Summary      Acknowledge urgent page
+// @Description  Answer the page of an URGENT_ON_AIR ticket, which stops repeating and escalating it. Called from the notification's action button.
+// @Tags         Staff
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id  path  string  true  "Ticket ID"
+// @Success      302  {string}  string  "Redirect to ticket detail"
+// @Failure      409  {object}  map[string]string
+// @Router       /staff/tickets/{id}/ack [post]
+func AcknowledgePage(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ticketActionResponse(c, nil, ticket.ErrTicketNotFound)
+		return
+	}
+	userID, _ := auth.CurrentUserID(c)
+
+	t, err := ticket.Acknowledge(ticketID, userID)
+	ticketActionResponse(c, t, err)
+}
+
+// AcknowledgeHandover godoc
+// @Summary      Acknowledge handover
+// @Description  Accept a pending handover; the ticket moves back to IN_PROGRESS with the caller as assignee
+// @Tags         Staff
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id  path  string  true  "Ticket ID"
+// @Success      302  {string}  string  "Redirect to ticket detail"
+// @Failure      403  {object}  map[string]string
+// @Router       /staff/tickets/{id}/handover/ack [post]
+func AcknowledgeHandover(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ticketActionResponse(c, nil, ticket.ErrTicketNotFound)
+		return
+	}
+	userID, _ := auth.CurrentUserID(c)
+
+	if _, err := ticket.AcknowledgeHandover(ticketID, userID); err != nil {
+		ticketActionResponse(c, nil, err)
+		return
+	}
+	if auth.WantsJSON(c) {
+		c.JSON(http.StatusOK, gin.H{"status": models.StatusInProgress, "current_assignee_id": userID})
+		return
+	}
+	c.Redirect(http.StatusFound, "/staff/tickets/"+ticketID.String())
+}
+
+// HandoverReport godoc
+// @Summary      End-of-shift handover report
+// @Description  Everything still open that the staff member owns, plus handovers waiting on either side
+// @Tags         Staff
+// @Produce      html
+// @Security     CookieAuth
+// @Param        user_id  query  string  false  "Staff ID (managers only, defaults to self)"
+// @Success      200  {string}  string  "HTML page"
+// @Router       /staff/handover/report [get]
+func HandoverReport(c *gin.Context) {
+	viewer, ok := auth.CurrentUser(c)
+	if !ok {
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
+
+	// Managers can look at anyone's report
+	owner := *viewer
+	if id, err := uuid.Parse(c.Query("user_id")); err == nil && id != viewer.ID && viewer.Role == models.RoleManager {
+		if err := database.DB.First(&owner, "id = ?", id).Error; err != nil {
+			c.String(http.StatusNotFound, "User not found")
+			return
+		}
+	}
+
+	now := time.Now()
+	var currentShift models.Shift
+	hasShift := database.DB.Where("user_id = ? AND start_time <= ? AND end_time > ?", owner.ID, now, now).
+		First(&currentShift).Error == nil
+	nextShift, _ := ticket.NextShift(database.DB, owner.ID, now)
+
+	var openTickets []models.Ticket
+	database.DB.Preload("Requester").
+		Where("status IN ? AND current_assignee_id = ?", []models.TicketStatus{models.StatusOpen, models.StatusInProgress}, owner.ID).
+		Order("case when priority = 'URGENT_ON_AIR' then 1 else 2 end, created_at asc").
+		Find(&openTickets)
+
+	// Handovers sent by this person still waiting for the recipient
+	var outgoing []models.TicketHandover
+	database.DB.Preload("Ticket").Preload("ToUser").
+		Where("from_user_id = ? AND acknowledged_at IS NULL", owner.ID).
+		Order("created_at desc").
+		Find(&outgoing)
+
+	// Handovers waiting for this person (named, or the pool)
+	var incoming []models.TicketHandover
+	database.DB.Preload("Ticket").Preload("FromUser").
+		Where("acknowledged_at IS NULL AND from_user_id <> ? AND (to_user_id = ? OR to_user_id IS NULL)", owner.ID, owner.ID).
+		Order("created_at asc").
+		Find(&incoming)
+
+	c.HTML(http.StatusOK, "staff/handover_report.html", gin.H{
+		"title":        "Laporan Handover",
+		"owner":        owner,
+		"isSelf":       owner.ID == viewer.ID,
+		"hasShift":     hasShift,
+		"currentShift": currentShift,
+		"nextShift":    nextShift,
+		"tickets":      openTickets,
+		"outgoing":     outgoing,
+		"incoming":     incoming,
+		"generatedAt":  now,
+	})
+}
+
 func ToggleRoutineItem(c *gin.Context) {
 	id := c.Param("id")
 	item := c.Query("item")
@@ -199,8 +487,17 @@ func ToggleRoutineItem(c *gin.Context) {
 		return
 	}
 	
+	// MISSED routines are final (already escalated to managers)
+	if routine.Status == models.RoutineMissed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Routine sudah terlewat (MISSED)"})
+		return
+	}
+
 	var items map[string]bool
 	json.Unmarshal(routine.ChecklistState, &items)
+	if items == nil {
+		items = map[string]bool{}
+	}
 	
 	// Toggle
 	items[item] = !items[item] // If not exists, becomes true (which is weird, but items should exist from seed)
@@ -216,13 +513,17 @@ func ToggleRoutineItem(c *gin.Context) {
             break
         }
     }
-    status := "PENDING"
+	// Unchecking a completed routine puts it back to PENDING, or OVERDUE if the deadline has passed
+	now := time.Now()
+	status := models.RoutinePending
+	if now.After(routine.DueAt) {
+		status = models.RoutineOverdue
+	}
 	var completedAt *time.Time
-    if allDone {
-        status = "COMPLETED"
-		now := time.Now()
+	if allDone {
+		status = models.RoutineCompleted
 		completedAt = &now
-    }
+	}
 
 	database.DB.Model(&routine).Updates(map[string]interface{}{
         "checklist_state": newState,
@@ -245,8 +546,8 @@ func ToggleRoutineItem(c *gin.Context) {
 // @Router       /staff/tickets/{id} [get]
 func TicketDetail(c *gin.Context) {
 	id := c.Param("id")
-	var ticket models.Ticket
-	if err := database.DB.Preload("Requester").First(&ticket, "id = ?", id).Error; err != nil {
+	var t models.Ticket
+	if err := database.DB.Preload("Requester").Preload("CurrentAssignee").First(&t, "id = ?", id).Error; err != nil {
 		c.String(404, "Ticket not found")
 		return
 	}
@@ -257,10 +558,35 @@ func TicketDetail(c *gin.Context) {
 		Where("ticket_id = ?", id).
 		Order("created_at asc").
 		Find(&activities)
+
+	// Files sent with the report and, keyed by activity ID, with chat messages
+	reportFiles, chatFiles := ticket.Attachments(t.ID)
+	activityFiles := make(map[string][]models.TicketAttachment, len(chatFiles))
+	for activityID, files := range chatFiles {
+		activityFiles[activityID.String()] = files
+	}
+
+	user, _ := auth.CurrentUser(c)
+	isAssignee := t.CurrentAssigneeID != nil && *t.CurrentAssigneeID == user.ID
+
+	// Pool handovers can be accepted by anyone, named ones by the recipient or a manager
+	handover := ticket.PendingHandover(t.ID)
+	canAck := handover != nil && (handover.ToUserID == nil || *handover.ToUserID == user.ID || user.Role == models.RoleManager)
 	
 	c.HTML(http.StatusOK, "staff/ticket_detail.html", gin.H{
-		"ticket":     ticket,
-		"activities": activities,
+		"ticket":        t,
+		"activities":    activities,
+		"user":          user,
+		"isAssignee":    isAssignee,
+		"canReassign":   ticket.CanReassign(&t, user),
+		"staffList":     ticket.AssignableStaff(),
+		"handover":      handover,
+		"canAck":        canAck,
+		"paging":        ticket.IsPaging(&t),
+		"rating":        ticket.RatingFor(t.ID),
+		"error":         c.Query("error"),
+		"reportFiles":   reportFiles,
+		"activityFiles": activityFiles,
 	})
 }
 
@@ -277,27 +603,17 @@ func TicketDetail(c *gin.Context) {
 // @Success      302  {string}  string  "Redirect to dashboard"
 // @Router       /staff/tickets/{id}/resolve [post]
 func ResolveTicket(c *gin.Context) {
-	id := c.Param("id")
-	solution := c.PostForm("solution")
-	now := time.Now()
-	
-	// Create Activity for Resolution
-	userIDStr, _ := c.Cookie("user_id")
-	userID, _ := uuid.Parse(userIDStr)
-	
-	activity := models.TicketActivity{
-		TicketID:   uuid.MustParse(id),
-		ActorID:    userID,
-		ActionType: "RESOLVE",
-		Note:       "Ticket Resolved. Solution: " + solution,
-	}
-	database.DB.Create(&activity)
-
-	database.DB.Model(&models.Ticket{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":      models.StatusResolved,
-		"resolved_at": now,
-		"solution":    solution,
-	})
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		ticketActionResponse(c, nil, ticket.ErrTicketNotFound)
+		return
+	}
+	userID, _ := auth.CurrentUserID(c)
+
+	if _, err := ticket.Resolve(ticketID, userID, c.PostForm("solution")); err != nil {
+		ticketActionResponse(c, nil, err)
+		return
+	}
 	
 	c.Redirect(http.StatusFound, "/staff")
 }
@@ -313,38 +629,59 @@ func ResolveTicket(c *gin.Context) {
 // @Router       /staff/bigbook [get]
 func BigBook(c *gin.Context) {
 	query := c.Query("q")
-	var articles []models.KnowledgeArticle
-	
-	db := database.DB.Model(&models.KnowledgeArticle{}).Preload("Author")
-	if query != "" {
-		db = db.Where("title ILIKE ? OR content ILIKE ?", "%"+query+"%", "%"+query+"%")
+
+	results, err := search.Search(query, search.Limit)
+	if err != nil {
+		log.Println("Big Book search failed: ", err)
 	}
-	db.Find(&articles)
-	
-	// Also fetch Resolved Tickets with Solutions as "Candidates"
-	var candidates []models.Ticket
-	if query != "" {
-		database.DB.Model(&models.Ticket{}).
-			Where("status = ? AND solution != '' AND (subject ILIKE ? OR solution ILIKE ?)", models.StatusResolved, "%"+query+"%", "%"+query+"%").
-			Limit(5).Find(&candidates)
-	} else {
+
+	// Ranked together, shown in two sections: official articles and solutions from tickets
+	var articles, candidates []search.Result
+	for _, r := range results {
+		if r.Type == search.TypeTicket {
+			candidates = append(candidates, r)
+		} else {
+			articles = append(articles, r)
+		}
+	}
+
+	// Without a query, show the latest solved tickets as candidates
+	if query == "" {
+		var tickets []models.Ticket
 		database.DB.Model(&models.Ticket{}).
-			Where("status = ? AND solution != ''", models.StatusResolved).
-			Limit(5).Find(&candidates)
+			Where("status IN ? AND solution != ''", finishedStatuses).
+			Order("resolved_at desc").
+			Limit(5).Find(&tickets)
+		for _, t := range tickets {
+			candidates = append(candidates, search.Result{
+				ID:           t.ID,
+				Type:         search.TypeTicket,
+				Title:        t.Subject,
+				Content:      t.Solution,
+				TicketNumber: t.TicketNumber,
+				ResolvedAt:   t.ResolvedAt,
+			})
+		}
 	}
 
+	userID, _ := auth.CurrentUserID(c)
+
 	c.HTML(http.StatusOK, "staff/bigbook.html", gin.H{
 		"title":      "Big Book (Knowledge Base)",
 		"articles":   articles,
 		"candidates": candidates,
+		"drafts":     articlesvc.Drafts(userID),
 		"query":      query,
+		"error":      c.Query("error"),
 	})
 }
 
 func Profile(c *gin.Context) {
-	userIDStr, _ := c.Cookie("user_id")
-	var user models.User
-	database.DB.First(&user, "id = ?", userIDStr)
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
 	
 	c.HTML(http.StatusOK, "staff/profile.html", gin.H{
 		"title": "My Profile",
@@ -355,7 +692,7 @@ func Profile(c *gin.Context) {
 
 func Alerts(c *gin.Context) {
 	var urgentTickets []models.Ticket
-	database.DB.Where("priority = ? AND status != ?", models.PriorityUrgentOnAir, models.StatusResolved).
+	database.DB.Where("priority = ? AND status IN ?", models.PriorityUrgentOnAir, activeStatuses).
 		Preload("Requester").
 		Order("created_at desc").
 		Find(&urgentTickets)
@@ -368,7 +705,7 @@ func Alerts(c *gin.Context) {
 
 // SearchBigBookJSON godoc
 // @Summary      Search knowledge base JSON
-// @Description  Search Big Book articles and return JSON results
+// @Description  Full-text search over Big Book articles and solved tickets, best match first
 // @Tags         Staff
 // @Produce      json
 // @Security     CookieAuth
@@ -376,58 +713,19 @@ func Alerts(c *gin.Context) {
 // @Success      200  {array}  object  "Search results"
 // @Router       /staff/bigbook/search [get]
 func SearchBigBookJSON(c *gin.Context) {
-	query := c.Query("q")
-	var results []map[string]interface{}
-	
-	// 1. Articles
-	var articles []models.KnowledgeArticle
-	db := database.DB.Model(&models.KnowledgeArticle{})
-
-	if query != "" {
-		db = db.Where("title ILIKE ? OR content ILIKE ?", "%"+query+"%", "%"+query+"%")
-	} else {
-		// Default sort alphabetical
-		db = db.Order("title ASC")
-	}
-	
-	// Limit to 20 to avoid heavy load on dropdown
-	db.Limit(20).Find(&articles)
-
-	for _, a := range articles {
-		results = append(results, map[string]interface{}{
-			"ID": a.ID,
-			"Title": a.Title,
-			"Category": a.Category,
-			"Content": a.Content,
-			"Type": "Article",
-		})
-	}
-
-	// 2. Candidate Tickets (Hanya jika ada query search, supaya list A-Z artikel tidak tercampur bising)
-	if query != "" {
-		var tickets []models.Ticket
-		database.DB.Where("status = ? AND solution != '' AND (subject ILIKE ? OR solution ILIKE ?)", models.StatusResolved, "%"+query+"%", "%"+query+"%").
-			Limit(5).Find(&tickets)
-		
-		for _, t := range tickets {
-			results = append(results, map[string]interface{}{
-				"ID": t.ID,
-				"Title": t.Subject,
-				"Category": "Ticket Solution",
-				"Content": t.Solution,
-				"Type": "Ticket",
-			})
-		}
+	results, err := search.Search(c.Query("q"), search.Limit)
+	if err != nil {
+		log.Println("Big Book search failed: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Pencarian gagal"})
+		return
 	}
-	
 	c.JSON(200, results)
 }
 
 
 func UpdateProfile(c *gin.Context) {
-	userIDStr, _ := c.Cookie("user_id")
-	var user models.User
-	if err := database.DB.First(&user, "id = ?", userIDStr).Error; err != nil {
+	user, ok := auth.CurrentUser(c)
+	if !ok {
 		c.Redirect(http.StatusFound, "/auth/login")
 		return
 	}
@@ -437,35 +735,25 @@ func UpdateProfile(c *gin.Context) {
 		user.FullName = fullname
 	}
 
-	file, err := c.FormFile("avatar")
-	if err == nil {
-		// Validation: Max 2MB
-		if file.Size > 2*1024*1024 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 2MB)"})
-			return
-		}
-
-		// Handle Avatar Upload
-		ext := filepath.Ext(file.Filename)
-		// If blob is sent as "avatar.jpg", ext will be ".jpg"
-		if ext == "" {
-			ext = ".jpg" 
-		}
-		
-		// Use timestamp to assume uniqueness/busting cache? Or just UUID
-		filename := fmt.Sprintf("%s_%d%s", user.ID.String(), time.Now().Unix(), ext)
-		savePath := filepath.Join("web/uploads/avatars", filename)
-		
-		if err := c.SaveUploadedFile(file, savePath); err == nil {
-			// URL path (served via /uploads route)
-			user.AvatarURL = "/uploads/avatars/" + filename
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+	previousAvatar := user.AvatarURL
+	if file, err := c.FormFile("avatar"); err == nil {
+		avatarURL, err := upload.Save(file, "avatars", upload.AvatarPolicy)
+		if err != nil {
+			c.JSON(upload.ErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
+		user.AvatarURL = avatarURL
 	}
 
-	database.DB.Save(&user)
+	if err := database.DB.Save(user).Error; err != nil {
+		upload.Remove(user.AvatarURL)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan profil"})
+		return
+	}
+	// The previous picture is no longer referenced anywhere
+	if user.AvatarURL != previousAvatar {
+		upload.Remove(previousAvatar)
+	}
 	c.Status(http.StatusOK)
 }
 
@@ -478,41 +766,277 @@ func ArticleDetail(c *gin.Context) {
 		return
 	}
 
-	// Increment View Count
-	database.DB.Model(&article).UpdateColumn("views_count", article.ViewsCount+1)
+	// Count the view once per reader per day
+	userID, _ := auth.CurrentUserID(c)
+	if counted, err := articlesvc.RecordView(article.ID, userID, time.Now()); err != nil {
+		log.Println("Recording article view failed: ", err)
+	} else if counted {
+		article.ViewsCount++
+	}
 
 	c.HTML(http.StatusOK, "staff/article_detail.html", gin.H{
-		"title":   article.Title,
-		"article": article,
+		"title":       article.Title,
+		"article":     article,
+		"rendered":    articlesvc.Render(&article),
+		"attachments": articlesvc.Attachments(article.ID),
+		"vote":        articlesvc.VoteOf(article.ID, userID),
+		"voted":       c.Query("voted") != "",
+		"error":       c.Query("error"),
 	})
 }
 
+// VoteArticle godoc
+// @Summary      Vote on an article
+// @Description  Answer "was this helpful" for a Big Book article. One vote per user; voting again replaces it.
+// @Tags         Staff
+// @Accept       x-www-form-urlencoded
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id       path      string  true   "Article ID"
+// @Param        helpful  formData  bool    true   "true if the article helped"
+// @Param        comment  formData  string  false  "What was missing (not helpful votes only)"
+// @Success      302  {string}  string  "Redirect back to the article"
+// @Failure      404  {object}  object  "Article not found"
+// @Router       /staff/articles/{id}/vote [post]
+func VoteArticle(c *gin.Context) {
+	back := "/staff/articles/" + c.Param("id")
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err == nil {
+		userID, _ := auth.CurrentUserID(c)
+		helpful, _ := strconv.ParseBool(c.PostForm("helpful"))
+		_, err = articlesvc.Vote(articleID, userID, helpful, c.PostForm("comment"))
+	} else {
+		err = articlesvc.ErrArticleNotFound
+	}
 
-func ReplyTicket(c *gin.Context) {
-	id := c.Param("id")
-	message := c.PostForm("message")
-	
-	if message == "" {
-		c.Redirect(http.StatusFound, "/staff/tickets/"+id)
+	if auth.WantsJSON(c) {
+		if err != nil {
+			c.JSON(articlesvc.ErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+		return
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, back+"?error="+url.QueryEscape(err.Error()))
 		return
 	}
+	c.Redirect(http.StatusFound, back+"?voted=1")
+}
 
-	userIDStr, _ := c.Cookie("user_id")
-	userID, _ := uuid.Parse(userIDStr)
+// DraftFromTicket godoc
+// @Summary      Draft an article from a ticket
+// @Description  Start a Big Book draft prefilled from a resolved ticket's subject, description and solution. Returns your existing draft if you already drafted the ticket; a ticket drafted by someone else is refused.
+// @Tags         Staff
+// @Produce      html
+// @Security     CookieAuth
+// @Param        id  path  string  true  "Ticket ID"
+// @Success      302  {string}  string  "Redirect to the draft editor"
+// @Router       /staff/tickets/{id}/draft [post]
+func DraftFromTicket(c *gin.Context) {
+	ticketID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		err = articlesvc.ErrTicketNotDraftable
+	}
+	var article *models.KnowledgeArticle
+	if err == nil {
+		userID, _ := auth.CurrentUserID(c)
+		article, err = articlesvc.DraftFromTicket(ticketID, userID)
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, "/staff/bigbook?error="+url.QueryEscape(err.Error()))
+		return
+	}
+	c.Redirect(http.StatusFound, "/staff/articles/"+article.ID.String()+"/edit")
+}
 
-	// Get user info for the activity view
-	var user models.User
-	database.DB.First(&user, "id = ?", userID)
+// EditDraft godoc
+// @Summary      Draft editor
+// @Description  Edit one of your own article drafts and submit it for review
+// @Tags         Staff
+// @Produce      html
+// @Security     CookieAuth
+// @Param        id  path  string  true  "Article ID"
+// @Success      200  {string}  string  "HTML page"
+// @Failure      404  {string}  string  "Article not found"
+// @Router       /staff/articles/{id}/edit [get]
+func EditDraft(c *gin.Context) {
+	userID, _ := auth.CurrentUserID(c)
+	var article models.KnowledgeArticle
+	// Unscoped: a rejected draft stays readable so its author sees the feedback
+	if err := database.DB.Unscoped().First(&article, "id = ? AND author_id = ?", c.Param("id"), userID).Error; err != nil {
+		c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte("<h1>Article not found</h1>"))
+		return
+	}
+
+	c.HTML(http.StatusOK, "staff/article_edit.html", gin.H{
+		"title":       "Draft: " + article.Title,
+		"article":     article,
+		"rendered":    articlesvc.Render(&article),
+		"attachments": articlesvc.Attachments(article.ID),
+		"editable":    article.Status == models.ArticleDraft || article.Status == models.ArticleChangesRequested,
+		"saved":       c.Query("saved"),
+		"error":       c.Query("error"),
+	})
+}
+
+// SaveDraft godoc
+// @Summary      Save a draft
+// @Description  Save your draft as a new revision. With action=submit it is then sent to the managers for review.
+// @Tags         Staff
+// @Accept       x-www-form-urlencoded
+// @Produce      html
+// @Security     CookieAuth
+// @Param        id        path      string  true   "Article ID"
+// @Param        title     formData  string  true   "Title"
+// @Param        category  formData  string  false  "Category"
+// @Param        content   formData  string  true   "Content"
+// @Param        note      formData  string  false  "What changed"
+// @Param        action    formData  string  false  "save (default) or submit"
+// @Success      302  {string}  string  "Redirect back to the editor"
+// @Router       /staff/articles/{id}/draft [post]
+func SaveDraft(c *gin.Context) {
+	back := "/staff/articles/" + c.Param("id") + "/edit"
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		err = articlesvc.ErrArticleNotFound
+	}
+	userID, _ := auth.CurrentUserID(c)
+	if err == nil {
+		fields := articlesvc.Fields{
+			Title:    c.PostForm("title"),
+			Category: c.PostForm("category"),
+			Content:  c.PostForm("content"),
+		}
+		_, err = articlesvc.SaveDraft(articleID, userID, fields, c.PostForm("note"))
+	}
+	submit := c.PostForm("action") == "submit"
+	if err == nil && submit {
+		_, err = articlesvc.Submit(articleID, userID)
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, back+"?error="+url.QueryEscape(err.Error()))
+		return
+	}
+	if submit {
+		c.Redirect(http.StatusFound, back+"?saved=submitted")
+		return
+	}
+	c.Redirect(http.StatusFound, back+"?saved=1")
+}
+
+// UploadAttachment godoc
+// @Summary      Attach a file to a draft
+// @Description  Upload an image (PNG, JPEG, GIF, WebP) or PDF to your draft, max 10MB. The type is checked from the file content. Reference it in the content with the returned Markdown.
+// @Tags         Staff
+// @Accept       multipart/form-data
+// @Produce      html,json
+// @Security     CookieAuth
+// @Param        id    path      string  true  "Article ID"
+// @Param        file  formData  file    true  "Image or PDF"
+// @Success      200  {object}  object  "Attachment with its Markdown snippet (JSON)"
+// @Success      302  {string}  string  "Redirect back to the editor"
+// @Failure      400  {object}  object  "Unsupported file"
+// @Router       /staff/articles/{id}/attachments [post]
+func UploadAttachment(c *gin.Context) {
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		err = articlesvc.ErrArticleNotFound
+	}
+	userID, _ := auth.CurrentUserID(c)
+	if err == nil {
+		err = articlesvc.CheckDraftAuthor(articleID, userID)
+	}
+	var attachment *models.ArticleAttachment
+	if err == nil {
+		attachment, err = saveAttachment(c, articleID, userID)
+	}
+	attachmentResponse(c, attachment, err)
+}
+
+// saveAttachment reads the "file" form field into an attachment of the article
+func saveAttachment(c *gin.Context, articleID, userID uuid.UUID) (*models.ArticleAttachment, error) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, upload.ErrMissing
+	}
+	return articlesvc.AddFormAttachment(articleID, userID, header)
+}
+
+func attachmentResponse(c *gin.Context, attachment *models.ArticleAttachment, err error) {
+	back := "/staff/articles/" + c.Param("id") + "/edit"
+	if auth.WantsJSON(c) {
+		if err != nil {
+			c.JSON(articlesvc.ErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if attachment == nil {
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"id":            attachment.ID,
+			"url":           attachment.URL,
+			"thumbnail_url": attachment.ThumbnailURL,
+			"markdown":      articlesvc.Snippet(*attachment),
+		})
+		return
+	}
+	if err != nil {
+		c.Redirect(http.StatusFound, back+"?error="+url.QueryEscape(err.Error()))
+		return
+	}
+	c.Redirect(http.StatusFound, back)
+}
 
-	// 1. Save Activity (Chat)
-	activity := models.TicketActivity{
-		TicketID:   uuid.MustParse(id),
-		ActorID:    userID,
-		ActionType: "REPLY",
-		Note:       message,
-		CreatedAt:  time.Now(),
+// DeleteAttachment removes a file from your draft
+func DeleteAttachment(c *gin.Context) {
+	articleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		err = articlesvc.ErrArticleNotFound
+	}
+	attachmentID, parseErr := uuid.Parse(c.Param("attachmentID"))
+	if err == nil && parseErr != nil {
+		err = articlesvc.ErrAttachmentNotFound
+	}
+	if err == nil {
+		userID, _ := auth.CurrentUserID(c)
+		err = articlesvc.CheckDraftAuthor(articleID, userID)
+	}
+	if err == nil {
+		err = articlesvc.DeleteAttachment(articleID, attachmentID)
+	}
+	attachmentResponse(c, nil, err)
+}
+
+
+
+func ReplyTicket(c *gin.Context) {
+	id := c.Param("id")
+	user, ok := auth.CurrentUser(c)
+	if !ok {
+		c.Redirect(http.StatusFound, "/auth/login")
+		return
+	}
+	userID := user.ID
+	ticketID, err := uuid.Parse(id)
+	if err != nil {
+		ticketActionResponse(c, nil, ticket.ErrTicketNotFound)
+		return
+	}
+
+	// 1. Save Activity (Chat) with its photos / videos
+	form, _ := c.MultipartForm()
+	files, err := ticket.ReadFiles(ticket.FormFiles(form, "attachments"))
+	var activity *models.TicketActivity
+	var attachments []models.TicketAttachment
+	if err == nil {
+		activity, attachments, err = ticket.Reply(ticketID, userID, c.PostForm("message"), files)
+	}
+	if err != nil {
+		ticketActionResponse(c, nil, err)
+		return
 	}
-	database.DB.Create(&activity)
 
 	// 2. Publish to Redis for real-time updates
 	if redisClient.IsConnected() {
@@ -520,24 +1044,19 @@ func ReplyTicket(c *gin.Context) {
 			"ActorName":   user.FullName,
 			"ActorAvatar": user.AvatarURL,
 			"ActionType":  "REPLY",
-			"Note":        message,
+			"Note":        activity.Note,
 			"Time":        activity.CreatedAt.Format("02 Jan 15:04"),
 			"IsMe":        false, // Determined client-side
 			"ActorID":     userID.String(),
+			"Attachments": attachments,
 		}
 		redisClient.Publish("chat:"+id, activityView)
 		log.Println("[Redis] Staff published chat message for ticket:", id)
 	}
 
 	// 3. Auto-update status: If still OPEN -> Change to IN_PROGRESS
-	var ticket models.Ticket
-	database.DB.First(&ticket, "id = ?", id)
-	
-	if ticket.Status == models.StatusOpen {
-		database.DB.Model(&ticket).Updates(map[string]interface{}{
-			"status":            models.StatusInProgress,
-			"first_response_at": time.Now(),
-		})
+	if _, err := ticket.StartProgress(activity.TicketID, userID); err != nil {
+		log.Printf("[Ticket] Failed to start progress on %s: %v", id, err)
 	}
 
 	c.Redirect(http.StatusFound, "/staff/tickets/"+id)
@@ -0,0 +1,302 @@
+package notification
+
+import (
+	"errors"
+	"io"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxPushAttempts is how often a push is tried before it is logged FAILED
+	maxPushAttempts = 4
+	// pushQueueSize bounds the pushes waiting for a worker; senders block when it is full
+	pushQueueSize = 256
+	// deliveryLogRetention is how long the delivery log is kept
+	deliveryLogRetention = 30 * 24 * time.Hour
+	maxPushBackoff       = time.Minute
+)
+
+// Options are the delivery headers of a push
+type Options struct {
+	// TTL is how long, in seconds, the push service keeps the message for an offline device
+	TTL     int
+	Urgency webpush.Urgency
+	// Topic replaces a message with the same topic still waiting for the device
+	Topic string
+}
+
+// DefaultOptions apply to pushes that are not about a ticket
+var DefaultOptions = Options{TTL: int((12 * time.Hour).Seconds()), Urgency: webpush.UrgencyNormal}
+
+// ForTicket returns the delivery headers of a push about a ticket: urgent tickets go out with high
+// urgency and expire quickly (they are paged again anyway), and every push about the ticket shares
+// a topic, so a device coming back online only gets the latest update.
+func ForTicket(ticketID uuid.UUID, priority models.TicketPriority) Options {
+	opts := Options{Topic: strings.ReplaceAll(ticketID.String(), "-", "")}
+	switch priority {
+	case models.PriorityUrgentOnAir:
+		opts.TTL, opts.Urgency = int((10 * time.Minute).Seconds()), webpush.UrgencyHigh
+	case models.PriorityHigh:
+		opts.TTL, opts.Urgency = int(time.Hour.Seconds()), webpush.UrgencyHigh
+	default:
+		opts.TTL, opts.Urgency = int((24 * time.Hour).Seconds()), webpush.UrgencyNormal
+	}
+	return opts
+}
+
+func pick(opts []Options) Options {
+	if len(opts) > 0 {
+		return opts[0]
+	}
+	return DefaultOptions
+}
+
+// vapidSubject identifies the sender to push services (VAPID_SUBJECT, a mailto: or https: URL)
+func vapidSubject() string {
+	if s := os.Getenv("VAPID_SUBJECT"); s != "" {
+		return s
+	}
+	return "mailto:noreply@localhost"
+}
+
+// pushWorkers is the number of concurrent deliveries (PUSH_WORKERS, default 4)
+func pushWorkers() int {
+	if v, err := strconv.Atoi(os.Getenv("PUSH_WORKERS")); err == nil && v > 0 {
+		return v
+	}
+	return 4
+}
+
+// pushBackoff is the wait before retrying after the given number of failed attempts:
+// two seconds, doubling up to maxPushBackoff
+func pushBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > 6 {
+		return maxPushBackoff
+	}
+	if d := 2 * time.Second << (attempts - 1); d < maxPushBackoff {
+		return d
+	}
+	return maxPushBackoff
+}
+
+// Dispatcher delivers pushes with a bounded pool of workers. Deliveries refused with 429 or 5xx
+// are retried with a backoff, subscriptions answered with 404 or 410 are deleted, and every
+// attempt is written to the delivery log.
+type Dispatcher struct {
+	Client      webpush.HTTPClient
+	Workers     int
+	MaxAttempts int
+	Backoff     func(attempts int) time.Duration
+
+	start   sync.Once
+	queue   chan *delivery
+	pending sync.WaitGroup
+}
+
+type delivery struct {
+	sub     models.PushSubscription
+	title   string
+	payload []byte
+	opts    Options
+	attempt int
+}
+
+// dispatcher delivers the pushes of the Send functions
+var dispatcher = &Dispatcher{
+	Client:      &http.Client{Timeout: 15 * time.Second},
+	Workers:     pushWorkers(),
+	MaxAttempts: maxPushAttempts,
+	Backoff:     pushBackoff,
+}
+
+// Send queues payload for every subscription
+func (d *Dispatcher) Send(subs []models.PushSubscription, payload []byte, title string, opts Options) {
+	d.start.Do(func() {
+		d.queue = make(chan *delivery, pushQueueSize)
+		for i := 0; i < d.Workers; i++ {
+			go d.work()
+		}
+	})
+	for _, sub := range subs {
+		d.pending.Add(1)
+		d.queue <- &delivery{sub: sub, title: title, payload: payload, opts: opts}
+	}
+}
+
+// Wait blocks until every queued push was delivered, given up or dropped
+func (d *Dispatcher) Wait() {
+	d.pending.Wait()
+}
+
+func (d *Dispatcher) work() {
+	for job := range d.queue {
+		wait, retry := d.deliver(job)
+		if !retry {
+			d.pending.Done()
+			continue
+		}
+		// Retries wait off the pool so a slow push service does not hold up the others
+		time.AfterFunc(wait, func() { d.queue <- job })
+	}
+}
+
+// deliver makes one attempt and logs it. It returns when to try again, if at all.
+func (d *Dispatcher) deliver(job *delivery) (time.Duration, bool) {
+	job.attempt++
+	entry := models.PushDelivery{
+		SubscriptionID: job.sub.ID,
+		UserID:         job.sub.UserID,
+		PushService:    pushService(job.sub.Endpoint),
+		Title:          job.title,
+		Topic:          job.opts.Topic,
+		Urgency:        string(job.opts.Urgency),
+		TTL:            job.opts.TTL,
+		Attempt:        job.attempt,
+	}
+
+	key, ok := keyFor(job.sub)
+	if !ok {
+		entry.Outcome, entry.Error = models.PushGone, "VAPID key retired"
+		d.remove(job.sub)
+		d.record(entry)
+		return 0, false
+	}
+
+	started := time.Now()
+	resp, err := webpush.SendNotification(job.payload, &webpush.Subscription{
+		Endpoint: job.sub.Endpoint,
+		Keys:     webpush.Keys{P256dh: job.sub.P256dh, Auth: job.sub.Auth},
+	}, &webpush.Options{
+		HTTPClient:      d.Client,
+		Subscriber:      vapidSubject(),
+		VAPIDPublicKey:  key.Public,
+		VAPIDPrivateKey: key.Private,
+		TTL:             job.opts.TTL,
+		Urgency:         job.opts.Urgency,
+		Topic:           job.opts.Topic,
+	})
+	entry.DurationMS = time.Since(started).Milliseconds()
+
+	var wait time.Duration
+	retryable := false
+	switch {
+	case err != nil:
+		// Network errors are worth another try, bad subscription keys are not
+		var netErr *url.Error
+		entry.Error = err.Error()
+		retryable = errors.As(err, &netErr)
+		wait = d.Backoff(job.attempt)
+	default:
+		entry.StatusCode = resp.StatusCode
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			entry.Outcome = models.PushSent
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			entry.Outcome = models.PushGone
+			d.remove(job.sub)
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			entry.Error = strings.TrimSpace(string(body))
+			retryable = true
+			wait = retryAfter(resp, d.Backoff(job.attempt))
+		default:
+			entry.Error = strings.TrimSpace(string(body))
+		}
+	}
+
+	if entry.Outcome == "" {
+		switch {
+		case retryable && job.attempt < d.MaxAttempts:
+			entry.Outcome = models.PushRetry
+		default:
+			entry.Outcome = models.PushFailed
+			log.Printf("[Push] ❌ Gagal kirim ke %s (%s, percobaan %d): %d %s", job.sub.UserID, entry.PushService,
+				job.attempt, entry.StatusCode, entry.Error)
+		}
+	}
+	d.record(entry)
+	return wait, entry.Outcome == models.PushRetry
+}
+
+// retryAfter honours the Retry-After header (in seconds) of a 429 or 503
+func retryAfter(resp *http.Response, fallback time.Duration) time.Duration {
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+		if d := time.Duration(secs) * time.Second; d < maxPushBackoff {
+			return d
+		}
+		return maxPushBackoff
+	}
+	return fallback
+}
+
+func pushService(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// remove deletes a subscription the push service no longer knows
+func (d *Dispatcher) remove(sub models.PushSubscription) {
+	if err := database.DB.Delete(&models.PushSubscription{}, "id = ?", sub.ID).Error; err != nil {
+		log.Printf("[Push] ❌ Gagal hapus subscription %s: %v", sub.ID, err)
+		return
+	}
+	log.Printf("[Push] 🧹 Subscription mati milik %s dihapus", sub.UserID)
+}
+
+func (d *Dispatcher) record(entry models.PushDelivery) {
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Printf("[Push] ⚠️  Gagal mencatat pengiriman: %v", err)
+	}
+}
+
+// DeliveryFilter narrows the delivery log; zero fields match everything
+type DeliveryFilter struct {
+	UserID  *uuid.UUID
+	Outcome string
+	Since   time.Time
+	Limit   int
+}
+
+// Deliveries returns the newest logged delivery attempts matching f (at most 500)
+func Deliveries(f DeliveryFilter) ([]models.PushDelivery, error) {
+	q := database.DB.Order("created_at desc")
+	if f.UserID != nil {
+		q = q.Where("user_id = ?", *f.UserID)
+	}
+	if f.Outcome != "" {
+		q = q.Where("outcome = ?", f.Outcome)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 500
+	}
+	var entries []models.PushDelivery
+	err := q.Limit(f.Limit).Find(&entries).Error
+	return entries, err
+}
+
+// PurgeDeliveries is the scheduler job dropping delivery log entries older than deliveryLogRetention
+func PurgeDeliveries(tx *gorm.DB, now time.Time) error {
+	return tx.Where("created_at < ?", now.Add(-deliveryLogRetention)).Delete(&models.PushDelivery{}).Error
+}
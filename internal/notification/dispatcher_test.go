@@ -0,0 +1,150 @@
+package notification
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForTicket(t *testing.T) {
+	id := uuid.New()
+
+	urgent := ForTicket(id, models.PriorityUrgentOnAir)
+	normal := ForTicket(id, models.PriorityNormal)
+	assert.Equal(t, webpush.UrgencyHigh, urgent.Urgency)
+	assert.Equal(t, webpush.UrgencyNormal, normal.Urgency)
+	assert.Less(t, urgent.TTL, normal.TTL, "stale pages are useless, they are repeated anyway")
+
+	assert.Equal(t, urgent.Topic, normal.Topic, "updates of one ticket collapse")
+	assert.NotEqual(t, urgent.Topic, ForTicket(uuid.New(), models.PriorityNormal).Topic)
+	assert.LessOrEqual(t, len(urgent.Topic), 32, "push services reject longer topics")
+}
+
+func TestPushBackoff(t *testing.T) {
+	assert.Equal(t, 2*time.Second, pushBackoff(1))
+	assert.Equal(t, 4*time.Second, pushBackoff(2))
+	assert.Equal(t, maxPushBackoff, pushBackoff(10))
+}
+
+// fakePushService answers like a push service: each path has a script of status codes,
+// the last one repeating
+type fakePushService struct {
+	mu      sync.Mutex
+	scripts map[string][]int
+	calls   map[string][]*http.Request
+}
+
+func (f *fakePushService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[r.URL.Path] = append(f.calls[r.URL.Path], r)
+	script := f.scripts[r.URL.Path]
+	status := script[0]
+	if len(script) > 1 {
+		f.scripts[r.URL.Path] = script[1:]
+	}
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "0")
+	}
+	w.WriteHeader(status)
+}
+
+// browserKeys returns the p256dh and auth secret of a fake browser subscription
+func browserKeys(t *testing.T) (string, string) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()), base64.RawURLEncoding.EncodeToString(auth)
+}
+
+func TestDispatcher_AgainstFakePushService(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	privateKey, publicKey, err := webpush.GenerateVAPIDKeys()
+	assert.NoError(t, err)
+	t.Setenv("VAPID_PUBLIC_KEY", publicKey)
+	t.Setenv("VAPID_PRIVATE_KEY", privateKey)
+	assert.NoError(t, InitKeys())
+
+	fake := &fakePushService{
+		scripts: map[string][]int{
+			"/ok":      {http.StatusCreated},
+			"/gone":    {http.StatusGone},
+			"/missing": {http.StatusNotFound},
+			"/flaky":   {http.StatusServiceUnavailable, http.StatusCreated},
+			"/limited": {http.StatusTooManyRequests},
+			"/bad":     {http.StatusBadRequest},
+		},
+		calls: map[string][]*http.Request{},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	user := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&user)
+	subs := map[string]models.PushSubscription{}
+	var all []models.PushSubscription
+	for path := range fake.scripts {
+		p256dh, auth := browserKeys(t)
+		sub := models.PushSubscription{UserID: user.ID, Endpoint: srv.URL + path, P256dh: p256dh, Auth: auth}
+		db.Create(&sub)
+		subs[path] = sub
+		all = append(all, sub)
+	}
+
+	d := &Dispatcher{Client: srv.Client(), Workers: 2, MaxAttempts: 3, Backoff: func(int) time.Duration { return time.Millisecond }}
+	opts := ForTicket(uuid.New(), models.PriorityUrgentOnAir)
+	d.Send(all, []byte(`{"title":"Tes"}`), "Tes", opts)
+	d.Wait()
+
+	// Delivery headers follow the ticket
+	ok := fake.calls["/ok"][0]
+	assert.Equal(t, "600", ok.Header.Get("TTL"))
+	assert.Equal(t, "high", ok.Header.Get("Urgency"))
+	assert.Equal(t, opts.Topic, ok.Header.Get("Topic"))
+	assert.Contains(t, ok.Header.Get("Authorization"), "vapid")
+
+	// Retries on 429/5xx only, up to MaxAttempts
+	assert.Len(t, fake.calls["/flaky"], 2)
+	assert.Len(t, fake.calls["/limited"], 3)
+	assert.Len(t, fake.calls["/bad"], 1)
+	assert.Len(t, fake.calls["/gone"], 1)
+
+	// Dead subscriptions are deleted, the others kept
+	var left []string
+	db.Model(&models.PushSubscription{}).Where("user_id = ?", user.ID).Order("endpoint").Pluck("endpoint", &left)
+	assert.Equal(t, []string{srv.URL + "/bad", srv.URL + "/flaky", srv.URL + "/limited", srv.URL + "/ok"}, left)
+
+	// Every attempt is in the delivery log
+	outcomes := func(path string) []string {
+		var out []string
+		db.Model(&models.PushDelivery{}).Where("subscription_id = ?", subs[path].ID).Order("attempt").Pluck("outcome", &out)
+		return out
+	}
+	assert.Equal(t, []string{models.PushSent}, outcomes("/ok"))
+	assert.Equal(t, []string{models.PushGone}, outcomes("/gone"))
+	assert.Equal(t, []string{models.PushGone}, outcomes("/missing"))
+	assert.Equal(t, []string{models.PushRetry, models.PushSent}, outcomes("/flaky"))
+	assert.Equal(t, []string{models.PushRetry, models.PushRetry, models.PushFailed}, outcomes("/limited"))
+	assert.Equal(t, []string{models.PushFailed}, outcomes("/bad"))
+
+	failed, err := Deliveries(DeliveryFilter{UserID: &user.ID, Outcome: models.PushFailed})
+	assert.NoError(t, err)
+	assert.Len(t, failed, 2)
+	for _, e := range failed {
+		assert.Equal(t, srv.Listener.Addr().String(), e.PushService)
+		assert.Equal(t, opts.Topic, e.Topic)
+	}
+}
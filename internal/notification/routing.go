@@ -0,0 +1,94 @@
+package notification
+
+import (
+	"it-broadcast-ops/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Routing tiers: who a staff notification goes to
+const (
+	TierOnShift  = "ON_SHIFT" // Staff whose regular shift covers now
+	TierOnCall   = "ON_CALL"  // Off-shift staff on standby, paged for urgent tickets nobody picked up
+	TierManagers = "MANAGERS" // Fallback when nobody is on duty
+)
+
+// Route is the resolved audience of a staff notification
+type Route struct {
+	Tier    string
+	UserIDs []uuid.UUID
+}
+
+// OnShiftStaff returns the active staff whose regular shift covers now
+func OnShiftStaff(tx *gorm.DB, now time.Time) ([]uuid.UUID, error) {
+	return shiftStaff(tx, now, false)
+}
+
+// OnCallStaff returns the active staff with an on-call shift covering now who are not also on a regular shift
+func OnCallStaff(tx *gorm.DB, now time.Time) ([]uuid.UUID, error) {
+	onCall, err := shiftStaff(tx, now, true)
+	if err != nil {
+		return nil, err
+	}
+	onShift, err := shiftStaff(tx, now, false)
+	if err != nil {
+		return nil, err
+	}
+	working := make(map[uuid.UUID]bool, len(onShift))
+	for _, id := range onShift {
+		working[id] = true
+	}
+	var ids []uuid.UUID
+	for _, id := range onCall {
+		if !working[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func shiftStaff(tx *gorm.DB, now time.Time, onCall bool) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := tx.Model(&models.Shift{}).
+		Joins("JOIN users ON users.id = shifts.user_id").
+		Where("users.role = ? AND users.is_active = ?", models.RoleStaff, true).
+		Where("shifts.on_call = ? AND shifts.start_time <= ? AND shifts.end_time > ?", onCall, now, now).
+		Distinct().Pluck("shifts.user_id", &ids).Error
+	return ids, err
+}
+
+// Managers returns every active manager
+func Managers(tx *gorm.DB) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := tx.Model(&models.User{}).
+		Where("role = ? AND is_active = ?", models.RoleManager, true).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// StaffRoute resolves who hears about staff work at now: the staff on shift,
+// or the managers when no shift is running.
+func StaffRoute(tx *gorm.DB, now time.Time) (Route, error) {
+	ids, err := OnShiftStaff(tx, now)
+	if err != nil || len(ids) > 0 {
+		return Route{Tier: TierOnShift, UserIDs: ids}, err
+	}
+	return managerRoute(tx)
+}
+
+// OnCallRoute resolves who is paged when nobody on shift reacted to an urgent ticket:
+// the on-call staff, or the managers when nobody is on call.
+func OnCallRoute(tx *gorm.DB, now time.Time) (Route, error) {
+	ids, err := OnCallStaff(tx, now)
+	if err != nil || len(ids) > 0 {
+		return Route{Tier: TierOnCall, UserIDs: ids}, err
+	}
+	return managerRoute(tx)
+}
+
+func managerRoute(tx *gorm.DB) (Route, error) {
+	ids, err := Managers(tx)
+	return Route{Tier: TierManagers, UserIDs: ids}, err
+}
@@ -0,0 +1,53 @@
+package notification
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutes_FollowShifts(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	pagi := models.User{Email: "pagi@example.com", FullName: "Staff Pagi", Role: models.RoleStaff, IsActive: true}
+	malam := models.User{Email: "malam@example.com", FullName: "Staff Malam", Role: models.RoleStaff, IsActive: true}
+	standby := models.User{Email: "standby@example.com", FullName: "Staff Standby", Role: models.RoleStaff, IsActive: true}
+	manager := models.User{Email: "manager@example.com", FullName: "Manager", Role: models.RoleManager, IsActive: true}
+	for _, u := range []*models.User{&pagi, &malam, &standby, &manager} {
+		db.Create(u)
+	}
+
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	db.Create(&models.Shift{UserID: pagi.ID, StartTime: day.Add(7 * time.Hour), EndTime: day.Add(15 * time.Hour), Label: "Pagi"})
+	db.Create(&models.Shift{UserID: malam.ID, StartTime: day.Add(22 * time.Hour), EndTime: day.Add(30 * time.Hour), Label: "Malam"})
+	db.Create(&models.Shift{UserID: standby.ID, StartTime: day, EndTime: day.Add(24 * time.Hour), Label: "On-call", OnCall: true})
+
+	// 10:00: only the morning crew, the night crew and standby are left alone
+	route, err := StaffRoute(db, day.Add(10*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, Route{Tier: TierOnShift, UserIDs: []uuid.UUID{pagi.ID}}, route)
+
+	route, err = OnCallRoute(db, day.Add(10*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, Route{Tier: TierOnCall, UserIDs: []uuid.UUID{standby.ID}}, route)
+
+	// 18:00: no regular shift running, managers are the fallback
+	route, err = StaffRoute(db, day.Add(18*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, Route{Tier: TierManagers, UserIDs: []uuid.UUID{manager.ID}}, route)
+
+	// The next day nobody is on call either
+	route, err = OnCallRoute(db, day.Add(26*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, TierManagers, route.Tier)
+
+	// Deactivated staff are skipped
+	db.Model(&pagi).Update("is_active", false)
+	route, err = StaffRoute(db, day.Add(10*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, TierManagers, route.Tier)
+}
@@ -5,122 +5,119 @@ import (
 	"it-broadcast-ops/internal/database"
 	"it-broadcast-ops/internal/models"
 	"log"
-	"os"
-
-	"github.com/SherClockHolmes/webpush-go"
+	"time"
 )
 
-// KITA GUNAKAN KUNCI TEST INI (JANGAN DIGANTI DULU SAMPAI BERHASIL)
-const (
-	// Key ini saya generate khusus untuk debugging Anda.
-	// Format: URL-Safe Base64 (tanpa padding)
-	FixedVapidPublic  = "BM5O2q4y2M2-8r2a2j2d2J2-9s8d7f6g5h4j3k2l1-1q2w3e4r5t6y7u8i9o0p" 
-	FixedVapidPrivate = "q1w2e3r4t5y6u7i8o9p0-a1s2d3f4g5h6j7k8l9" 
-)
+// SendNotificationToUser mengirim notifikasi ke satu user (untuk Test Button).
+// Opsi pengiriman (TTL, Urgency, Topic) default ke DefaultOptions, lihat ForTicket.
+func SendNotificationToUser(userID string, title, message, url string, opts ...Options) error {
+	var subs []models.PushSubscription
+	database.DB.Where("user_id = ?", userID).Find(&subs)
 
-var (
-	VapidPublicKey  string
-	VapidPrivateKey string
-)
+	if len(subs) == 0 {
+		return nil
+	}
 
-func InitKeys() {
-	// Cek environment variable, jika tidak ada gunakan kunci FIX di atas
-	VapidPublicKey = os.Getenv("VAPID_PUBLIC_KEY")
-	if VapidPublicKey == "" {
-		log.Println("⚠️  Using HARDCODED VAPID Keys for testing stability.")
-		
-		// Generate kunci baru yang valid secara programatik untuk memastikan formatnya 100% benar
-		// Kita override konstanta string di atas dengan generate langsung agar aman dari typo
-		privateKey, publicKey, err := webpush.GenerateVAPIDKeys()
-		if err != nil {
-			log.Fatal("Gagal generate VAPID keys:", err)
-		}
+	return sendToSubs(subs, title, message, url, pick(opts))
+}
 
-		VapidPublicKey = publicKey
-		VapidPrivateKey = privateKey
-		
-		log.Printf("🔑 PUBLIC KEY: %s", VapidPublicKey)
-		log.Printf("🔒 PRIVATE KEY: %s", VapidPrivateKey)
-	} else {
-		VapidPrivateKey = os.Getenv("VAPID_PRIVATE_KEY")
+// SendBroadcastToStaff mengirim notifikasi ke staff yang sedang shift (Untuk Tiket Baru).
+// Jika tidak ada shift yang berjalan, notifikasi jatuh ke manager.
+func SendBroadcastToStaff(title, message, url string, opts ...Options) {
+	route, err := StaffRoute(database.DB, time.Now())
+	if err != nil {
+		log.Printf("[Broadcast] ❌ Error Query Database: %v", err)
+		return
 	}
+	SendToRoute(route, title, message, url, opts...)
 }
 
-// SendNotificationToUser mengirim notifikasi ke satu user (untuk Test Button)
-func SendNotificationToUser(userID string, title, message, url string) error {
+// SendToRoute mengirim notifikasi ke semua penerima hasil routing
+func SendToRoute(route Route, title, message, url string, opts ...Options) {
+	if len(route.UserIDs) == 0 {
+		log.Printf("[Broadcast] ⚠️ Tidak ada penerima untuk tier %s!", route.Tier)
+		return
+	}
 	var subs []models.PushSubscription
-	database.DB.Where("user_id = ?", userID).Find(&subs)
-
-	if len(subs) == 0 {
-		return nil
+	if err := database.DB.Where("user_id IN ?", route.UserIDs).Find(&subs).Error; err != nil {
+		log.Printf("[Broadcast] ❌ Error Query Database: %v", err)
+		return
 	}
-	
-	return sendToSubs(subs, title, message, url)
+	log.Printf("[Broadcast] 📡 Tier %s: %d user, %d device", route.Tier, len(route.UserIDs), len(subs))
+	sendToSubs(subs, title, message, url, pick(opts))
 }
 
-// SendBroadcastToStaff mengirim notifikasi ke SEMUA staff (Untuk Tiket Baru)
-func SendBroadcastToStaff(title, message, url string) {
-	log.Println("[Broadcast] 📡 Memulai broadcast ke seluruh STAFF...")
-
+// SendBroadcastToManagers mengirim notifikasi ke semua MANAGER (untuk eskalasi)
+func SendBroadcastToManagers(title, message, url string, opts ...Options) {
 	var subs []models.PushSubscription
-	
-	// Query Debug: Cari subscription milik user dengan role STAFF
-	// Pastikan join table 'users' dan 'push_subscriptions' benar
 	err := database.DB.Table("push_subscriptions").
 		Joins("JOIN users ON users.id = push_subscriptions.user_id").
-		Where("users.role = ?", models.RoleStaff).
+		Where("users.role = ?", models.RoleManager).
 		Find(&subs).Error
-
 	if err != nil {
 		log.Printf("[Broadcast] ❌ Error Query Database: %v", err)
 		return
 	}
-
 	if len(subs) == 0 {
-		log.Println("[Broadcast] ⚠️ Tidak ada subscription STAFF ditemukan!")
-		
-		// Debugging tambahan: Cek apakah ada user staff sama sekali?
-		var staffCount int64
-		database.DB.Model(&models.User{}).Where("role = ?", models.RoleStaff).Count(&staffCount)
-		log.Printf("[Broadcast] ℹ️ Info DB: Total User Staff = %d", staffCount)
+		log.Println("[Broadcast] ⚠️ Tidak ada subscription MANAGER ditemukan!")
 		return
 	}
 
-	log.Printf("[Broadcast] ✅ Ditemukan %d device staff. Mengirim...", len(subs))
-	sendToSubs(subs, title, message, url)
+	log.Printf("[Broadcast] ✅ Eskalasi ke %d device manager.", len(subs))
+	sendToSubs(subs, title, message, url, pick(opts))
+}
+
+// Action is a button shown on the notification; the service worker POSTs to URL when it is pressed
+type Action struct {
+	Action string `json:"action"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+}
+
+// Payload is what the service worker receives
+type Payload struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url"`
+	// Tag replaces an earlier notification with the same tag instead of stacking a new one
+	Tag                string   `json:"tag,omitempty"`
+	RequireInteraction bool     `json:"requireInteraction,omitempty"`
+	Actions            []Action `json:"actions,omitempty"`
 }
 
-// Helper internal untuk mengirim loop
-func sendToSubs(subs []models.PushSubscription, title, message, url string) error {
-	payloadData := map[string]string{
-		"title": title,
-		"body":  message,
-		"url":   url,
+// SendPage memanggil penerima tiket URGENT: notifikasi tetap tampil sampai disentuh dan punya
+// tombol "Saya tangani" yang langsung meng-acknowledge tiket.
+func SendPage(route Route, title, message, url, ackURL, tag string, opts ...Options) {
+	if len(route.UserIDs) == 0 {
+		log.Printf("[Page] ⚠️ Tidak ada penerima untuk tier %s!", route.Tier)
+		return
+	}
+	var subs []models.PushSubscription
+	if err := database.DB.Where("user_id IN ?", route.UserIDs).Find(&subs).Error; err != nil {
+		log.Printf("[Page] ❌ Error Query Database: %v", err)
+		return
 	}
-	payloadJSON, _ := json.Marshal(payloadData)
+	sendPayload(subs, Payload{
+		Title:              title,
+		Body:               message,
+		URL:                url,
+		Tag:                tag,
+		RequireInteraction: true,
+		Actions:            []Action{{Action: "ack", Title: "✅ Saya tangani", URL: ackURL}},
+	}, pick(opts))
+}
 
-	for _, sub := range subs {
-		s := &webpush.Subscription{
-			Endpoint: sub.Endpoint,
-			Keys: webpush.Keys{
-				P256dh: sub.P256dh,
-				Auth:   sub.Auth,
-			},
-		}
+// Helper internal untuk mengirim ke banyak device
+func sendToSubs(subs []models.PushSubscription, title, message, url string, opts Options) error {
+	return sendPayload(subs, Payload{Title: title, Body: message, URL: url}, opts)
+}
 
-		resp, err := webpush.SendNotification(payloadJSON, s, &webpush.Options{
-			Subscriber:      "mailto:admin@example.com",
-			VAPIDPublicKey:  VapidPublicKey,
-			VAPIDPrivateKey: VapidPrivateKey,
-			TTL:             30,
-		})
-		
-		if err != nil {
-			log.Printf("[Push] ❌ Gagal kirim ke %s: %v", sub.UserID, err)
-		} else {
-			log.Printf("[Push] 🚀 Terkirim ke %s", sub.UserID)
-			resp.Body.Close()
-		}
+// sendPayload menyerahkan push ke dispatcher; pengiriman, retry dan pencatatan berjalan di worker
+func sendPayload(subs []models.PushSubscription, payload Payload, opts Options) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
 	}
+	dispatcher.Send(subs, payloadJSON, payload.Title, opts)
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,406 @@
+package notification
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrKeysNotRotatable is returned by RotateKeys when the keys are not kept in the database
+var ErrKeysNotRotatable = errors.New("VAPID keys come from the environment or are temporary and cannot be rotated")
+
+// vapidPair is a decrypted signing key pair. ID is uuid.Nil for keys that are not persisted.
+type vapidPair struct {
+	ID      uuid.UUID
+	Public  string
+	Private string
+}
+
+const (
+	// keysRefresh is how often the stored keys are reloaded, so a rotation on another
+	// instance is picked up
+	keysRefresh = time.Minute
+	// vapidKeysLock serialises instances generating or rotating the stored keys
+	vapidKeysLock int64 = 0x76617069646b6579
+)
+
+var (
+	keysMu sync.RWMutex
+	// current signs pushes to new subscriptions and to those made before keys were persisted
+	current vapidPair
+	// previous holds rotated keys still in their grace period
+	previous  = map[uuid.UUID]vapidPair{}
+	persisted bool
+	loadedAt  time.Time
+)
+
+// RotationGrace is how long a rotated key keeps working for devices that have not resubscribed
+// (VAPID_ROTATION_GRACE_DAYS, default 14)
+func RotationGrace() time.Duration {
+	days := 14
+	if v, err := strconv.Atoi(os.Getenv("VAPID_ROTATION_GRACE_DAYS")); err == nil && v > 0 {
+		days = v
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// encryptionKey derives the AES-256 key protecting stored private keys from VAPID_ENCRYPTION_KEY.
+// Nil when it is not configured.
+func encryptionKey() []byte {
+	secret := os.Getenv("VAPID_ENCRYPTION_KEY")
+	if secret == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// seal encrypts plaintext with AES-GCM; the nonce is prepended to the ciphertext
+func seal(key []byte, plaintext string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+func unseal(key []byte, sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// InitKeys loads the web push signing keys. VAPID_PUBLIC_KEY/VAPID_PRIVATE_KEY take precedence;
+// otherwise, with VAPID_ENCRYPTION_KEY set, the key pair is generated once and stored encrypted in
+// the database, so subscriptions survive restarts. When the stored keys cannot be read a temporary
+// key is used and the error is returned.
+func InitKeys() error {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	if pub := os.Getenv("VAPID_PUBLIC_KEY"); pub != "" {
+		priv := os.Getenv("VAPID_PRIVATE_KEY")
+		if priv == "" {
+			return errors.New("VAPID_PUBLIC_KEY is set without VAPID_PRIVATE_KEY")
+		}
+		current, previous, persisted = vapidPair{Public: pub, Private: priv}, map[uuid.UUID]vapidPair{}, false
+		log.Println("🔑 Using VAPID keys from the environment")
+		return nil
+	}
+
+	key := encryptionKey()
+	if key == nil {
+		log.Println("⚠️  VAPID_ENCRYPTION_KEY not set. Using a temporary VAPID key; push subscriptions will not survive a restart.")
+		return temporaryKey()
+	}
+	if err := loadKeys(database.DB, key); err != nil {
+		if tmpErr := temporaryKey(); tmpErr != nil {
+			return tmpErr
+		}
+		return err
+	}
+	return nil
+}
+
+// temporaryKey signs with a key pair that only lives in memory. The caller holds keysMu.
+func temporaryKey() error {
+	privateKey, publicKey, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		return err
+	}
+	current, previous, persisted = vapidPair{Public: publicKey, Private: privateKey}, map[uuid.UUID]vapidPair{}, false
+	return nil
+}
+
+// lockKeys holds vapidKeysLock until tx ends, so only one instance generates or rotates keys
+func lockKeys(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", vapidKeysLock).Error
+}
+
+// loadKeys reads the stored keys, generating the first pair when there is none.
+// The caller holds keysMu.
+func loadKeys(db *gorm.DB, key []byte) error {
+	var rows []models.VapidKey
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockKeys(tx); err != nil {
+			return err
+		}
+		if err := tx.Order("created_at asc").Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if row.RetiresAt == nil {
+				return nil
+			}
+		}
+		row, err := newKey(tx, key)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, *row)
+		log.Println("🔑 Generated and stored a new VAPID key")
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var cur vapidPair
+	loaded := map[uuid.UUID]vapidPair{}
+	for _, row := range rows {
+		priv, err := unseal(key, row.PrivateKeyEnc)
+		if err != nil {
+			return fmt.Errorf("decrypting VAPID key %s (was VAPID_ENCRYPTION_KEY changed?): %w", row.ID, err)
+		}
+		pair := vapidPair{ID: row.ID, Public: row.PublicKey, Private: priv}
+		if row.RetiresAt == nil {
+			cur = pair
+		} else {
+			loaded[row.ID] = pair
+		}
+	}
+	current, previous, persisted, loadedAt = cur, loaded, true, time.Now()
+	return nil
+}
+
+// reloadKeys reloads the stored keys when they are older than keysRefresh, or right away when
+// force is set (a key this instance does not know was seen). Errors keep the loaded keys.
+func reloadKeys(force bool) {
+	keysMu.RLock()
+	stale := persisted && (force || time.Since(loadedAt) > keysRefresh)
+	keysMu.RUnlock()
+	key := encryptionKey()
+	if !stale || key == nil {
+		return
+	}
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	if !force && time.Since(loadedAt) <= keysRefresh {
+		return // Reloaded by another goroutine meanwhile
+	}
+	if err := loadKeys(database.DB, key); err != nil {
+		log.Printf("[Push] ⚠️  Gagal memuat ulang VAPID key: %v", err)
+		loadedAt = time.Now()
+	}
+}
+
+// newKey generates a key pair and stores it encrypted as the current key
+func newKey(tx *gorm.DB, key []byte) (*models.VapidKey, error) {
+	privateKey, publicKey, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(key, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	row := models.VapidKey{PublicKey: publicKey, PrivateKeyEnc: sealed}
+	if err := tx.Create(&row).Error; err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// CurrentPublicKey is the application server key browsers subscribe with
+func CurrentPublicKey() string {
+	reloadKeys(false)
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return current.Public
+}
+
+// KeyIDFor returns the stored key a browser subscribed with, given its public key.
+// Unknown keys map to the current one; nil when keys are not persisted.
+func KeyIDFor(publicKey string) *uuid.UUID {
+	reloadKeys(false)
+	if id, known := keyIDFor(publicKey); known {
+		return id
+	}
+	// Possibly a key rotated in on another instance
+	reloadKeys(true)
+	id, _ := keyIDFor(publicKey)
+	return id
+}
+
+func keyIDFor(publicKey string) (*uuid.UUID, bool) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	if !persisted {
+		return nil, true
+	}
+	for id, pair := range previous {
+		if pair.Public == publicKey {
+			return &id, true
+		}
+	}
+	id := current.ID
+	return &id, current.Public == publicKey
+}
+
+// keyFor returns the key pushes to sub must be signed with. False when that key was retired.
+func keyFor(sub models.PushSubscription) (vapidPair, bool) {
+	reloadKeys(false)
+	if pair, ok := storedKeyFor(sub); ok {
+		return pair, true
+	}
+	// The subscription may use a key rotated in on another instance
+	reloadKeys(true)
+	return storedKeyFor(sub)
+}
+
+func storedKeyFor(sub models.PushSubscription) (vapidPair, bool) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	if sub.VapidKeyID == nil || *sub.VapidKeyID == current.ID {
+		return current, true
+	}
+	pair, ok := previous[*sub.VapidKeyID]
+	return pair, ok
+}
+
+// RotateKeys makes a new key current. Devices subscribed with the old key keep receiving pushes
+// until RotationGrace has passed and are asked to resubscribe in the meantime.
+func RotateKeys(now time.Time) (time.Time, error) {
+	key := encryptionKey()
+	keysMu.Lock()
+	if !persisted || key == nil {
+		keysMu.Unlock()
+		return time.Time{}, ErrKeysNotRotatable
+	}
+	retiresAt := now.Add(RotationGrace())
+	// The key being retired is read from the database, another instance may have rotated since
+	var old []uuid.UUID
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockKeys(tx); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.VapidKey{}).Where("retires_at IS NULL").Pluck("id", &old).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.VapidKey{}).Where("retires_at IS NULL").Update("retires_at", retiresAt).Error; err != nil {
+			return err
+		}
+		_, err := newKey(tx, key)
+		return err
+	})
+	if err == nil {
+		err = loadKeys(database.DB, key)
+	}
+	keysMu.Unlock()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	log.Printf("🔑 VAPID key rotated, the previous key retires at %s", retiresAt.Format(time.RFC3339))
+	go promptResubscribe(old, retiresAt)
+	return retiresAt, nil
+}
+
+// promptResubscribe asks the devices still subscribed with a rotated key to subscribe again
+func promptResubscribe(old []uuid.UUID, retiresAt time.Time) {
+	var subs []models.PushSubscription
+	if err := database.DB.Where("vapid_key_id IN ?", old).Find(&subs).Error; err != nil {
+		log.Printf("[Push] ❌ Error Query Database: %v", err)
+		return
+	}
+	sendPayload(subs, Payload{
+		Title: "🔑 Perbarui notifikasi",
+		Body:  "Buka aplikasi dan aktifkan ulang notifikasi sebelum " + retiresAt.Format("02 Jan 15:04") + " agar tetap menerima alert tiket.",
+		URL:   "/",
+	}, DefaultOptions)
+}
+
+// RetireKeys is the scheduler job dropping rotated keys whose grace period ended,
+// together with the subscriptions that still use them
+func RetireKeys(tx *gorm.DB, now time.Time) error {
+	key := encryptionKey()
+	keysMu.Lock()
+	defer keysMu.Unlock()
+	if !persisted || key == nil {
+		return nil
+	}
+
+	var expired []uuid.UUID
+	if err := tx.Model(&models.VapidKey{}).Where("retires_at <= ?", now).Pluck("id", &expired).Error; err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	res := tx.Where("vapid_key_id IN ?", expired).Delete(&models.PushSubscription{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if err := tx.Where("id IN ?", expired).Delete(&models.VapidKey{}).Error; err != nil {
+		return err
+	}
+	log.Printf("🔑 Retired %d VAPID key(s), dropped %d subscription(s) that did not resubscribe", len(expired), res.RowsAffected)
+	return loadKeys(tx, key)
+}
+
+// KeyInfo describes a stored key for the manager dashboard, without key material
+type KeyInfo struct {
+	CreatedAt time.Time
+	RetiresAt *time.Time
+	Devices   int64
+}
+
+// KeysPersisted reports whether the keys live in the database and can be rotated
+func KeysPersisted() bool {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return persisted
+}
+
+// StoredKeys lists the current key and the rotated keys in their grace period, newest first
+func StoredKeys() []KeyInfo {
+	var infos []KeyInfo
+	database.DB.Model(&models.VapidKey{}).
+		Select("vapid_keys.created_at, vapid_keys.retires_at, COUNT(push_subscriptions.id) AS devices").
+		Joins("LEFT JOIN push_subscriptions ON push_subscriptions.vapid_key_id = vapid_keys.id").
+		Group("vapid_keys.id").
+		Order("vapid_keys.created_at desc").
+		Scan(&infos)
+	return infos
+}
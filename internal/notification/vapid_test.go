@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeal_RoundTripAndWrongKey(t *testing.T) {
+	key := make([]byte, 32)
+	other := make([]byte, 32)
+	other[0] = 1
+
+	sealed, err := seal(key, "private-key")
+	assert.NoError(t, err)
+	assert.NotContains(t, sealed, "private-key")
+
+	plain, err := unseal(key, sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, "private-key", plain)
+
+	_, err = unseal(other, sealed)
+	assert.Error(t, err, "a different secret cannot read the key")
+}
+
+func TestKeys_PersistedAndRotated(t *testing.T) {
+	db := testutil.SetupTestDB()
+	t.Setenv("VAPID_PUBLIC_KEY", "")
+	t.Setenv("VAPID_ENCRYPTION_KEY", "test-secret")
+	t.Setenv("VAPID_ROTATION_GRACE_DAYS", "7")
+
+	assert.NoError(t, InitKeys())
+	first := CurrentPublicKey()
+	assert.NotEmpty(t, first)
+
+	var stored models.VapidKey
+	db.First(&stored)
+	assert.Equal(t, first, stored.PublicKey)
+	assert.NotContains(t, stored.PrivateKeyEnc, current.Private, "private key is encrypted at rest")
+
+	// A restart loads the same key instead of generating a new one
+	assert.NoError(t, InitKeys())
+	assert.Equal(t, first, CurrentPublicKey())
+
+	user := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&user)
+	sub := models.PushSubscription{UserID: user.ID, Endpoint: "https://push.example.com/1", P256dh: "p", Auth: "a",
+		VapidKeyID: KeyIDFor(first)}
+	db.Create(&sub)
+
+	now := time.Now()
+	retiresAt, err := RotateKeys(now)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, now.Add(7*24*time.Hour), retiresAt, time.Second)
+	assert.NotEqual(t, first, CurrentPublicKey())
+
+	// The old device keeps its key during the grace period
+	key, ok := keyFor(sub)
+	assert.True(t, ok)
+	assert.Equal(t, first, key.Public)
+	assert.Equal(t, *sub.VapidKeyID, *KeyIDFor(first))
+
+	assert.NoError(t, RetireKeys(db, now.Add(time.Hour)))
+	_, ok = keyFor(sub)
+	assert.True(t, ok, "still in grace")
+
+	assert.NoError(t, RetireKeys(db, retiresAt))
+	_, ok = keyFor(sub)
+	assert.False(t, ok)
+	var left int64
+	db.Model(&models.PushSubscription{}).Where("id = ?", sub.ID).Count(&left)
+	assert.Zero(t, left, "devices that did not resubscribe are dropped")
+
+	// A rotation on another instance is picked up once the loaded keys are stale
+	db.Model(&models.VapidKey{}).Where("retires_at IS NULL").Update("retires_at", now.Add(time.Hour))
+	rotated, err := newKey(db, encryptionKey())
+	assert.NoError(t, err)
+	assert.NotEqual(t, rotated.PublicKey, CurrentPublicKey(), "loaded keys are still fresh")
+	loadedAt = time.Now().Add(-keysRefresh - time.Second)
+	assert.Equal(t, rotated.PublicKey, CurrentPublicKey())
+
+	// Another secret cannot read the stored keys: a temporary key is used instead of crashing
+	t.Setenv("VAPID_ENCRYPTION_KEY", "other-secret")
+	assert.Error(t, InitKeys())
+	assert.False(t, KeysPersisted())
+	assert.NotEmpty(t, CurrentPublicKey())
+}
+
+func TestInitKeys_RequiresEncryptionKey(t *testing.T) {
+	t.Setenv("VAPID_PUBLIC_KEY", "")
+	t.Setenv("VAPID_ENCRYPTION_KEY", "")
+	t.Setenv("SESSION_SECRET", "session-secret")
+
+	assert.NoError(t, InitKeys())
+	assert.False(t, KeysPersisted(), "the session secret does not protect stored keys")
+	assert.NotEmpty(t, CurrentPublicKey())
+}
@@ -95,3 +95,18 @@ func IsConnected() bool {
 	_, err := Client.Ping(ctx).Result()
 	return err == nil
 }
+
+// === SET FUNCTIONS ===
+
+// AddToSet adds members to a set and refreshes its TTL
+func AddToSet(key string, ttl time.Duration, members ...string) error {
+	if err := Client.SAdd(ctx, key, members).Err(); err != nil {
+		return err
+	}
+	return Client.Expire(ctx, key, ttl).Err()
+}
+
+// SetMembers returns all members of a set
+func SetMembers(key string) ([]string, error) {
+	return Client.SMembers(ctx, key).Result()
+}
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"it-broadcast-ops/internal/utils"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// routineLookback lets the generator catch up on slots missed during a restart or deploy.
+// Slots are unique per template, so re-checking them is harmless.
+const routineLookback = 5 * time.Minute
+
+// GenerateRoutineInstances creates a RoutineInstance for every active template whose
+// cron schedule (evaluated in Asia/Jakarta) fires between now-routineLookback and now.
+func GenerateRoutineInstances(tx *gorm.DB, now time.Time) error {
+	var templates []models.RoutineTemplate
+	if err := tx.Where("is_active = ?", true).Find(&templates).Error; err != nil {
+		return err
+	}
+
+	loc := utils.Jakarta()
+	now = now.In(loc).Truncate(time.Minute)
+
+	for _, tpl := range templates {
+		schedule, err := cron.ParseStandard(tpl.CronSchedule)
+		if err != nil {
+			log.Printf("[Routine] ⚠️ Invalid cron %q on template %s: %v", tpl.CronSchedule, tpl.ID, err)
+			continue
+		}
+
+		for _, slot := range dueSlots(schedule, now.Add(-routineLookback), now) {
+			// Don't generate instances that would already be past their deadline
+			dueAt := slot.Add(time.Duration(tpl.DeadlineMinutes) * time.Minute)
+			if !dueAt.After(now) {
+				continue
+			}
+			if err := createInstance(tx, tpl, slot, dueAt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dueSlots returns every minute in (from, to] at which the schedule fires
+func dueSlots(schedule cron.Schedule, from, to time.Time) []time.Time {
+	var slots []time.Time
+	for t := schedule.Next(from); !t.After(to); t = schedule.Next(t) {
+		slots = append(slots, t)
+	}
+	return slots
+}
+
+func createInstance(tx *gorm.DB, tpl models.RoutineTemplate, slot, dueAt time.Time) error {
+	state, err := initialChecklistState(tpl.ChecklistItems)
+	if err != nil {
+		log.Printf("[Routine] ⚠️ Invalid checklist on template %s: %v", tpl.ID, err)
+	}
+
+	instance := models.RoutineInstance{
+		TemplateID:     tpl.ID,
+		AssignedUserID: staffOnShift(tx, slot),
+		ChecklistState: state,
+		ScheduledFor:   &slot,
+		GeneratedAt:    time.Now(),
+		DueAt:          dueAt,
+		Status:         models.RoutinePending,
+	}
+
+	// Unique (template_id, scheduled_for) makes this a no-op if another replica or a previous run created it
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&instance)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	log.Printf("[Routine] ✅ Generated %q for %s (due %s)", tpl.Title, slot.Format("2006-01-02 15:04"), dueAt.Format("15:04"))
+	if instance.AssignedUserID != nil {
+		userID := instance.AssignedUserID.String()
+		database.AfterCommit(tx, func() {
+			go notification.SendNotificationToUser(
+				userID,
+				"📋 Routine: "+tpl.Title,
+				fmt.Sprintf("Checklist harus selesai sebelum %s", dueAt.Format("15:04")),
+				"/staff",
+			)
+		})
+	} else {
+		log.Printf("[Routine] ⚠️ No active shift at %s, %q is unassigned", slot.Format("15:04"), tpl.Title)
+	}
+	return nil
+}
+
+// staffOnShift returns the staff member whose shift covers t.
+// If shifts overlap, the one staying longest is chosen so the owner is still on duty at the deadline.
+func staffOnShift(tx *gorm.DB, t time.Time) *uuid.UUID {
+	var shift models.Shift
+	err := tx.Where("start_time <= ? AND end_time > ?", t, t).
+		Order("end_time desc").
+		First(&shift).Error
+	if err != nil {
+		return nil
+	}
+	return &shift.UserID
+}
+
+// initialChecklistState turns the template items into a {"item": false} map.
+// Items are stored either as plain strings or as {"label": "..."} objects.
+func initialChecklistState(items models.JSONB) (models.JSONB, error) {
+	state := map[string]bool{}
+	if len(items) > 0 {
+		var labels []string
+		if err := json.Unmarshal(items, &labels); err != nil {
+			var objects []struct {
+				Label string `json:"label"`
+			}
+			if err := json.Unmarshal(items, &objects); err != nil {
+				return models.JSONB(`{}`), err
+			}
+			for _, o := range objects {
+				labels = append(labels, o.Label)
+			}
+		}
+		for _, label := range labels {
+			if label != "" {
+				state[label] = false
+			}
+		}
+	}
+	data, err := json.Marshal(state)
+	return data, err
+}
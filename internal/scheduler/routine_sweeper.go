@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RoutineReminderLead is how long before DueAt the assignee gets a reminder push
+// (ROUTINE_REMINDER_MINUTES, default 10).
+func RoutineReminderLead() time.Duration {
+	return envMinutes("ROUTINE_REMINDER_MINUTES", 10)
+}
+
+// RoutineMissedGrace is how long an instance stays OVERDUE before it becomes MISSED
+// (ROUTINE_MISSED_GRACE_MINUTES, default 60).
+func RoutineMissedGrace() time.Duration {
+	return envMinutes("ROUTINE_MISSED_GRACE_MINUTES", 60)
+}
+
+func envMinutes(key string, def int) time.Duration {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v >= 0 {
+		return time.Duration(v) * time.Minute
+	}
+	return time.Duration(def) * time.Minute
+}
+
+// SweepRoutineInstances moves instances through PENDING -> OVERDUE -> MISSED,
+// reminds the assignee before the deadline and escalates missed routines to managers.
+func SweepRoutineInstances(tx *gorm.DB, now time.Time) error {
+	// 1. Reminder before deadline (once per instance)
+	var upcoming []models.RoutineInstance
+	tx.Preload("Template").
+		Where("status = ? AND reminder_sent_at IS NULL AND assigned_user_id IS NOT NULL", models.RoutinePending).
+		Where("due_at > ? AND due_at <= ?", now, now.Add(RoutineReminderLead())).
+		Find(&upcoming)
+	for _, r := range upcoming {
+		if err := tx.Model(&r).Update("reminder_sent_at", now).Error; err != nil {
+			return err
+		}
+		database.AfterCommit(tx, func() {
+			go notification.SendNotificationToUser(
+				r.AssignedUserID.String(),
+				"⏰ Routine hampir lewat: "+r.Template.Title,
+				fmt.Sprintf("Deadline %s, segera selesaikan checklist.", r.DueAt.Format("15:04")),
+				"/staff",
+			)
+		})
+	}
+
+	// 2. PENDING past deadline -> OVERDUE
+	overdue := tx.Model(&models.RoutineInstance{}).
+		Where("status = ? AND due_at <= ?", models.RoutinePending, now).
+		Update("status", models.RoutineOverdue)
+	if overdue.Error != nil {
+		return overdue.Error
+	}
+	if overdue.RowsAffected > 0 {
+		log.Printf("[Routine] ⚠️ %d instance(s) marked OVERDUE", overdue.RowsAffected)
+	}
+
+	// 3. OVERDUE past grace period -> MISSED + escalate to managers
+	var missed []models.RoutineInstance
+	tx.Preload("Template").
+		Where("status = ? AND due_at <= ?", models.RoutineOverdue, now.Add(-RoutineMissedGrace())).
+		Find(&missed)
+	for _, r := range missed {
+		if err := tx.Model(&r).Updates(map[string]interface{}{
+			"status":       models.RoutineMissed,
+			"escalated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		assignee := "belum di-assign"
+		if r.AssignedUserID != nil {
+			var user models.User
+			if tx.Select("full_name").First(&user, "id = ?", r.AssignedUserID).Error == nil {
+				assignee = user.FullName
+			}
+		}
+		log.Printf("[Routine] ❌ %q (due %s) MISSED by %s", r.Template.Title, r.DueAt.Format("15:04"), assignee)
+		database.AfterCommit(tx, func() {
+			go notification.SendBroadcastToManagers(
+				"❌ Routine terlewat: "+r.Template.Title,
+				fmt.Sprintf("Deadline %s, PIC: %s", r.DueAt.Format("02 Jan 15:04"), assignee),
+				"/manager",
+			)
+		})
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSweepRoutineInstances_RemindsThenMarksOverdueAndMissed(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	staff := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&staff)
+	tpl := models.RoutineTemplate{Title: "Cek genset", CronSchedule: "0 7 * * *", DeadlineMinutes: 30, CreatedBy: staff.ID, IsActive: true}
+	db.Create(&tpl)
+
+	due := time.Now().Truncate(time.Minute).Add(time.Hour)
+	slot := due.Add(-30 * time.Minute)
+	instance := models.RoutineInstance{TemplateID: tpl.ID, AssignedUserID: &staff.ID, ScheduledFor: &slot, DueAt: due,
+		Status: models.RoutinePending}
+	db.Create(&instance)
+
+	reload := func() models.RoutineInstance {
+		var r models.RoutineInstance
+		db.First(&r, "id = ?", instance.ID)
+		return r
+	}
+
+	// Too early for a reminder
+	assert.NoError(t, SweepRoutineInstances(db, due.Add(-RoutineReminderLead()-time.Minute)))
+	r := reload()
+	assert.Equal(t, models.RoutinePending, r.Status)
+	assert.Nil(t, r.ReminderSentAt)
+
+	// Reminded once inside the lead time
+	remindedAt := due.Add(-RoutineReminderLead() + time.Minute)
+	assert.NoError(t, SweepRoutineInstances(db, remindedAt))
+	r = reload()
+	assert.NotNil(t, r.ReminderSentAt)
+	assert.NoError(t, SweepRoutineInstances(db, remindedAt.Add(time.Minute)))
+	assert.WithinDuration(t, remindedAt, *reload().ReminderSentAt, time.Second, "no second reminder")
+
+	// Past the deadline it is overdue, not yet escalated
+	assert.NoError(t, SweepRoutineInstances(db, due))
+	r = reload()
+	assert.Equal(t, models.RoutineOverdue, r.Status)
+	assert.Nil(t, r.EscalatedAt)
+
+	// After the grace period it is missed and escalated to the managers once
+	missedAt := due.Add(RoutineMissedGrace())
+	assert.NoError(t, SweepRoutineInstances(db, missedAt))
+	r = reload()
+	assert.Equal(t, models.RoutineMissed, r.Status)
+	assert.NotNil(t, r.EscalatedAt)
+	assert.NoError(t, SweepRoutineInstances(db, missedAt.Add(time.Hour)))
+	assert.WithinDuration(t, missedAt, *reload().EscalatedAt, time.Second, "escalated once")
+
+	// Completed routines are left alone
+	done := models.RoutineInstance{TemplateID: tpl.ID, AssignedUserID: &staff.ID, DueAt: due, Status: models.RoutineCompleted}
+	db.Create(&done)
+	assert.NoError(t, SweepRoutineInstances(db, missedAt))
+	var left models.RoutineInstance
+	db.First(&left, "id = ?", done.ID)
+	assert.Equal(t, models.RoutineCompleted, left.Status)
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"it-broadcast-ops/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDueSlots(t *testing.T) {
+	loc := utils.Jakarta()
+	now := time.Date(2025, 1, 6, 7, 2, 0, 0, loc) // Monday 07:02 WIB
+
+	cases := []struct {
+		cron string
+		want []time.Time
+	}{
+		{"0 7 * * *", []time.Time{time.Date(2025, 1, 6, 7, 0, 0, 0, loc)}},
+		{"*/2 * * * *", []time.Time{
+			time.Date(2025, 1, 6, 6, 58, 0, 0, loc),
+			time.Date(2025, 1, 6, 7, 0, 0, 0, loc),
+			time.Date(2025, 1, 6, 7, 2, 0, 0, loc),
+		}},
+		{"0 7 * * 2", nil}, // Tuesday only
+		{"0 8 * * *", nil},
+	}
+
+	for _, tc := range cases {
+		schedule, err := cron.ParseStandard(tc.cron)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, dueSlots(schedule, now.Add(-routineLookback), now), tc.cron)
+	}
+}
+
+func TestInitialChecklistState(t *testing.T) {
+	// 1. Plain string items (CreateRoutine & seeder format)
+	state, err := initialChecklistState([]byte(`["Cek Mic", "Cek Lampu"]`))
+	assert.NoError(t, err)
+	var items map[string]bool
+	json.Unmarshal(state, &items)
+	assert.Equal(t, map[string]bool{"Cek Mic": false, "Cek Lampu": false}, items)
+
+	// 2. Object items (schema comment format)
+	state, err = initialChecklistState([]byte(`[{"label": "Cek Mic", "checked": true}]`))
+	assert.NoError(t, err)
+	items = nil
+	json.Unmarshal(state, &items)
+	assert.Equal(t, map[string]bool{"Cek Mic": false}, items)
+
+	// 3. Empty template
+	state, err = initialChecklistState(nil)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(state))
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"hash/fnv"
+	"it-broadcast-ops/internal/auth"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"it-broadcast-ops/internal/sla"
+	"it-broadcast-ops/internal/ticket"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Job is a unit of background work executed once per tick.
+// Jobs run inside a transaction holding a Postgres advisory lock named after the job,
+// so only one app replica runs a given job at a time.
+type Job struct {
+	Name string
+	Run  func(tx *gorm.DB, now time.Time) error
+}
+
+// jobs run in order on every tick
+var jobs = []Job{
+	{Name: "routine-generator", Run: GenerateRoutineInstances},
+	{Name: "routine-sweeper", Run: SweepRoutineInstances},
+	{Name: "ticket-autoclose", Run: ticket.AutoCloseResolved},
+	{Name: "sla-watcher", Run: sla.Watch},
+	{Name: "urgent-pager", Run: ticket.PageUrgent},
+	{Name: "ticket-reopen-reminder", Run: ticket.RemindResolved},
+	{Name: "vapid-key-retire", Run: notification.RetireKeys},
+	{Name: "push-log-purge", Run: notification.PurgeDeliveries},
+	{Name: "scheduler-run-purge", Run: PurgeRuns},
+	{Name: "session-purge", Run: auth.PurgeExpiredSessions},
+}
+
+// runRetention is how long scheduler_runs are kept
+const runRetention = 7 * 24 * time.Hour
+
+// Start runs every job once per minute, aligned to the start of the minute.
+func Start() {
+	go func() {
+		log.Printf("[Scheduler] ⏱️  Started with %d job(s)", len(jobs))
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			time.Sleep(time.Until(next))
+			RunOnce(next)
+		}
+	}()
+}
+
+// RunOnce executes all jobs for the given tick. Exposed for tests and manual triggers.
+func RunOnce(now time.Time) {
+	for _, job := range jobs {
+		if err := withLock(job.Name, now, func(tx *gorm.DB) error {
+			return job.Run(tx, now)
+		}); err != nil {
+			log.Printf("[Scheduler] ❌ Job %s failed: %v", job.Name, err)
+		}
+	}
+}
+
+// withLock runs fn in a transaction guarded by pg_try_advisory_xact_lock. If another replica
+// already holds the lock, fn is skipped. The lock is released on commit, so the tick is also
+// recorded in scheduler_runs: a replica arriving late for a tick already run skips it too.
+// Work registered with database.AfterCommit (pushes) runs once the transaction committed.
+func withLock(name string, tick time.Time, fn func(tx *gorm.DB) error) error {
+	var afterCommit func()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var acquired bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", lockKey(name)).Scan(&acquired).Error; err != nil {
+			return err
+		}
+		if !acquired {
+			return nil
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.SchedulerRun{Job: name, Tick: tick.Truncate(time.Minute)})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		tx, afterCommit = database.WithAfterCommit(tx)
+		return fn(tx)
+	})
+	if err == nil && afterCommit != nil {
+		afterCommit()
+	}
+	return err
+}
+
+// PurgeRuns is the scheduler job dropping scheduler_runs older than runRetention
+func PurgeRuns(tx *gorm.DB, now time.Time) error {
+	return tx.Where("tick < ?", now.Add(-runRetention)).Delete(&models.SchedulerRun{}).Error
+}
+
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("scheduler:" + name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"errors"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestWithLock_RunsATickOnceAndSendsAfterCommit(t *testing.T) {
+	db := testutil.SetupTestDB()
+	tick := time.Now().Truncate(time.Minute)
+
+	runs, sent := 0, 0
+	job := func(tx *gorm.DB) error {
+		runs++
+		database.AfterCommit(tx, func() { sent++ })
+		assert.Zero(t, sent, "nothing is sent before the commit")
+		return nil
+	}
+	assert.NoError(t, withLock("test-job", tick, job))
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, 1, sent)
+
+	// A replica arriving late for the same tick skips it
+	assert.NoError(t, withLock("test-job", tick.Add(20*time.Second), job))
+	assert.Equal(t, 1, runs)
+
+	assert.NoError(t, withLock("test-job", tick.Add(time.Minute), job))
+	assert.Equal(t, 2, runs)
+
+	// A failed run is rolled back, so the tick can be retried, and sends nothing
+	failing := func(tx *gorm.DB) error {
+		database.AfterCommit(tx, func() { sent++ })
+		return errors.New("boom")
+	}
+	assert.Error(t, withLock("failing-job", tick, failing))
+	assert.Equal(t, 2, sent)
+	var recorded int64
+	db.Model(&models.SchedulerRun{}).Where("job = ?", "failing-job").Count(&recorded)
+	assert.Zero(t, recorded)
+
+	assert.NoError(t, PurgeRuns(db, tick.Add(runRetention+2*time.Minute)))
+	db.Model(&models.SchedulerRun{}).Count(&recorded)
+	assert.Zero(t, recorded)
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"html"
+	"html/template"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Result is one Big Book hit: a verified article or a solved ticket's solution
+type Result struct {
+	ID           uuid.UUID
+	Type         string // "Article" or "Ticket"
+	Title        string
+	Category     string
+	Content      string
+	Snippet      template.HTML // Escaped excerpt, matched words wrapped in <mark>
+	Rank         float64
+	ViewsCount   int
+	TicketNumber int
+	ResolvedAt   *time.Time
+}
+
+const (
+	TypeArticle = "Article"
+	TypeTicket  = "Ticket"
+
+	// Private-use characters cannot appear in normal text, so ts_headline marks matches
+	// with them and Snippet swaps them for <mark> after escaping the rest.
+	markStart = "\uE000"
+	markStop  = "\uE001"
+
+	headlineOptions = `StartSel=` + markStart + `, StopSel=` + markStop +
+		`, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`
+
+	excerptLength = 160
+
+	// Limit is the number of results every Big Book endpoint returns
+	Limit = 50
+)
+
+// finishedStatuses are the tickets whose solutions are searchable
+var finishedStatuses = []models.TicketStatus{models.StatusResolved, models.StatusClosed}
+
+// searchSQL ranks verified articles (knowledge_articles.search_vector) and solved tickets
+// (database.TicketSearchVector) against the query parsed in both Indonesian and English.
+// The headline uses whichever language actually matched the text.
+var searchSQL = `
+WITH q AS (
+	SELECT websearch_to_tsquery('indonesian', @query) AS id,
+	       websearch_to_tsquery('english', @query) AS en
+)
+SELECT r.*,
+	CASE WHEN to_tsvector('indonesian', r.content) @@ q.id
+		THEN ts_headline('indonesian', r.content, q.id, @options)
+		ELSE ts_headline('english', r.content, q.en, @options)
+	END AS snippet
+FROM (
+	SELECT a.id, 'Article' AS type, a.title, a.category::text AS category, a.content,
+		a.views_count, 0 AS ticket_number, NULL::timestamptz AS resolved_at,
+		ts_rank(a.search_vector, q.id || q.en) AS rank
+	FROM knowledge_articles a, q
+	WHERE a.is_verified AND a.deleted_at IS NULL AND a.search_vector @@ (q.id || q.en)
+	UNION ALL
+	SELECT t.id, 'Ticket', t.subject, 'Ticket Solution', t.solution,
+		0, t.ticket_number, t.resolved_at,
+		ts_rank(` + database.TicketSearchVector + `, q.id || q.en)
+	FROM tickets t, q
+	WHERE t.status IN @finished AND t.solution <> ''
+		AND ` + database.TicketSearchVector + ` @@ (q.id || q.en)
+) r, q
+ORDER BY r.rank DESC, r.title
+LIMIT @limit`
+
+// Search returns Big Book results for query, best match first.
+// An empty query lists verified articles A-Z instead.
+func Search(query string, limit int) ([]Result, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return listArticles(limit)
+	}
+
+	var results []Result
+	err := database.DB.Raw(searchSQL, map[string]interface{}{
+		"query":    query,
+		"options":  headlineOptions,
+		"finished": finishedStatuses,
+		"limit":    limit,
+	}).Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Snippet = highlight(string(results[i].Snippet))
+	}
+	return results, nil
+}
+
+func listArticles(limit int) ([]Result, error) {
+	var articles []models.KnowledgeArticle
+	if err := database.DB.Where("is_verified = ?", true).Order("title ASC").Limit(limit).Find(&articles).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(articles))
+	for _, a := range articles {
+		results = append(results, Result{
+			ID:         a.ID,
+			Type:       TypeArticle,
+			Title:      a.Title,
+			Category:   a.Category,
+			Content:    a.Content,
+			Snippet:    template.HTML(html.EscapeString(excerpt(a.Content))),
+			ViewsCount: a.ViewsCount,
+		})
+	}
+	return results, nil
+}
+
+// highlight escapes a ts_headline fragment and turns the match markers into <mark> tags
+func highlight(headline string) template.HTML {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, markStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, markStop, "</mark>")
+	return template.HTML(escaped)
+}
+
+// excerpt cuts text to excerptLength characters on a word boundary
+func excerpt(text string) string {
+	if utf8.RuneCountInString(text) <= excerptLength {
+		return text
+	}
+	cut := string([]rune(text)[:excerptLength])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
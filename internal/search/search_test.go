@@ -0,0 +1,54 @@
+package search
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlight_EscapesText(t *testing.T) {
+	got := highlight("Restart <b>" + markStart + "router" + markStop + "</b> & tunggu")
+	assert.Equal(t, "Restart &lt;b&gt;<mark>router</mark>&lt;/b&gt; &amp; tunggu", string(got))
+}
+
+func TestSearch_RanksArticlesAndSolutions(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	author := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&author)
+
+	db.Create(&models.KnowledgeArticle{Title: "Router wifi mati", Content: "Restart router lalu cek kabel LAN.",
+		Category: "IT_NETWORK", AuthorID: author.ID, IsVerified: true})
+	db.Create(&models.KnowledgeArticle{Title: "Printer macet", Content: "Router tidak berhubungan dengan printer.",
+		Category: "SOFTWARE", AuthorID: author.ID, IsVerified: true})
+	db.Create(&models.KnowledgeArticle{Title: "Router draft", Content: "Belum diverifikasi.",
+		Category: "IT_NETWORK", AuthorID: author.ID})
+
+	resolvedAt := time.Now()
+	db.Create(&models.Ticket{Subject: "Wifi studio lambat", Solution: "Firmware router diperbarui",
+		Location: models.LocationStudio1, RequesterID: author.ID, Status: models.StatusClosed, ResolvedAt: &resolvedAt})
+	db.Create(&models.Ticket{Subject: "Router hilang", Location: models.LocationStudio2, RequesterID: author.ID,
+		Status: models.StatusOpen})
+
+	results, err := Search("router", Limit)
+	assert.NoError(t, err)
+	assert.Len(t, results, 3, "unverified articles and unsolved tickets are not searchable")
+
+	// Title matches (weight A) rank above body matches
+	assert.Equal(t, "Router wifi mati", results[0].Title)
+	assert.Contains(t, string(results[0].Snippet), "<mark>")
+
+	var types []string
+	for _, r := range results {
+		types = append(types, r.Type)
+	}
+	assert.Contains(t, types, TypeTicket)
+
+	// English query words match too
+	results, err = Search("restarting", Limit)
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+}
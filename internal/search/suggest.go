@@ -0,0 +1,138 @@
+package search
+
+import (
+	"it-broadcast-ops/internal/article"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// SuggestLimit is how many articles the report forms offer
+	SuggestLimit = 3
+
+	// minWordLength skips fragments too short to say anything about the problem
+	minWordLength = 3
+	maxWords      = 8
+)
+
+// suggestSQL matches verified articles against every word typed so far (any word, as a prefix,
+// so half-typed subjects already match). Articles in the picked category rank twice as high.
+const suggestSQL = `
+WITH q AS (
+	SELECT to_tsquery('indonesian', @words) AS id,
+	       to_tsquery('english', @words) AS en
+)
+SELECT a.id, 'Article' AS type, a.title, a.category::text AS category, a.content, a.views_count,
+	ts_rank(a.search_vector, q.id || q.en) * CASE WHEN a.category::text = @category THEN 2 ELSE 1 END AS rank,
+	ts_headline('indonesian', a.content, q.id || q.en, @options) AS snippet
+FROM knowledge_articles a, q
+WHERE a.is_verified AND a.deleted_at IS NULL AND a.search_vector @@ (q.id || q.en)
+ORDER BY rank DESC, a.title
+LIMIT @limit`
+
+// Suggest returns the verified articles that best match a partially typed ticket subject
+func Suggest(subject, category string, limit int) ([]Result, error) {
+	words := prefixQuery(subject)
+	if words == "" {
+		return []Result{}, nil
+	}
+
+	results := []Result{}
+	err := database.DB.Raw(suggestSQL, map[string]interface{}{
+		"words":    words,
+		"category": category,
+		"options":  headlineOptions,
+		"limit":    limit,
+	}).Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Snippet = highlight(string(results[i].Snippet))
+	}
+	return results, nil
+}
+
+// prefixQuery turns free text into a tsquery that matches any of its words as a prefix,
+// e.g. "Mic clip-on mat" -> "mic:* | clip:* | mat:*". Everything but letters and digits is
+// dropped so user input can never break the tsquery syntax.
+func prefixQuery(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var terms []string
+	seen := map[string]bool{}
+	for _, f := range fields {
+		if len([]rune(f)) < minWordLength || seen[f] {
+			continue
+		}
+		seen[f] = true
+		terms = append(terms, f+":*")
+		if len(terms) == maxWords {
+			break
+		}
+	}
+	return strings.Join(terms, " | ")
+}
+
+// Suggestion is who saw a suggestion and in which context
+type Suggestion struct {
+	Category string
+	Subject  string
+	Source   string     // "consumer" or "public"
+	UserID   *uuid.UUID // nil for public reports
+}
+
+// RecordDeflection stores that the requester solved the problem with articleID instead of
+// filing a ticket
+func RecordDeflection(articleID uuid.UUID, s Suggestion) error {
+	var a models.KnowledgeArticle
+	if err := database.DB.Select("id").Where("id = ? AND is_verified = ?", articleID, true).
+		First(&a).Error; err != nil {
+		return article.ErrArticleNotFound
+	}
+	return database.DB.Create(s.event(articleID, models.SuggestionDeflected)).Error
+}
+
+// RecordIgnored stores that ticketID was filed even though articleIDs were suggested.
+// ids come straight from the form, so unknown or malformed ones are skipped.
+func RecordIgnored(ticketID uuid.UUID, articleIDs []string, s Suggestion) {
+	var ids []uuid.UUID
+	for _, raw := range articleIDs {
+		if id, err := uuid.Parse(strings.TrimSpace(raw)); err == nil {
+			ids = append(ids, id)
+		}
+		if len(ids) == SuggestLimit {
+			break
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	var known []uuid.UUID
+	database.DB.Model(&models.KnowledgeArticle{}).Where("id IN ?", ids).Pluck("id", &known)
+	for _, id := range known {
+		event := s.event(id, models.SuggestionIgnored)
+		event.TicketID = &ticketID
+		database.DB.Create(event)
+	}
+}
+
+func (s Suggestion) event(articleID uuid.UUID, outcome string) *models.SuggestionEvent {
+	return &models.SuggestionEvent{
+		ArticleID: articleID,
+		Category:  s.Category,
+		Subject:   strings.TrimSpace(s.Subject),
+		Outcome:   outcome,
+		Source:    s.Source,
+		UserID:    s.UserID,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,45 @@
+package search
+
+import (
+	articlesvc "it-broadcast-ops/internal/article"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixQuery(t *testing.T) {
+	assert.Equal(t, "mic:* | clip:* | mat:*", prefixQuery("Mic clip-on mat"))
+	assert.Equal(t, "router:*", prefixQuery("router & ROUTER | !"), "operators are stripped, duplicates dropped")
+	assert.Equal(t, "", prefixQuery("ok ya"))
+}
+
+func TestSuggest_RecordsOutcomes(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	author := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&author)
+	article := models.KnowledgeArticle{Title: "Mic clip-on tidak bunyi", Content: "Ganti baterai transmitter.",
+		Category: "AUDIO", AuthorID: author.ID, IsVerified: true}
+	db.Create(&article)
+
+	results, err := Suggest("mic cli", "AUDIO", SuggestLimit)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, article.ID, results[0].ID)
+	}
+
+	ctx := Suggestion{Category: "AUDIO", Subject: "mic clip-on mati", Source: "public"}
+	assert.NoError(t, RecordDeflection(article.ID, ctx))
+	assert.ErrorIs(t, RecordDeflection(uuid.New(), ctx), articlesvc.ErrArticleNotFound)
+
+	RecordIgnored(uuid.New(), []string{article.ID.String(), uuid.New().String(), "not-a-uuid"}, ctx)
+
+	var deflected, ignored int64
+	db.Model(&models.SuggestionEvent{}).Where("outcome = ?", models.SuggestionDeflected).Count(&deflected)
+	db.Model(&models.SuggestionEvent{}).Where("outcome = ?", models.SuggestionIgnored).Count(&ignored)
+	assert.Equal(t, int64(1), deflected)
+	assert.Equal(t, int64(1), ignored, "unknown and malformed ids are skipped")
+}
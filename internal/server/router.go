@@ -14,6 +14,7 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"it-broadcast-ops/internal/auth"
 	"it-broadcast-ops/internal/modules/consumer"
+	"it-broadcast-ops/internal/modules/files"
 	"it-broadcast-ops/internal/modules/manager"
 	"it-broadcast-ops/internal/modules/public"
 	"it-broadcast-ops/internal/modules/staff"
@@ -58,7 +59,6 @@ func NewRouter() *gin.Engine {
 	r.Use(gin.Recovery())
 
 	r.Static("/static", "./web/static")
-	r.Static("/uploads", "./web/uploads")
     
     // [FIX] Serve Service Worker di Root Path agar Scope-nya global (mencakup /staff, /consumer, dll)
 	r.StaticFile("/sw.js", "./web/static/sw.js")
@@ -78,6 +78,7 @@ func NewRouter() *gin.Engine {
 	staff.RegisterRoutes(r)
 	manager.RegisterRoutes(r)
 	notification.RegisterRoutes(r)
+	files.RegisterRoutes(r)
 	public.RegisterRoutes(r) // Public emergency form (no auth) 
 
 	r.GET("/seed", func(c *gin.Context) {
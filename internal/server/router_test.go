@@ -0,0 +1,126 @@
+package server
+
+import (
+	"it-broadcast-ops/internal/auth"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// NewRouter loads templates and static files relative to the project root
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func TestRouteGroups_AuthAndRoles(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	// Seed one user per role
+	sessions := map[models.UserRole]string{}
+	for _, role := range []models.UserRole{models.RoleConsumer, models.RoleStaff, models.RoleManager} {
+		user := models.User{
+			Email:    strings.ToLower(string(role)) + "@example.com",
+			FullName: "Test " + string(role),
+			Role:     role,
+			IsActive: true,
+		}
+		db.Create(&user)
+		value, err := auth.IssueSession(user.ID)
+		assert.NoError(t, err)
+		sessions[role] = value
+	}
+
+	r := NewRouter()
+
+	const anonymous models.UserRole = ""
+	type expect int
+	const (
+		allowed expect = iota
+		redirectLogin
+		unauthorized
+		forbidden
+	)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		role   models.UserRole
+		json   bool // fetch/HTMX request instead of a page navigation
+		want   expect
+	}{
+		// /consumer: every logged-in role
+		{"consumer page anonymous", "GET", "/consumer", anonymous, false, redirectLogin},
+		{"consumer api anonymous", "GET", "/consumer/bigbook", anonymous, true, unauthorized},
+		{"consumer page as consumer", "GET", "/consumer", models.RoleConsumer, false, allowed},
+		{"consumer page as staff", "GET", "/consumer", models.RoleStaff, false, allowed},
+		{"consumer page as manager", "GET", "/consumer", models.RoleManager, false, allowed},
+		{"consumer api as consumer", "GET", "/consumer/bigbook", models.RoleConsumer, true, allowed},
+
+		// /staff: staff and managers
+		{"staff page anonymous", "GET", "/staff", anonymous, false, redirectLogin},
+		{"staff htmx anonymous", "GET", "/staff/tickets/list", anonymous, true, unauthorized},
+		{"staff page as consumer", "GET", "/staff", models.RoleConsumer, false, forbidden},
+		{"staff htmx as consumer", "GET", "/staff/tickets/list", models.RoleConsumer, true, forbidden},
+		{"staff page as staff", "GET", "/staff", models.RoleStaff, false, allowed},
+		{"staff page as manager", "GET", "/staff", models.RoleManager, false, allowed},
+		{"staff api as staff", "GET", "/staff/bigbook/search", models.RoleStaff, true, allowed},
+
+		// /manager: managers only
+		{"manager page anonymous", "GET", "/manager", anonymous, false, redirectLogin},
+		{"manager api anonymous", "GET", "/manager/articles/00000000-0000-0000-0000-000000000000/json", anonymous, true, unauthorized},
+		{"manager page as consumer", "GET", "/manager", models.RoleConsumer, false, forbidden},
+		{"manager page as staff", "GET", "/manager", models.RoleStaff, false, forbidden},
+		{"manager api as staff", "GET", "/manager/articles/00000000-0000-0000-0000-000000000000/json", models.RoleStaff, true, forbidden},
+		{"manager page as manager", "GET", "/manager", models.RoleManager, false, allowed},
+
+		// /notifications: any logged-in user
+		{"notifications anonymous", "POST", "/notifications/test", anonymous, true, unauthorized},
+		{"notifications as consumer", "POST", "/notifications/test", models.RoleConsumer, true, allowed},
+		{"vapid key is public", "GET", "/notifications/vapid-public-key", anonymous, true, allowed},
+
+		// Public routes stay open
+		{"public report form", "GET", "/report", anonymous, false, allowed},
+		{"login form", "GET", "/auth/login", anonymous, false, allowed},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, tc.path, nil)
+			if tc.json {
+				req.Header.Set("Accept", "application/json")
+			} else {
+				req.Header.Set("Accept", "text/html,application/xhtml+xml")
+			}
+			if tc.role != anonymous {
+				req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: sessions[tc.role]})
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			switch tc.want {
+			case allowed:
+				assert.NotContains(t, []int{http.StatusFound, http.StatusUnauthorized, http.StatusForbidden}, w.Code)
+			case redirectLogin:
+				assert.Equal(t, http.StatusFound, w.Code)
+				assert.Equal(t, "/auth/login?next=%2F"+strings.TrimPrefix(tc.path, "/"), w.Header().Get("Location"))
+			case unauthorized:
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+			case forbidden:
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package sla
+
+import (
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/utils"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// specificity scores how well a policy matches a ticket: -1 if it does not apply,
+// otherwise the number of fields (priority, category, location) it pins down.
+func specificity(p *models.SLAPolicy, t *models.Ticket) int {
+	score := 0
+	for _, f := range []struct{ want, got string }{
+		{p.Priority, string(t.Priority)},
+		{p.Category, t.Category},
+		{p.Location, string(t.Location)},
+	} {
+		if f.want == "" {
+			continue
+		}
+		if f.want != f.got {
+			return -1
+		}
+		score++
+	}
+	return score
+}
+
+// Best picks the policy for a ticket out of policies: the most specific match,
+// and the stricter resolution target when two are equally specific.
+func Best(policies []models.SLAPolicy, t *models.Ticket) *models.SLAPolicy {
+	var best *models.SLAPolicy
+	bestScore := -1
+	for i := range policies {
+		p := &policies[i]
+		score := specificity(p, t)
+		if score < 0 {
+			continue
+		}
+		if score > bestScore || (score == bestScore && p.ResolutionMinutes < best.ResolutionMinutes) {
+			best, bestScore = p, score
+		}
+	}
+	return best
+}
+
+// Match returns the active policy for a ticket, or nil when none applies
+func Match(db *gorm.DB, t *models.Ticket) (*models.SLAPolicy, error) {
+	var policies []models.SLAPolicy
+	if err := db.Where("is_active = ?", true).Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return Best(policies, t), nil
+}
+
+// DueAt adds minutes of SLA time to from. With BusinessHoursOnly only Monday-Friday between
+// BusinessStartHour and BusinessEndHour count, so a ticket raised on Friday evening is due on Monday.
+// Business hours are Jakarta time whatever the zone of from; the result is in Jakarta time.
+func DueAt(p *models.SLAPolicy, from time.Time, minutes int) time.Time {
+	remaining := time.Duration(minutes) * time.Minute
+	from = from.In(utils.Jakarta())
+	if !p.BusinessHoursOnly || p.BusinessEndHour <= p.BusinessStartHour {
+		return from.Add(remaining)
+	}
+
+	t := from
+	for remaining > 0 {
+		y, m, d := t.Date()
+		opening := time.Date(y, m, d, p.BusinessStartHour, 0, 0, 0, t.Location())
+		closing := time.Date(y, m, d, p.BusinessEndHour, 0, 0, 0, t.Location())
+		if weekday := t.Weekday(); weekday == time.Saturday || weekday == time.Sunday || !t.Before(closing) {
+			t = opening.AddDate(0, 0, 1)
+			continue
+		}
+		if t.Before(opening) {
+			t = opening
+		}
+		available := closing.Sub(t)
+		if remaining <= available {
+			return t.Add(remaining)
+		}
+		remaining -= available
+		t = closing
+	}
+	return t
+}
+
+// Apply stamps the SLA policy and due times on a ticket that is about to be created.
+// Tickets matching no policy are left without targets.
+func Apply(t *models.Ticket) {
+	policy, err := Match(database.DB, t)
+	if err != nil {
+		log.Println("[SLA] Policy lookup failed:", err)
+		return
+	}
+	if policy == nil {
+		return
+	}
+
+	created := t.CreatedAt
+	if created.IsZero() {
+		created = time.Now()
+		t.CreatedAt = created
+	}
+	responseDue := DueAt(policy, created, policy.ResponseMinutes)
+	resolutionDue := DueAt(policy, created, policy.ResolutionMinutes)
+	t.SLAPolicyID = &policy.ID
+	t.ResponseDueAt = &responseDue
+	t.ResolutionDueAt = &resolutionDue
+}
@@ -0,0 +1,74 @@
+package sla
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBest_MostSpecificWins(t *testing.T) {
+	policies := []models.SLAPolicy{
+		{Name: "Default", ResponseMinutes: 60, ResolutionMinutes: 480},
+		{Name: "On Air", Priority: "URGENT_ON_AIR", ResponseMinutes: 5, ResolutionMinutes: 15},
+		{Name: "On Air Audio", Priority: "URGENT_ON_AIR", Category: "AUDIO", ResponseMinutes: 2, ResolutionMinutes: 10},
+		{Name: "MCR", Location: "MCR", ResponseMinutes: 10, ResolutionMinutes: 60},
+	}
+
+	audio := &models.Ticket{Priority: models.PriorityUrgentOnAir, Category: "AUDIO", Location: models.LocationStudio1}
+	assert.Equal(t, "On Air Audio", Best(policies, audio).Name)
+
+	video := &models.Ticket{Priority: models.PriorityUrgentOnAir, Category: "VIDEO", Location: models.LocationStudio1}
+	assert.Equal(t, "On Air", Best(policies, video).Name)
+
+	// Equally specific: the stricter resolution target wins
+	mcr := &models.Ticket{Priority: models.PriorityUrgentOnAir, Category: "VIDEO", Location: models.LocationMCR}
+	assert.Equal(t, "On Air", Best(policies, mcr).Name)
+
+	office := &models.Ticket{Priority: models.PriorityNormal, Location: models.LocationOffice}
+	assert.Equal(t, "Default", Best(policies, office).Name)
+
+	assert.Nil(t, Best(policies[1:3], office))
+}
+
+func TestDueAt_BusinessHours(t *testing.T) {
+	loc := utils.Jakarta()
+	p := &models.SLAPolicy{BusinessHoursOnly: true, BusinessStartHour: 8, BusinessEndHour: 17}
+
+	// Friday 16:00 + 120 minutes: 60 on Friday, the rest Monday morning
+	friday := time.Date(2024, 3, 1, 16, 0, 0, 0, loc)
+	assert.True(t, time.Date(2024, 3, 4, 9, 0, 0, 0, loc).Equal(DueAt(p, friday, 120)))
+
+	// Before opening time the clock starts at 08:00
+	early := time.Date(2024, 3, 5, 6, 30, 0, 0, loc)
+	assert.True(t, time.Date(2024, 3, 5, 8, 30, 0, 0, loc).Equal(DueAt(p, early, 30)))
+
+	// Saturday rolls over to Monday
+	saturday := time.Date(2024, 3, 2, 10, 0, 0, 0, loc)
+	assert.True(t, time.Date(2024, 3, 4, 8, 15, 0, 0, loc).Equal(DueAt(p, saturday, 15)))
+
+	// Without business hours it is plain wall-clock time
+	p.BusinessHoursOnly = false
+	assert.True(t, friday.Add(2*time.Hour).Equal(DueAt(p, friday, 120)))
+}
+
+func TestDueAt_UTCInputUsesJakartaHours(t *testing.T) {
+	loc := utils.Jakarta()
+	p := &models.SLAPolicy{BusinessHoursOnly: true, BusinessStartHour: 8, BusinessEndHour: 17}
+
+	// 02:00 UTC is 09:00 in Jakarta, already inside business hours
+	morning := time.Date(2024, 3, 5, 2, 0, 0, 0, time.UTC)
+	due := DueAt(p, morning, 30)
+	assert.True(t, time.Date(2024, 3, 5, 9, 30, 0, 0, loc).Equal(due))
+	assert.Equal(t, 9, due.Hour(), "reported in Jakarta time")
+
+	// Friday 09:30 UTC is 16:30 in Jakarta: 30 minutes left on Friday, the rest Monday
+	friday := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	assert.True(t, time.Date(2024, 3, 4, 8, 30, 0, 0, loc).Equal(DueAt(p, friday, 60)))
+
+	// Saturday 20:00 UTC is already Sunday 03:00 in Jakarta, still the weekend
+	saturday := time.Date(2024, 3, 2, 20, 0, 0, 0, time.UTC)
+	assert.True(t, time.Date(2024, 3, 4, 8, 15, 0, 0, loc).Equal(DueAt(p, saturday, 15)))
+}
@@ -0,0 +1,166 @@
+package sla
+
+import (
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AtRiskRatio is the share of a target's window after which a running ticket is flagged at risk
+const AtRiskRatio = 0.8
+
+var activeStatuses = []models.TicketStatus{models.StatusOpen, models.StatusInProgress, models.StatusHandover}
+
+// target describes the columns behind one SLA target
+type target struct {
+	kind    string
+	dueCol  string // tickets column holding the due time
+	metExpr string // SQL expression for when the target was met (NULL = not yet)
+	riskCol string // tickets column set when the at-risk warning went out
+}
+
+var targets = []target{
+	{
+		kind:    models.SLAResponse,
+		dueCol:  "response_due_at",
+		metExpr: "COALESCE(claimed_at, first_response_at, resolved_at)",
+		riskCol: "response_at_risk_at",
+	},
+	{
+		kind:    models.SLAResolution,
+		dueCol:  "resolution_due_at",
+		metExpr: "resolved_at",
+		riskCol: "resolution_at_risk_at",
+	},
+}
+
+// Watch is the scheduler job that records SLA breaches and warns about tickets close to one.
+// Breaches are recorded once per ticket and target in sla_breaches, which the dashboard reads.
+func Watch(tx *gorm.DB, now time.Time) error {
+	for _, tg := range targets {
+		if err := recordBreaches(tx, now, tg); err != nil {
+			return err
+		}
+		if err := warnAtRisk(tx, now, tg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordBreaches stores a breach for tickets whose target passed without being met (or was met late)
+func recordBreaches(tx *gorm.DB, now time.Time, tg target) error {
+	var tickets []models.Ticket
+	err := tx.Where(tg.dueCol+" <= ?", now).
+		Where("("+tg.metExpr+" IS NULL OR "+tg.metExpr+" > "+tg.dueCol+")").
+		Where("NOT EXISTS (SELECT 1 FROM sla_breaches b WHERE b.ticket_id = tickets.id AND b.kind = ?)", tg.kind).
+		Find(&tickets).Error
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tickets {
+		due := dueOf(&t, tg.kind)
+		breach := models.SLABreach{TicketID: t.ID, PolicyID: t.SLAPolicyID, Kind: tg.kind, DueAt: *due}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&breach).Error; err != nil {
+			return err
+		}
+		if isRunning(&t, tg.kind) {
+			escalate(tx, &t, tg.kind, now.Sub(*due))
+		}
+	}
+	if len(tickets) > 0 {
+		log.Printf("[SLA] 🚨 Recorded %d %s breach(es)", len(tickets), tg.kind)
+	}
+	return nil
+}
+
+// warnAtRisk pushes a one-time warning once AtRiskRatio of a running target's window has passed
+func warnAtRisk(tx *gorm.DB, now time.Time, tg target) error {
+	var tickets []models.Ticket
+	err := tx.Where("status IN ?", activeStatuses).
+		Where(tg.metExpr+" IS NULL AND "+tg.riskCol+" IS NULL").
+		Where(tg.dueCol+" > ?", now).
+		Where("created_at + ("+tg.dueCol+" - created_at) * ? <= ?", AtRiskRatio, now).
+		Find(&tickets).Error
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tickets {
+		if err := tx.Model(&t).UpdateColumn(tg.riskCol, now).Error; err != nil {
+			return err
+		}
+		due := dueOf(&t, tg.kind)
+		title := fmt.Sprintf("⏳ SLA %s tiket #%d hampir habis", label(tg.kind), t.TicketNumber)
+		msg := fmt.Sprintf("%s - sisa %s", t.Subject, formatDuration(due.Sub(now)))
+		notifyOwner(tx, &t, title, msg)
+	}
+	return nil
+}
+
+// escalate alerts managers and the ticket owner, once tx committed, that a running target was missed
+func escalate(tx *gorm.DB, t *models.Ticket, kind string, late time.Duration) {
+	title := fmt.Sprintf("🚨 SLA %s tiket #%d terlewati", label(kind), t.TicketNumber)
+	msg := fmt.Sprintf("%s - terlambat %s", t.Subject, formatDuration(late))
+	opts := notification.ForTicket(t.ID, t.Priority)
+	database.AfterCommit(tx, func() {
+		go notification.SendBroadcastToManagers(title, msg, "/manager#manager-content-history", opts)
+	})
+	notifyOwner(tx, t, title, msg)
+}
+
+// notifyOwner pushes to the assignee, or to every staff member while the ticket is in the pool,
+// once tx committed
+func notifyOwner(tx *gorm.DB, t *models.Ticket, title, msg string) {
+	url := "/staff/tickets/" + t.ID.String()
+	opts := notification.ForTicket(t.ID, t.Priority)
+	if t.CurrentAssigneeID != nil {
+		userID := t.CurrentAssigneeID.String()
+		database.AfterCommit(tx, func() {
+			go notification.SendNotificationToUser(userID, title, msg, url, opts)
+		})
+		return
+	}
+	database.AfterCommit(tx, func() {
+		go notification.SendBroadcastToStaff(title, msg, url, opts)
+	})
+}
+
+func dueOf(t *models.Ticket, kind string) *time.Time {
+	if kind == models.SLAResponse {
+		return t.ResponseDueAt
+	}
+	return t.ResolutionDueAt
+}
+
+// isRunning reports whether the target is still unmet, i.e. escalating can still help
+func isRunning(t *models.Ticket, kind string) bool {
+	if t.Status == models.StatusResolved || t.Status == models.StatusClosed {
+		return false
+	}
+	if kind == models.SLAResponse {
+		return t.ClaimedAt == nil && t.FirstResponseAt == nil
+	}
+	return true
+}
+
+func label(kind string) string {
+	if kind == models.SLAResponse {
+		return "respon"
+	}
+	return "penyelesaian"
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%.1fj", d.Hours())
+}
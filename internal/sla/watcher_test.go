@@ -0,0 +1,46 @@
+package sla
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch_RecordsBreachesOnce(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	db.Create(&requester)
+
+	now := time.Now()
+	created := now.Add(-20 * time.Minute)
+	responseDue := created.Add(5 * time.Minute)
+	resolutionDue := created.Add(15 * time.Minute)
+	late := models.Ticket{Subject: "Mixer mati", Location: models.LocationStudio1, RequesterID: requester.ID,
+		CreatedAt: created, ResponseDueAt: &responseDue, ResolutionDueAt: &resolutionDue}
+	db.Create(&late)
+
+	// 9 of 10 minutes gone: at risk, not breached
+	riskyResolutionDue := now.Add(time.Minute)
+	risky := models.Ticket{Subject: "Lampu kedip", Location: models.LocationStudio2, RequesterID: requester.ID,
+		CreatedAt: now.Add(-9 * time.Minute), ResolutionDueAt: &riskyResolutionDue}
+	db.Create(&risky)
+
+	assert.NoError(t, Watch(db, now))
+	assert.NoError(t, Watch(db, now)) // Second tick must not duplicate anything
+
+	var breaches []models.SLABreach
+	db.Order("kind").Find(&breaches)
+	if assert.Len(t, breaches, 2) {
+		assert.Equal(t, models.SLAResolution, breaches[0].Kind)
+		assert.Equal(t, models.SLAResponse, breaches[1].Kind)
+		assert.Equal(t, late.ID, breaches[0].TicketID)
+	}
+
+	var reloaded models.Ticket
+	db.First(&reloaded, "id = ?", risky.ID)
+	assert.NotNil(t, reloaded.ResolutionAtRiskAt)
+}
@@ -0,0 +1,234 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Errors are user-facing, handlers return err.Error() as-is
+var (
+	ErrTicketNotFound  = errors.New("Tiket tidak ditemukan")
+	ErrTicketFinished  = errors.New("Tiket sudah selesai")
+	ErrAlreadyClaimed  = errors.New("Tiket sudah diambil staff lain")
+	ErrNotAssigned     = errors.New("Tiket belum di-assign")
+	ErrInvalidAssignee = errors.New("Assignee harus staff atau manager yang aktif")
+	ErrNotAllowed      = errors.New("Anda tidak berhak mengubah assignee tiket ini")
+	ErrSameAssignee    = errors.New("Tiket sudah di-assign ke user tersebut")
+)
+
+// Claim assigns an unassigned ticket to the staff member taking it.
+// The claim is atomic: if two staff claim at the same time, only the first wins.
+// A first claim tells the requester their ticket is being handled.
+func Claim(ticketID, staffID uuid.UUID) (*models.Ticket, error) {
+	var ticket models.Ticket
+	var push *Push
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockTicket(tx, ticketID, &ticket); err != nil {
+			return err
+		}
+		if ticket.CurrentAssigneeID != nil {
+			if *ticket.CurrentAssigneeID == staffID {
+				return ErrSameAssignee
+			}
+			return ErrAlreadyClaimed
+		}
+		staff, err := activeStaff(tx, staffID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		// The first claim is the first response unless staff already replied
+		firstResponse := ticket.ClaimedAt == nil && ticket.FirstResponseAt == nil
+		updates := map[string]interface{}{"current_assignee_id": staffID}
+		if ticket.ClaimedAt == nil {
+			updates["claimed_at"] = now
+			ticket.ClaimedAt = &now
+		}
+		if err := tx.Model(&ticket).Updates(updates).Error; err != nil {
+			return err
+		}
+		ticket.CurrentAssigneeID = &staffID
+
+		if err := tx.Create(&models.TicketActivity{
+			TicketID:   ticket.ID,
+			ActorID:    staffID,
+			ActionType: models.ActivityClaim,
+			NewValue:   staffID.String(),
+			Note:       staff.FullName + " mengambil tiket ini",
+			CreatedAt:  now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := acknowledge(tx, &ticket, staffID, now); err != nil {
+			return err
+		}
+		if firstResponse {
+			push, err = notifyRequester(tx, Event{Kind: EventFirstResponse, Ticket: &ticket, ActorID: staffID})
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	push.Send()
+	return &ticket, nil
+}
+
+// Assign gives the ticket to assigneeID on behalf of actorID (a manager, or the current assignee passing it on).
+// The assignee is notified by push unless they assigned themselves.
+func Assign(ticketID, assigneeID, actorID uuid.UUID) (*models.Ticket, error) {
+	var ticket models.Ticket
+	var assignee *models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockTicket(tx, ticketID, &ticket); err != nil {
+			return err
+		}
+		if ticket.CurrentAssigneeID != nil && *ticket.CurrentAssigneeID == assigneeID {
+			return ErrSameAssignee
+		}
+		actor, err := activeStaff(tx, actorID)
+		if err != nil {
+			return ErrNotAllowed
+		}
+		if !CanReassign(&ticket, actor) {
+			return ErrNotAllowed
+		}
+		if assignee, err = activeStaff(tx, assigneeID); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&ticket).Update("current_assignee_id", assigneeID).Error; err != nil {
+			return err
+		}
+
+		previous := ""
+		if ticket.CurrentAssigneeID != nil {
+			previous = ticket.CurrentAssigneeID.String()
+		}
+		ticket.CurrentAssigneeID = &assigneeID
+
+		return tx.Create(&models.TicketActivity{
+			TicketID:      ticket.ID,
+			ActorID:       actorID,
+			ActionType:    models.ActivityAssign,
+			PreviousValue: previous,
+			NewValue:      assigneeID.String(),
+			Note:          fmt.Sprintf("%s meng-assign tiket ke %s", actor.FullName, assignee.FullName),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if assigneeID != actorID {
+		go notification.SendNotificationToUser(
+			assigneeID.String(),
+			fmt.Sprintf("📌 Tiket #%d di-assign ke Anda", ticket.TicketNumber),
+			ticket.Subject,
+			"/staff/tickets/"+ticket.ID.String(),
+			notification.ForTicket(ticket.ID, ticket.Priority),
+		)
+	}
+	return &ticket, nil
+}
+
+// Unassign returns the ticket to the unassigned pool.
+// Only the current assignee or a manager may do this.
+func Unassign(ticketID, actorID uuid.UUID) (*models.Ticket, error) {
+	var ticket models.Ticket
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockTicket(tx, ticketID, &ticket); err != nil {
+			return err
+		}
+		if ticket.CurrentAssigneeID == nil {
+			return ErrNotAssigned
+		}
+		actor, err := activeStaff(tx, actorID)
+		if err != nil {
+			return ErrNotAllowed
+		}
+		if !CanReassign(&ticket, actor) {
+			return ErrNotAllowed
+		}
+
+		if err := tx.Model(&ticket).Update("current_assignee_id", nil).Error; err != nil {
+			return err
+		}
+
+		previous := ticket.CurrentAssigneeID.String()
+		ticket.CurrentAssigneeID = nil
+
+		return tx.Create(&models.TicketActivity{
+			TicketID:      ticket.ID,
+			ActorID:       actorID,
+			ActionType:    models.ActivityUnassign,
+			PreviousValue: previous,
+			Note:          actor.FullName + " melepas tiket ke antrian",
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// CanReassign reports whether user may assign/unassign the ticket:
+// managers always, staff only while the ticket is theirs or still unassigned.
+func CanReassign(ticket *models.Ticket, user *models.User) bool {
+	if user.Role == models.RoleManager {
+		return true
+	}
+	if user.Role != models.RoleStaff {
+		return false
+	}
+	return ticket.CurrentAssigneeID == nil || *ticket.CurrentAssigneeID == user.ID
+}
+
+// AssignableStaff lists active staff and managers, for assignee dropdowns
+func AssignableStaff() []models.User {
+	var users []models.User
+	database.DB.Where("role IN ? AND is_active = ?", []models.UserRole{models.RoleStaff, models.RoleManager}, true).
+		Order("full_name asc").
+		Find(&users)
+	return users
+}
+
+// lockTicket loads an active (not RESOLVED/CLOSED) ticket for update
+func lockTicket(tx *gorm.DB, ticketID uuid.UUID, ticket *models.Ticket) error {
+	if err := lockTicketAnyStatus(tx, ticketID, ticket); err != nil {
+		return err
+	}
+	if ticket.Status == models.StatusResolved || ticket.Status == models.StatusClosed {
+		return ErrTicketFinished
+	}
+	return nil
+}
+
+// lockTicketAnyStatus loads the ticket with SELECT ... FOR UPDATE so concurrent changes serialize
+func lockTicketAnyStatus(tx *gorm.DB, ticketID uuid.UUID, ticket *models.Ticket) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(ticket, "id = ?", ticketID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTicketNotFound
+	}
+	return err
+}
+
+func activeStaff(tx *gorm.DB, userID uuid.UUID) (*models.User, error) {
+	var user models.User
+	err := tx.Where("id = ? AND is_active = ? AND role IN ?", userID, true,
+		[]models.UserRole{models.RoleStaff, models.RoleManager}).
+		First(&user).Error
+	if err != nil {
+		return nil, ErrInvalidAssignee
+	}
+	return &user, nil
+}
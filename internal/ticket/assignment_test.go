@@ -0,0 +1,64 @@
+package ticket
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanReassign(t *testing.T) {
+	owner := &models.User{ID: uuid.New(), Role: models.RoleStaff}
+	other := &models.User{ID: uuid.New(), Role: models.RoleStaff}
+	manager := &models.User{ID: uuid.New(), Role: models.RoleManager}
+	consumer := &models.User{ID: uuid.New(), Role: models.RoleConsumer}
+
+	unassigned := &models.Ticket{}
+	owned := &models.Ticket{CurrentAssigneeID: &owner.ID}
+
+	assert.True(t, CanReassign(unassigned, other), "staff may assign an unassigned ticket")
+	assert.True(t, CanReassign(owned, owner), "assignee may pass the ticket on")
+	assert.False(t, CanReassign(owned, other), "staff may not take a colleague's ticket")
+	assert.True(t, CanReassign(owned, manager), "managers may always reassign")
+	assert.False(t, CanReassign(unassigned, consumer))
+}
+
+func TestClaim_OnlyFirstWins(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	first := models.User{Email: "first@example.com", FullName: "First", Role: models.RoleStaff, IsActive: true}
+	second := models.User{Email: "second@example.com", FullName: "Second", Role: models.RoleStaff, IsActive: true}
+	db.Create(&requester)
+	db.Create(&first)
+	db.Create(&second)
+
+	tk := models.Ticket{Subject: "Mic mati", Location: models.LocationStudio1, RequesterID: requester.ID}
+	db.Create(&tk)
+
+	claimed, err := Claim(tk.ID, first.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, *claimed.CurrentAssigneeID)
+	assert.NotNil(t, claimed.ClaimedAt)
+
+	_, err = Claim(tk.ID, second.ID)
+	assert.ErrorIs(t, err, ErrAlreadyClaimed)
+
+	// Unassign back to the pool keeps the original claimed_at for MTTA
+	_, err = Unassign(tk.ID, first.ID)
+	assert.NoError(t, err)
+	reclaimed, err := Claim(tk.ID, second.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, claimed.ClaimedAt.Unix(), reclaimed.ClaimedAt.Unix())
+
+	var activities []models.TicketActivity
+	db.Where("ticket_id = ?", tk.ID).Order("created_at asc").Find(&activities)
+	if assert.Len(t, activities, 3) {
+		assert.Equal(t, models.ActivityClaim, activities[0].ActionType)
+		assert.Equal(t, models.ActivityUnassign, activities[1].ActionType)
+		assert.Equal(t, first.ID.String(), activities[1].PreviousValue)
+		assert.Equal(t, second.ID.String(), activities[2].NewValue)
+	}
+}
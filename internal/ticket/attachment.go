@@ -0,0 +1,208 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/upload"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTooManyFiles = errors.New("Maksimal 5 lampiran per pesan")
+	ErrReplyEmpty   = errors.New("Tulis pesan atau lampirkan file")
+	ErrNotRequester = errors.New("Tiket ini bukan laporan Anda")
+)
+
+// MaxAttachments is how many files the report or a single chat message may carry
+const MaxAttachments = 5
+
+// NamedFile is a validated upload together with the name the client sent
+type NamedFile struct {
+	Name string
+	*upload.File
+}
+
+// FormFiles collects the files of the given multipart fields, e.g. the legacy
+// single "proof_image" field and the multi-file "attachments" field
+func FormFiles(form *multipart.Form, fields ...string) []*multipart.FileHeader {
+	if form == nil {
+		return nil
+	}
+	var headers []*multipart.FileHeader
+	for _, field := range fields {
+		headers = append(headers, form.File[field]...)
+	}
+	return headers
+}
+
+// ReadFiles validates every uploaded file before anything is stored, so a rejected
+// file never leaves the others half saved
+func ReadFiles(headers []*multipart.FileHeader) ([]NamedFile, error) {
+	if len(headers) > MaxAttachments {
+		return nil, ErrTooManyFiles
+	}
+	files := make([]NamedFile, 0, len(headers))
+	for _, h := range headers {
+		f, err := upload.ReadForm(h, upload.TicketFilePolicy)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(h.Filename), err)
+		}
+		files = append(files, NamedFile{Name: filepath.Base(h.Filename), File: f})
+	}
+	return files, nil
+}
+
+// storeFiles saves the files under tickets/<ticket id>/ and returns their records, not yet inserted
+func storeFiles(ticketID uuid.UUID, activityID *uuid.UUID, uploaderID uuid.UUID, files []NamedFile) ([]models.TicketAttachment, error) {
+	attachments := make([]models.TicketAttachment, 0, len(files))
+	for _, f := range files {
+		key, err := upload.Store("tickets/"+ticketID.String(), f.File)
+		if err != nil {
+			removeFiles(attachments)
+			return nil, err
+		}
+		attachments = append(attachments, models.TicketAttachment{
+			ID:          uuid.New(),
+			TicketID:    ticketID,
+			ActivityID:  activityID,
+			FileName:    f.Name,
+			ContentType: f.ContentType,
+			Size:        int64(len(f.Data)),
+			URL:         upload.URL(key),
+			UploaderID:  uploaderID,
+			CreatedAt:   time.Now(),
+		})
+	}
+	return attachments, nil
+}
+
+func removeFiles(attachments []models.TicketAttachment) {
+	for _, a := range attachments {
+		upload.Remove(a.URL)
+	}
+}
+
+// AttachToReport stores files sent with a new ticket. The first photo also becomes the
+// ticket's ProofImageURL, which lists and older pages show as its picture.
+func AttachToReport(t *models.Ticket, uploaderID uuid.UUID, files []NamedFile) ([]models.TicketAttachment, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	attachments, err := storeFiles(t.ID, nil, uploaderID, files)
+	if err != nil {
+		return nil, err
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attachments).Error; err != nil {
+			return err
+		}
+		for _, a := range attachments {
+			if strings.HasPrefix(a.ContentType, "image/") {
+				t.ProofImageURL = a.URL
+				return tx.Model(t).Update("proof_image_url", a.URL).Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		removeFiles(attachments)
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// Reply adds a chat message to the ticket. A message may be text, files or both.
+// Consumers may only write in their own tickets, staff and managers in every ticket.
+func Reply(ticketID, actorID uuid.UUID, note string, files []NamedFile) (*models.TicketActivity, []models.TicketAttachment, error) {
+	note = strings.TrimSpace(note)
+	if note == "" && len(files) == 0 {
+		return nil, nil, ErrReplyEmpty
+	}
+	if len(files) > MaxAttachments {
+		return nil, nil, ErrTooManyFiles
+	}
+	var t models.Ticket
+	if err := database.DB.First(&t, "id = ?", ticketID).Error; err != nil {
+		return nil, nil, ErrTicketNotFound
+	}
+	_, err := activeStaff(database.DB, actorID)
+	fromStaff := err == nil
+	if !fromStaff && actorID != t.RequesterID {
+		return nil, nil, ErrNotRequester
+	}
+
+	activity := models.TicketActivity{
+		ID:         uuid.New(),
+		TicketID:   ticketID,
+		ActorID:    actorID,
+		ActionType: "REPLY",
+		Note:       note,
+		CreatedAt:  time.Now(),
+	}
+	attachments, err := storeFiles(ticketID, &activity.ID, actorID, files)
+	if err != nil {
+		return nil, nil, err
+	}
+	var push *Push
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&activity).Error; err != nil {
+			return err
+		}
+		if len(attachments) > 0 {
+			if err := tx.Create(&attachments).Error; err != nil {
+				return err
+			}
+		}
+		// The requester hears about messages from IT staff, never about their own
+		if !fromStaff || actorID == t.RequesterID {
+			return nil
+		}
+		var err error
+		push, err = notifyRequester(tx, Event{Kind: EventStaffReply, Ticket: &t, ActorID: actorID,
+			Message: replyText(note, attachments)})
+		return err
+	})
+	if err != nil {
+		removeFiles(attachments)
+		return nil, nil, err
+	}
+	push.Send()
+	return &activity, attachments, nil
+}
+
+// replyText is the reply as quoted in the email, files mentioned by name
+func replyText(note string, attachments []models.TicketAttachment) string {
+	if len(attachments) == 0 {
+		return note
+	}
+	names := make([]string, len(attachments))
+	for i, a := range attachments {
+		names[i] = a.FileName
+	}
+	return strings.TrimSpace(note + "\n\n📎 " + strings.Join(names, ", "))
+}
+
+// Attachments returns the files sent with the report and, per activity, those sent in the chat
+func Attachments(ticketID uuid.UUID) ([]models.TicketAttachment, map[uuid.UUID][]models.TicketAttachment) {
+	var all []models.TicketAttachment
+	database.DB.Where("ticket_id = ?", ticketID).Order("created_at asc").Find(&all)
+
+	var report []models.TicketAttachment
+	byActivity := make(map[uuid.UUID][]models.TicketAttachment)
+	for _, a := range all {
+		if a.ActivityID == nil {
+			report = append(report, a)
+		} else {
+			byActivity[*a.ActivityID] = append(byActivity[*a.ActivityID], a)
+		}
+	}
+	return report, byActivity
+}
@@ -0,0 +1,99 @@
+package ticket
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"it-broadcast-ops/internal/upload"
+	"mime/multipart"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// formFiles builds multipart file headers the way a browser upload arrives
+func formFiles(t *testing.T, files map[string][]byte) []*multipart.FileHeader {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, data := range files {
+		part, err := w.CreateFormFile("attachments", name)
+		assert.NoError(t, err)
+		part.Write(data)
+	}
+	assert.NoError(t, w.Close())
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	assert.NoError(t, err)
+	return FormFiles(form, "proof_image", "attachments")
+}
+
+func pngBytes(t *testing.T) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))))
+	return buf.Bytes()
+}
+
+func TestReadFiles_ValidatesEveryFile(t *testing.T) {
+	_, err := ReadFiles(formFiles(t, map[string][]byte{
+		"kamera.png": pngBytes(t),
+		"virus.jpg":  []byte("MZ\x90\x00 not a photo"),
+	}))
+	assert.ErrorIs(t, err, upload.ErrType)
+	assert.True(t, strings.HasPrefix(err.Error(), "virus.jpg: "), "names the rejected file")
+
+	many := map[string][]byte{}
+	for _, name := range []string{"1.png", "2.png", "3.png", "4.png", "5.png", "6.png"} {
+		many[name] = pngBytes(t)
+	}
+	_, err = ReadFiles(formFiles(t, many))
+	assert.ErrorIs(t, err, ErrTooManyFiles)
+
+	files, err := ReadFiles(formFiles(t, map[string][]byte{"kamera.png": pngBytes(t)}))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "kamera.png", files[0].Name)
+	assert.Equal(t, "image/png", files[0].ContentType)
+}
+
+func TestReply_WithAttachments(t *testing.T) {
+	db := testutil.SetupTestDB()
+	upload.SetStorage(upload.NewLocalStorage(t.TempDir()))
+	defer upload.SetStorage(upload.NewLocalStorage("data/uploads"))
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	staff := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&requester)
+	db.Create(&staff)
+	tk := models.Ticket{Subject: "Monitor PGM berkedip", Location: models.LocationStudio1, RequesterID: requester.ID}
+	db.Create(&tk)
+
+	files, err := ReadFiles(formFiles(t, map[string][]byte{"monitor.png": pngBytes(t)}))
+	assert.NoError(t, err)
+	report, err := AttachToReport(&tk, requester.ID, files)
+	assert.NoError(t, err)
+	assert.Len(t, report, 1)
+	assert.Equal(t, report[0].URL, tk.ProofImageURL, "first photo is the ticket's picture")
+	assert.True(t, strings.HasPrefix(report[0].URL, "/files/tickets/"+tk.ID.String()+"/"))
+
+	_, _, err = Reply(tk.ID, staff.ID, "  ", nil)
+	assert.ErrorIs(t, err, ErrReplyEmpty)
+
+	// Other consumers cannot write in someone else's ticket, let alone upload to it
+	stranger := models.User{Email: "stranger@example.com", FullName: "Stranger", Role: models.RoleConsumer, IsActive: true}
+	db.Create(&stranger)
+	_, _, err = Reply(tk.ID, stranger.ID, "Halo", files)
+	assert.ErrorIs(t, err, ErrNotRequester)
+
+	// A screenshot without text is a valid message
+	activity, sent, err := Reply(tk.ID, staff.ID, "", files)
+	assert.NoError(t, err)
+	assert.Len(t, sent, 1)
+	assert.Equal(t, activity.ID, *sent[0].ActivityID)
+
+	withReport, byActivity := Attachments(tk.ID)
+	assert.Len(t, withReport, 1)
+	assert.Len(t, byActivity[activity.ID], 1)
+}
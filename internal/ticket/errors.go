@@ -0,0 +1,33 @@
+package ticket
+
+import (
+	"errors"
+	"it-broadcast-ops/internal/upload"
+	"net/http"
+)
+
+// ErrorStatus maps service errors to HTTP status codes
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrTicketNotFound), errors.Is(err, ErrRatingToken):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNotAllowed), errors.Is(err, ErrNotHandoverOwner), errors.Is(err, ErrNotRequester):
+		return http.StatusForbidden
+	case errors.Is(err, ErrInvalidAssignee), errors.Is(err, ErrHandoverNote),
+		errors.Is(err, ErrHandoverTarget), errors.Is(err, ErrHandoverSelf),
+		errors.Is(err, ErrSolutionRequired), errors.Is(err, ErrReasonRequired),
+		errors.Is(err, ErrRatingScore), errors.Is(err, ErrReplyEmpty), errors.Is(err, ErrTooManyFiles):
+		return http.StatusBadRequest
+	case errors.Is(err, upload.ErrTooLarge), errors.Is(err, upload.ErrType):
+		return upload.ErrorStatus(err)
+	case errors.Is(err, ErrAlreadyClaimed), errors.Is(err, ErrSameAssignee),
+		errors.Is(err, ErrNotAssigned), errors.Is(err, ErrTicketFinished),
+		errors.Is(err, ErrHandoverPending), errors.Is(err, ErrNoHandover),
+		errors.Is(err, ErrNoNextShift), errors.Is(err, ErrInvalidTransition),
+		errors.Is(err, ErrNotRateable), errors.Is(err, ErrAlreadyRated),
+		errors.Is(err, ErrNotPaged), errors.Is(err, ErrAlreadyAcknowledged):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
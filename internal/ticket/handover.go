@@ -0,0 +1,240 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrHandoverNote     = errors.New("Catatan handover wajib diisi")
+	ErrHandoverTarget   = errors.New("Tujuan handover tidak valid")
+	ErrHandoverSelf     = errors.New("Tidak bisa handover ke diri sendiri")
+	ErrNoNextShift      = errors.New("Belum ada jadwal shift berikutnya")
+	ErrHandoverPending  = errors.New("Tiket masih menunggu konfirmasi handover")
+	ErrNoHandover       = errors.New("Tidak ada handover yang menunggu konfirmasi")
+	ErrNotHandoverOwner = errors.New("Handover ini ditujukan ke staff lain")
+)
+
+// HandoverRequest describes who a ticket is passed to.
+// ToUserID is only used for models.HandoverToUser.
+type HandoverRequest struct {
+	Target   string
+	ToUserID *uuid.UUID
+	Note     string
+}
+
+// Handover passes the ticket from fromID to a colleague, the next shift or the pool.
+// The ticket goes to HANDOVER and stays there until the recipient acknowledges it.
+func Handover(ticketID, fromID uuid.UUID, req HandoverRequest) (*models.TicketHandover, error) {
+	req.Note = strings.TrimSpace(req.Note)
+	if req.Note == "" {
+		return nil, ErrHandoverNote
+	}
+
+	var handover models.TicketHandover
+	var ticket models.Ticket
+	var from *models.User
+	var push *Push
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockTicket(tx, ticketID, &ticket); err != nil {
+			return err
+		}
+		if ticket.Status == models.StatusHandover {
+			return ErrHandoverPending
+		}
+		var err error
+		if from, err = activeStaff(tx, fromID); err != nil {
+			return ErrNotAllowed
+		}
+		if !CanReassign(&ticket, from) {
+			return ErrNotAllowed
+		}
+
+		toUserID, err := handoverRecipient(tx, fromID, req, time.Now())
+		if err != nil {
+			return err
+		}
+
+		handover = models.TicketHandover{
+			TicketID:     ticket.ID,
+			FromUserID:   fromID,
+			ToUserID:     toUserID,
+			Target:       req.Target,
+			HandoverNote: req.Note,
+		}
+		if err := tx.Create(&handover).Error; err != nil {
+			return err
+		}
+
+		if err := changeStatus(tx, &ticket, Change{
+			To:         models.StatusHandover,
+			ActorID:    fromID,
+			ActionType: models.ActivityHandover,
+			Note:       req.Note,
+			Updates: map[string]interface{}{
+				"is_handover":         true,
+				"current_assignee_id": toUserID,
+			},
+		}); err != nil {
+			return err
+		}
+		// The note is for the colleague, the requester only learns the ticket changed hands
+		push, err = notifyRequester(tx, Event{Kind: EventHandover, Ticket: &ticket, ActorID: fromID})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	push.Send()
+
+	title := fmt.Sprintf("🔁 Handover tiket #%d dari %s", ticket.TicketNumber, from.FullName)
+	url := "/staff/tickets/" + ticket.ID.String()
+	opts := notification.ForTicket(ticket.ID, ticket.Priority)
+	if handover.ToUserID != nil {
+		go notification.SendNotificationToUser(handover.ToUserID.String(), title, req.Note, url, opts)
+	} else {
+		go notification.SendBroadcastToStaff(title, req.Note, url, opts)
+	}
+	return &handover, nil
+}
+
+// AcknowledgeHandover accepts the pending handover of a ticket: the acknowledger becomes
+// the assignee and the ticket goes back to IN_PROGRESS. Pool handovers can be accepted by
+// any staff, named ones only by the recipient (or a manager).
+func AcknowledgeHandover(ticketID, userID uuid.UUID) (*models.TicketHandover, error) {
+	var handover models.TicketHandover
+	var ticket models.Ticket
+	var user *models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockTicket(tx, ticketID, &ticket); err != nil {
+			return err
+		}
+		if ticket.Status != models.StatusHandover {
+			return ErrNoHandover
+		}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("ticket_id = ? AND acknowledged_at IS NULL", ticketID).
+			Order("created_at desc").
+			First(&handover).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNoHandover
+		}
+		if err != nil {
+			return err
+		}
+
+		if user, err = activeStaff(tx, userID); err != nil {
+			return ErrNotAllowed
+		}
+		if handover.ToUserID != nil && *handover.ToUserID != userID && user.Role != models.RoleManager {
+			return ErrNotHandoverOwner
+		}
+
+		now := time.Now()
+		if err := tx.Model(&handover).Updates(map[string]interface{}{
+			"acknowledged_at":    now,
+			"acknowledged_by_id": userID,
+		}).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"current_assignee_id": userID}
+		if ticket.ClaimedAt == nil {
+			updates["claimed_at"] = now
+		}
+		return changeStatus(tx, &ticket, Change{
+			To:         models.StatusInProgress,
+			ActorID:    userID,
+			ActionType: models.ActivityHandoverAck,
+			Note:       user.FullName + " menerima handover",
+			Updates:    updates,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go notification.SendNotificationToUser(
+		handover.FromUserID.String(),
+		fmt.Sprintf("✅ Handover tiket #%d diterima", ticket.TicketNumber),
+		user.FullName+" melanjutkan tiket: "+ticket.Subject,
+		"/staff/tickets/"+ticket.ID.String(),
+		notification.ForTicket(ticket.ID, ticket.Priority),
+	)
+	return &handover, nil
+}
+
+// PendingHandover returns the handover of a ticket still waiting for acknowledgement, if any
+func PendingHandover(ticketID uuid.UUID) *models.TicketHandover {
+	var handover models.TicketHandover
+	err := database.DB.Preload("FromUser").Preload("ToUser").
+		Where("ticket_id = ? AND acknowledged_at IS NULL", ticketID).
+		Order("created_at desc").
+		First(&handover).Error
+	if err != nil {
+		return nil
+	}
+	return &handover
+}
+
+// handoverRecipient resolves the target of a handover to a user ID (nil = pool)
+func handoverRecipient(tx *gorm.DB, fromID uuid.UUID, req HandoverRequest, now time.Time) (*uuid.UUID, error) {
+	switch req.Target {
+	case models.HandoverToPool:
+		return nil, nil
+	case models.HandoverToUser:
+		if req.ToUserID == nil {
+			return nil, ErrHandoverTarget
+		}
+		if *req.ToUserID == fromID {
+			return nil, ErrHandoverSelf
+		}
+		to, err := activeStaff(tx, *req.ToUserID)
+		if err != nil {
+			return nil, err
+		}
+		return &to.ID, nil
+	case models.HandoverToNextShift:
+		shift, err := NextShift(tx, fromID, now)
+		if err != nil {
+			return nil, err
+		}
+		return &shift.UserID, nil
+	default:
+		return nil, ErrHandoverTarget
+	}
+}
+
+// NextShift finds the shift taking over once userID's current shift ends (or from now if
+// userID is not on shift): the earliest-starting shift of another staff member that is still
+// running at that moment. Overlapping shifts (14:00-22:00 after 07:00-15:00) qualify,
+// on-call standby shifts do not.
+func NextShift(tx *gorm.DB, userID uuid.UUID, now time.Time) (*models.Shift, error) {
+	from := now
+	var current models.Shift
+	if tx.Where("user_id = ? AND start_time <= ? AND end_time > ?", userID, now, now).
+		Order("end_time desc").
+		First(&current).Error == nil {
+		from = current.EndTime
+	}
+
+	var next models.Shift
+	err := tx.Preload("User").
+		Joins("JOIN users ON users.id = shifts.user_id AND users.is_active = ?", true).
+		Where("shifts.user_id <> ? AND shifts.end_time > ? AND shifts.on_call = ?", userID, from, false).
+		Order("shifts.start_time asc").
+		First(&next).Error
+	if err != nil {
+		return nil, ErrNoNextShift
+	}
+	return &next, nil
+}
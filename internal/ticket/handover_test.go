@@ -0,0 +1,63 @@
+package ticket
+
+import (
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandover_NextShiftAndAcknowledge(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	pagi := models.User{Email: "pagi@example.com", FullName: "Staff Pagi", Role: models.RoleStaff, IsActive: true}
+	siang := models.User{Email: "siang@example.com", FullName: "Staff Siang", Role: models.RoleStaff, IsActive: true}
+	db.Create(&requester)
+	db.Create(&pagi)
+	db.Create(&siang)
+
+	now := time.Now()
+	db.Create(&models.Shift{UserID: pagi.ID, StartTime: now.Add(-7 * time.Hour), EndTime: now.Add(time.Hour), Label: "Pagi"})
+	db.Create(&models.Shift{UserID: siang.ID, StartTime: now.Add(30 * time.Minute), EndTime: now.Add(8 * time.Hour), Label: "Siang"})
+
+	tk := models.Ticket{Subject: "Encoder freeze", Location: models.LocationMCR, RequesterID: requester.ID}
+	db.Create(&tk)
+	_, err := Claim(tk.ID, pagi.ID)
+	assert.NoError(t, err)
+
+	handover, err := Handover(tk.ID, pagi.ID, HandoverRequest{Target: models.HandoverToNextShift, Note: "Sudah restart, masih freeze"})
+	assert.NoError(t, err)
+	if assert.NotNil(t, handover.ToUserID) {
+		assert.Equal(t, siang.ID, *handover.ToUserID)
+	}
+
+	var reloaded models.Ticket
+	database.DB.First(&reloaded, "id = ?", tk.ID)
+	assert.Equal(t, models.StatusHandover, reloaded.Status)
+	assert.True(t, reloaded.IsHandover)
+
+	// A second handover must wait for the first to be acknowledged
+	_, err = Handover(tk.ID, siang.ID, HandoverRequest{Target: models.HandoverToPool, Note: "x"})
+	assert.ErrorIs(t, err, ErrHandoverPending)
+
+	// Only the named recipient can acknowledge
+	_, err = AcknowledgeHandover(tk.ID, pagi.ID)
+	assert.ErrorIs(t, err, ErrNotHandoverOwner)
+
+	_, err = AcknowledgeHandover(tk.ID, siang.ID)
+	assert.NoError(t, err)
+
+	database.DB.First(&reloaded, "id = ?", tk.ID)
+	assert.Equal(t, models.StatusInProgress, reloaded.Status)
+	assert.Equal(t, siang.ID, *reloaded.CurrentAssigneeID)
+	assert.Nil(t, PendingHandover(tk.ID))
+}
+
+func TestHandover_RequiresNote(t *testing.T) {
+	_, err := Handover(models.Ticket{}.ID, models.User{}.ID, HandoverRequest{Target: models.HandoverToPool, Note: "  "})
+	assert.ErrorIs(t, err, ErrHandoverNote)
+}
@@ -0,0 +1,269 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidTransition = errors.New("Perubahan status tiket tidak diizinkan")
+	ErrSolutionRequired  = errors.New("Solusi wajib diisi")
+	ErrReasonRequired    = errors.New("Alasan wajib diisi")
+)
+
+// transitions is the ticket state machine:
+//
+//	OPEN -> IN_PROGRESS -> HANDOVER -> IN_PROGRESS (acknowledged) -> RESOLVED -> CLOSED
+//	RESOLVED -> IN_PROGRESS (reopen)
+//
+// OPEN tickets may also be handed over or resolved straight away. CLOSED is final.
+var transitions = map[models.TicketStatus][]models.TicketStatus{
+	models.StatusOpen:       {models.StatusInProgress, models.StatusHandover, models.StatusResolved},
+	models.StatusInProgress: {models.StatusHandover, models.StatusResolved},
+	models.StatusHandover:   {models.StatusInProgress},
+	models.StatusResolved:   {models.StatusClosed, models.StatusInProgress},
+	models.StatusClosed:     {},
+}
+
+// CanTransition reports whether a ticket may move from one status to another
+func CanTransition(from, to models.TicketStatus) bool {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Change describes a status change and the activity recorded for it
+type Change struct {
+	To         models.TicketStatus
+	ActorID    uuid.UUID
+	ActionType string                 // Defaults to models.ActivityStatusChange
+	Note       string                 // Shown in the ticket timeline
+	Updates    map[string]interface{} // Extra columns written together with the status
+}
+
+// changeStatus is the single place where tickets.status is written.
+// It validates the move, stamps the lifecycle timestamps and records a TicketActivity
+// with the previous and new status. ticket must be locked by the caller.
+func changeStatus(tx *gorm.DB, ticket *models.Ticket, change Change) error {
+	from := ticket.Status
+	if !CanTransition(from, change.To) {
+		return fmt.Errorf("%w: %s → %s", ErrInvalidTransition, from, change.To)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": change.To}
+	for k, v := range change.Updates {
+		updates[k] = v
+	}
+	switch change.To {
+	case models.StatusInProgress:
+		if ticket.FirstResponseAt == nil {
+			updates["first_response_at"] = now
+		}
+		if from == models.StatusResolved {
+			// Reopened: MTTR is measured again from the next resolution
+			updates["resolved_at"] = nil
+		}
+	case models.StatusResolved:
+		updates["resolved_at"] = now
+		updates["reopen_reminder_at"] = nil
+	case models.StatusClosed:
+		updates["closed_at"] = now
+	}
+
+	if err := tx.Model(ticket).Updates(updates).Error; err != nil {
+		return err
+	}
+	ticket.Status = change.To
+
+	actionType := change.ActionType
+	if actionType == "" {
+		actionType = models.ActivityStatusChange
+	}
+	if err := tx.Create(&models.TicketActivity{
+		TicketID:      ticket.ID,
+		ActorID:       change.ActorID,
+		ActionType:    actionType,
+		PreviousValue: string(from),
+		NewValue:      string(change.To),
+		Note:          change.Note,
+		CreatedAt:     now,
+	}).Error; err != nil {
+		return err
+	}
+	// Staff moving an urgent ticket on have answered its page
+	if from == models.StatusOpen && change.ActorID != ticket.RequesterID {
+		return acknowledge(tx, ticket, change.ActorID, now)
+	}
+	return nil
+}
+
+// transition locks the ticket and applies change in its own transaction.
+// after runs in the same transaction once the status has changed.
+func transition(ticketID uuid.UUID, change Change, after ...func(tx *gorm.DB, ticket *models.Ticket) error) (*models.Ticket, error) {
+	var ticket models.Ticket
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockTicketAnyStatus(tx, ticketID, &ticket); err != nil {
+			return err
+		}
+		if err := changeStatus(tx, &ticket, change); err != nil {
+			return err
+		}
+		for _, fn := range after {
+			if err := fn(tx, &ticket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// StartProgress moves an OPEN ticket to IN_PROGRESS when staff first respond.
+// Tickets already past OPEN are left untouched.
+func StartProgress(ticketID, actorID uuid.UUID) (*models.Ticket, error) {
+	var ticket models.Ticket
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockTicketAnyStatus(tx, ticketID, &ticket); err != nil {
+			return err
+		}
+		if ticket.Status != models.StatusOpen {
+			return nil
+		}
+		return changeStatus(tx, &ticket, Change{
+			To:      models.StatusInProgress,
+			ActorID: actorID,
+			Note:    "Tiket mulai dikerjakan",
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// Resolve marks the ticket RESOLVED with the given solution and tells the requester
+func Resolve(ticketID, actorID uuid.UUID, solution string) (*models.Ticket, error) {
+	solution = strings.TrimSpace(solution)
+	if solution == "" {
+		return nil, ErrSolutionRequired
+	}
+	var push *Push
+	ticket, err := transition(ticketID, Change{
+		To:         models.StatusResolved,
+		ActorID:    actorID,
+		ActionType: models.ActivityResolve,
+		Note:       "Ticket Resolved. Solution: " + solution,
+		Updates:    map[string]interface{}{"solution": solution},
+	}, func(tx *gorm.DB, ticket *models.Ticket) (err error) {
+		push, err = notifyRequester(tx, Event{Kind: EventResolved, Ticket: ticket, ActorID: actorID, Message: solution})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	push.Send()
+	return ticket, nil
+}
+
+// Close moves a RESOLVED ticket to CLOSED
+func Close(ticketID, actorID uuid.UUID, note string) (*models.Ticket, error) {
+	return transition(ticketID, Change{
+		To:         models.StatusClosed,
+		ActorID:    actorID,
+		ActionType: models.ActivityClose,
+		Note:       note,
+	})
+}
+
+// Reopen sends a RESOLVED ticket back to IN_PROGRESS. The requester gets a confirmation,
+// which also reaches their other devices.
+func Reopen(ticketID, actorID uuid.UUID, reason string) (*models.Ticket, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	var push *Push
+	ticket, err := transition(ticketID, Change{
+		To:         models.StatusInProgress,
+		ActorID:    actorID,
+		ActionType: models.ActivityReopen,
+		Note:       reason,
+		Updates:    map[string]interface{}{"reopen_count": gorm.Expr("reopen_count + 1")},
+	}, func(tx *gorm.DB, ticket *models.Ticket) (err error) {
+		push, err = notifyRequester(tx, Event{Kind: EventReopened, Ticket: ticket, ActorID: actorID, Message: reason})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	push.Send()
+	return ticket, nil
+}
+
+// ResolverID returns who resolved the ticket most recently
+func ResolverID(ticketID uuid.UUID) (uuid.UUID, bool) {
+	var activity models.TicketActivity
+	err := database.DB.Where("ticket_id = ? AND action_type = ?", ticketID, models.ActivityResolve).
+		Order("created_at desc").
+		First(&activity).Error
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return activity.ActorID, true
+}
+
+// AutoCloseAfter is how long a RESOLVED ticket waits for the requester before it is closed
+// (TICKET_AUTO_CLOSE_DAYS, default 3).
+func AutoCloseAfter() time.Duration {
+	days := 3
+	if v, err := strconv.Atoi(os.Getenv("TICKET_AUTO_CLOSE_DAYS")); err == nil && v > 0 {
+		days = v
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// AutoCloseResolved closes tickets that stayed RESOLVED longer than AutoCloseAfter.
+// The close is recorded on behalf of the requester, who accepted the fix by not reopening it.
+func AutoCloseResolved(tx *gorm.DB, now time.Time) error {
+	// Rows a requester is reopening right now are skipped and picked up by a later run if still due
+	var tickets []models.Ticket
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND resolved_at <= ?", models.StatusResolved, now.Add(-AutoCloseAfter())).
+		Find(&tickets).Error; err != nil {
+		return err
+	}
+
+	for i := range tickets {
+		t := &tickets[i]
+		if err := changeStatus(tx, t, Change{
+			To:         models.StatusClosed,
+			ActorID:    t.RequesterID,
+			ActionType: models.ActivityClose,
+			Note:       fmt.Sprintf("Ditutup otomatis, tidak ada konfirmasi dalam %d hari", int(AutoCloseAfter().Hours()/24)),
+		}); err != nil {
+			return err
+		}
+	}
+	if len(tickets) > 0 {
+		log.Printf("[Ticket] 🔒 Auto-closed %d resolved ticket(s)", len(tickets))
+	}
+	return nil
+}
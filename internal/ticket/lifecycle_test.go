@@ -0,0 +1,78 @@
+package ticket
+
+import (
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	cases := []struct {
+		from, to models.TicketStatus
+		want     bool
+	}{
+		{models.StatusOpen, models.StatusInProgress, true},
+		{models.StatusInProgress, models.StatusHandover, true},
+		{models.StatusHandover, models.StatusInProgress, true},
+		{models.StatusInProgress, models.StatusResolved, true},
+		{models.StatusResolved, models.StatusClosed, true},
+		{models.StatusResolved, models.StatusInProgress, true}, // reopen
+		{models.StatusHandover, models.StatusResolved, false},  // must be acknowledged first
+		{models.StatusOpen, models.StatusClosed, false},
+		{models.StatusInProgress, models.StatusOpen, false},
+		{models.StatusClosed, models.StatusInProgress, false},
+		{models.StatusResolved, models.StatusResolved, false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, CanTransition(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+}
+
+func TestLifecycle_ResolveReopenAutoClose(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	staff := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&requester)
+	db.Create(&staff)
+	tk := models.Ticket{Subject: "Prompter mati", Location: models.LocationStudio2, RequesterID: requester.ID}
+	db.Create(&tk)
+
+	_, err := Close(tk.ID, staff.ID, "")
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+
+	resolved, err := Resolve(tk.ID, staff.ID, "Ganti kabel HDMI")
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusResolved, resolved.Status)
+
+	reopened, err := Reopen(tk.ID, requester.ID, "Masih mati")
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusInProgress, reopened.Status)
+
+	_, err = Resolve(tk.ID, staff.ID, "Ganti unit prompter")
+	assert.NoError(t, err)
+
+	// Not old enough yet
+	assert.NoError(t, AutoCloseResolved(database.DB, time.Now()))
+	var reloaded models.Ticket
+	database.DB.First(&reloaded, "id = ?", tk.ID)
+	assert.Equal(t, models.StatusResolved, reloaded.Status)
+
+	assert.NoError(t, AutoCloseResolved(database.DB, time.Now().Add(AutoCloseAfter()+time.Minute)))
+	database.DB.First(&reloaded, "id = ?", tk.ID)
+	assert.Equal(t, models.StatusClosed, reloaded.Status)
+	assert.NotNil(t, reloaded.ClosedAt)
+
+	// Every change left an activity with the previous and new status
+	var activities []models.TicketActivity
+	database.DB.Where("ticket_id = ?", tk.ID).Order("created_at asc").Find(&activities)
+	if assert.Len(t, activities, 4) {
+		assert.Equal(t, []string{"OPEN", "RESOLVED"}, []string{activities[0].PreviousValue, activities[0].NewValue})
+		assert.Equal(t, models.ActivityReopen, activities[1].ActionType)
+		assert.Equal(t, []string{"RESOLVED", "CLOSED"}, []string{activities[3].PreviousValue, activities[3].NewValue})
+	}
+}
@@ -0,0 +1,181 @@
+package ticket
+
+import (
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/email"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// reminderLead is how long before the auto-close the requester is reminded to reopen
+const reminderLead = 24 * time.Hour
+
+// Events the requester is told about. They double as the names of the email templates.
+const (
+	EventCreated        = email.TicketCreated
+	EventFirstResponse  = email.FirstResponse
+	EventStaffReply     = email.StaffReply
+	EventHandover       = email.Handover
+	EventResolved       = email.TicketResolved
+	EventReopened       = email.Reopened
+	EventReopenReminder = email.ReopenReminder
+)
+
+// Event is something that happened to a ticket. ActorID names who replied, resolved, ...
+// (uuid.Nil for the system), Message is the reply, solution or note.
+type Event struct {
+	Kind    string
+	Ticket  *models.Ticket
+	ActorID uuid.UUID
+	Message string
+}
+
+// Push is a web push decided inside a transaction, sent once it has committed
+type Push struct {
+	UserID  uuid.UUID
+	Title   string
+	Body    string
+	URL     string
+	Options notification.Options
+}
+
+// Send delivers the push in the background; a nil Push sends nothing
+func (p *Push) Send() {
+	if p != nil {
+		go notification.SendNotificationToUser(p.UserID.String(), p.Title, p.Body, p.URL, p.Options)
+	}
+}
+
+// notifyRequester tells the requester about ev: by web push when they subscribed a device,
+// otherwise by email. Emails are queued with tx; a returned Push must be sent after commit.
+func notifyRequester(tx *gorm.DB, ev Event) (*Push, error) {
+	t := ev.Ticket
+	var requester models.User
+	if err := tx.Select("id", "email", "full_name").First(&requester, "id = ?", t.RequesterID).Error; err != nil {
+		return nil, err
+	}
+	if t.TicketNumber == 0 {
+		tx.Model(t).Select("ticket_number").First(t)
+	}
+
+	data := email.TicketEmail{
+		RecipientName: requester.FullName,
+		TicketNumber:  t.TicketNumber,
+		Subject:       t.Subject,
+		Location:      string(t.Location),
+		Message:       ev.Message,
+		Link:          "/consumer?ticket=" + t.ID.String(),
+		CanReopen:     true,
+		AutoCloseAt:   autoCloseAt(t),
+		ActorName:     "Tim IT",
+	}
+	if t.PublicToken != "" {
+		// Public reporters have no account: their link shows the status and rating page
+		data.Link = "/report/rate/" + t.PublicToken
+		data.CanReopen = false
+	}
+	if ev.ActorID != uuid.Nil {
+		var actor models.User
+		if err := tx.Select("full_name").First(&actor, "id = ?", ev.ActorID).Error; err == nil && actor.FullName != "" {
+			data.ActorName = actor.FullName
+		}
+	}
+
+	// The receipt of a new report always goes by email, the requester is looking at the app anyway
+	if ev.Kind != EventCreated {
+		var devices int64
+		tx.Model(&models.PushSubscription{}).Where("user_id = ?", requester.ID).Count(&devices)
+		if devices > 0 {
+			title, body := pushText(ev.Kind, data)
+			return &Push{UserID: requester.ID, Title: title, Body: body, URL: data.Link,
+				Options: notification.ForTicket(t.ID, t.Priority)}, nil
+		}
+	}
+
+	if requester.Email == "" {
+		return nil, nil
+	}
+	data.Link = email.BaseURL() + data.Link
+	return nil, email.Queue(tx, requester.Email, ev.Kind, data)
+}
+
+// pushText is the title and body of the push for an event
+func pushText(kind string, d email.TicketEmail) (string, string) {
+	switch kind {
+	case EventFirstResponse:
+		return fmt.Sprintf("👋 Tiket #%d sedang ditangani", d.TicketNumber), d.ActorName + " menangani laporan Anda: " + d.Subject
+	case EventStaffReply:
+		return fmt.Sprintf("💬 Balasan baru di tiket #%d", d.TicketNumber), d.ActorName + ": " + d.Message
+	case EventHandover:
+		return fmt.Sprintf("🔁 Tiket #%d dialihkan", d.TicketNumber), "Laporan Anda diteruskan ke rekan tim IT: " + d.Subject
+	case EventResolved:
+		return fmt.Sprintf("✅ Tiket #%d selesai", d.TicketNumber), "Solusi: " + d.Message
+	case EventReopened:
+		return fmt.Sprintf("↩️ Tiket #%d dibuka kembali", d.TicketNumber), "Tim IT akan menindaklanjuti: " + d.Subject
+	case EventReopenReminder:
+		return fmt.Sprintf("⏰ Tiket #%d segera ditutup", d.TicketNumber),
+			"Buka kembali sebelum " + d.AutoCloseAt.Format("02 Jan 15:04") + " jika masalah belum beres"
+	}
+	return fmt.Sprintf("Tiket #%d", d.TicketNumber), d.Subject
+}
+
+// notifyAfter is notifyRequester for a single event outside any transaction
+func notifyAfter(ev Event) {
+	push, err := notifyRequester(database.DB, ev)
+	if err != nil {
+		log.Printf("[Ticket] ⚠️  Notifying requester of ticket %s failed: %v", ev.Ticket.ID, err)
+		return
+	}
+	push.Send()
+}
+
+// autoCloseAt is when a RESOLVED ticket is closed by AutoCloseResolved
+func autoCloseAt(t *models.Ticket) time.Time {
+	if t.ResolvedAt == nil {
+		return time.Now().Add(AutoCloseAfter())
+	}
+	return t.ResolvedAt.Add(AutoCloseAfter())
+}
+
+// NotifyCreated emails the requester that their report was received
+func NotifyCreated(t *models.Ticket) {
+	notifyAfter(Event{Kind: EventCreated, Ticket: t})
+}
+
+// RemindResolved is the scheduler job reminding requesters, reminderLead before the auto-close,
+// that a RESOLVED ticket is about to be closed. Short auto-close periods get the reminder halfway.
+func RemindResolved(tx *gorm.DB, now time.Time) error {
+	lead := reminderLead
+	if AutoCloseAfter() <= lead {
+		lead = AutoCloseAfter() / 2
+	}
+	var tickets []models.Ticket
+	if err := tx.Where("status = ? AND reopen_reminder_at IS NULL AND resolved_at <= ?",
+		models.StatusResolved, now.Add(-(AutoCloseAfter() - lead))).
+		Find(&tickets).Error; err != nil {
+		return err
+	}
+
+	var pushes []*Push
+	for i := range tickets {
+		t := &tickets[i]
+		push, err := notifyRequester(tx, Event{Kind: EventReopenReminder, Ticket: t})
+		if err != nil {
+			return err
+		}
+		pushes = append(pushes, push)
+		if err := tx.Model(t).Update("reopen_reminder_at", now).Error; err != nil {
+			return err
+		}
+	}
+	for _, p := range pushes {
+		database.AfterCommit(tx, p.Send)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package ticket
+
+import (
+	"it-broadcast-ops/internal/email"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nopSender struct{}
+
+func (nopSender) Send(email.Message) error { return nil }
+
+func TestRequesterNotifications(t *testing.T) {
+	db := testutil.SetupTestDB()
+	email.SetSender(nopSender{})
+	defer email.SetSender(nil)
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	staff := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&requester)
+	db.Create(&staff)
+	ticket := models.Ticket{Subject: "Mic mati", Location: models.LocationStudio1, RequesterID: requester.ID,
+		Status: models.StatusOpen}
+	db.Create(&ticket)
+
+	emails := func() []string {
+		var names []string
+		db.Model(&models.OutboxEmail{}).Where("to_address = ?", requester.Email).Order("created_at asc").Pluck("template", &names)
+		return names
+	}
+
+	NotifyCreated(&ticket)
+	_, err := Claim(ticket.ID, staff.ID)
+	assert.NoError(t, err)
+	_, _, err = Reply(ticket.ID, requester.ID, "Masih mati", nil)
+	assert.NoError(t, err)
+	_, _, err = Reply(ticket.ID, staff.ID, "Sedang dicek", nil)
+	assert.NoError(t, err)
+	_, err = Handover(ticket.ID, staff.ID, HandoverRequest{Target: models.HandoverToPool, Note: "Lanjut shift siang"})
+	assert.NoError(t, err)
+	_, err = AcknowledgeHandover(ticket.ID, staff.ID)
+	assert.NoError(t, err)
+	_, err = Resolve(ticket.ID, staff.ID, "Ganti baterai")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{EventCreated, EventFirstResponse, EventStaffReply, EventHandover, EventResolved}, emails(),
+		"the requester's own messages are not sent back")
+
+	// The reminder goes out once, a day before the auto-close
+	db.First(&ticket, "id = ?", ticket.ID)
+	assert.NoError(t, RemindResolved(db, ticket.ResolvedAt.Add(time.Hour)))
+	assert.Len(t, emails(), 5)
+	remindAt := ticket.ResolvedAt.Add(AutoCloseAfter() - reminderLead)
+	assert.NoError(t, RemindResolved(db, remindAt))
+	assert.NoError(t, RemindResolved(db, remindAt.Add(time.Hour)))
+	assert.Len(t, emails(), 6)
+	assert.Equal(t, EventReopenReminder, emails()[5])
+
+	// A requester with a subscribed device is told by push instead of email
+	db.Create(&models.PushSubscription{UserID: requester.ID, Endpoint: "https://push.example.com/1", P256dh: "k", Auth: "a"})
+	push, err := notifyRequester(db, Event{Kind: EventReopened, Ticket: &ticket, ActorID: requester.ID, Message: "Masih mati"})
+	assert.NoError(t, err)
+	if assert.NotNil(t, push) {
+		assert.Equal(t, requester.ID, push.UserID)
+		assert.Equal(t, "/consumer?ticket="+ticket.ID.String(), push.URL)
+	}
+	assert.Len(t, emails(), 6)
+}
+
+func TestNotifyRequester_PublicReporterLink(t *testing.T) {
+	db := testutil.SetupTestDB()
+	email.SetSender(nopSender{})
+	defer email.SetSender(nil)
+
+	guest := models.User{Email: "tamu@example.com", FullName: "Tamu", Role: models.RoleConsumer, IsActive: true}
+	db.Create(&guest)
+	ticket := models.Ticket{Subject: "Lampu studio mati", Location: models.LocationStudio1, RequesterID: guest.ID,
+		PublicToken: "tok123"}
+	db.Create(&ticket)
+
+	_, err := notifyRequester(db, Event{Kind: EventStaffReply, Ticket: &ticket, Message: "Teknisi menuju lokasi"})
+	assert.NoError(t, err)
+	var queued models.OutboxEmail
+	db.First(&queued, "to_address = ?", guest.Email)
+	assert.Contains(t, queued.TextBody, email.BaseURL()+"/report/rate/tok123")
+	assert.Contains(t, queued.TextBody, "Tim IT membalas")
+}
+
+func TestReply_NotifiesOnlyForStaffMessages(t *testing.T) {
+	db := testutil.SetupTestDB()
+	email.SetSender(nopSender{})
+	defer email.SetSender(nil)
+
+	reporter := models.User{Email: "reporter@example.com", FullName: "Reporter", Role: models.RoleStaff, IsActive: true}
+	manager := models.User{Email: "manager@example.com", FullName: "Manager", Role: models.RoleManager, IsActive: true}
+	db.Create(&reporter)
+	db.Create(&manager)
+	ticket := models.Ticket{Subject: "Switcher hang", Location: models.LocationStudio1, RequesterID: reporter.ID,
+		Status: models.StatusInProgress}
+	db.Create(&ticket)
+
+	count := func() int64 {
+		var n int64
+		db.Model(&models.OutboxEmail{}).Where("to_address = ? AND template = ?", reporter.Email, EventStaffReply).Count(&n)
+		return n
+	}
+
+	// A staff member reporting their own problem is not told about their own message
+	_, _, err := Reply(ticket.ID, reporter.ID, "Sudah restart", nil)
+	assert.NoError(t, err)
+	assert.Zero(t, count())
+	_, _, err = Reply(ticket.ID, manager.ID, "Vendor dihubungi", nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, count())
+}
@@ -0,0 +1,107 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRatingScore  = errors.New("Nilai harus antara 1 sampai 5")
+	ErrNotRateable  = errors.New("Tiket belum selesai, belum bisa dinilai")
+	ErrAlreadyRated = errors.New("Tiket ini sudah dinilai")
+	ErrRatingToken  = errors.New("Link penilaian tidak valid")
+)
+
+// CanRate reports whether the ticket is finished and can receive a rating
+func CanRate(t *models.Ticket) bool {
+	return t.Status == models.StatusResolved || t.Status == models.StatusClosed
+}
+
+// Rate stores the requester's CSAT score for a RESOLVED or CLOSED ticket.
+// The rating is credited to whoever resolved the ticket last.
+func Rate(ticketID, requesterID uuid.UUID, score int, comment string) (*models.TicketRating, error) {
+	if score < 1 || score > 5 {
+		return nil, ErrRatingScore
+	}
+
+	var rating models.TicketRating
+	var ticket models.Ticket
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockTicketAnyStatus(tx, ticketID, &ticket); err != nil {
+			return err
+		}
+		if ticket.RequesterID != requesterID {
+			return ErrNotAllowed
+		}
+		if !CanRate(&ticket) {
+			return ErrNotRateable
+		}
+		var count int64
+		tx.Model(&models.TicketRating{}).Where("ticket_id = ?", ticketID).Count(&count)
+		if count > 0 {
+			return ErrAlreadyRated
+		}
+
+		rating = models.TicketRating{
+			TicketID:    ticketID,
+			RequesterID: requesterID,
+			Score:       score,
+			Comment:     strings.TrimSpace(comment),
+		}
+		if resolverID, ok := ResolverID(ticketID); ok {
+			rating.ResolverID = &resolverID
+		}
+		return tx.Create(&rating).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rating.ResolverID != nil {
+		go notification.SendNotificationToUser(
+			rating.ResolverID.String(),
+			fmt.Sprintf("%s Tiket #%d dinilai %d/5", strings.Repeat("⭐", score), ticket.TicketNumber, score),
+			rating.Comment,
+			"/staff/tickets/"+ticket.ID.String(),
+			notification.ForTicket(ticket.ID, ticket.Priority),
+		)
+	}
+	return &rating, nil
+}
+
+// RateByToken rates the ticket behind a public reporter's link on behalf of its requester
+func RateByToken(token string, score int, comment string) (*models.TicketRating, error) {
+	t, err := TicketByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return Rate(t.ID, t.RequesterID, score, comment)
+}
+
+// TicketByToken finds the ticket a public link points to
+func TicketByToken(token string) (*models.Ticket, error) {
+	if token == "" {
+		return nil, ErrRatingToken
+	}
+	var t models.Ticket
+	if err := database.DB.Where("public_token = ?", token).First(&t).Error; err != nil {
+		return nil, ErrRatingToken
+	}
+	return &t, nil
+}
+
+// RatingFor returns the rating of a ticket, or nil if it has not been rated
+func RatingFor(ticketID uuid.UUID) *models.TicketRating {
+	var rating models.TicketRating
+	if err := database.DB.Where("ticket_id = ?", ticketID).First(&rating).Error; err != nil {
+		return nil
+	}
+	return &rating
+}
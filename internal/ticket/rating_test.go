@@ -0,0 +1,54 @@
+package ticket
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRate_ScoreRange(t *testing.T) {
+	for _, score := range []int{0, 6, -1} {
+		_, err := Rate(uuid.New(), uuid.New(), score, "")
+		assert.ErrorIs(t, err, ErrRatingScore)
+	}
+}
+
+func TestRate_CreditsResolverOnce(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	staff := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&requester)
+	db.Create(&staff)
+
+	tk := models.Ticket{Subject: "Prompter mati", Location: models.LocationMCR, RequesterID: requester.ID, PublicToken: "abc123"}
+	db.Create(&tk)
+
+	// Not finished yet
+	_, err := RateByToken("abc123", 5, "")
+	assert.ErrorIs(t, err, ErrNotRateable)
+
+	_, err = Resolve(tk.ID, staff.ID, "Ganti kabel HDMI")
+	assert.NoError(t, err)
+
+	// Only the requester may rate
+	_, err = Rate(tk.ID, staff.ID, 5, "")
+	assert.ErrorIs(t, err, ErrNotAllowed)
+
+	rating, err := RateByToken("abc123", 4, " Cepat ")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, rating.Score)
+	assert.Equal(t, "Cepat", rating.Comment)
+	if assert.NotNil(t, rating.ResolverID) {
+		assert.Equal(t, staff.ID, *rating.ResolverID)
+	}
+
+	_, err = Rate(tk.ID, requester.ID, 5, "")
+	assert.ErrorIs(t, err, ErrAlreadyRated)
+
+	_, err = RateByToken("wrong", 5, "")
+	assert.ErrorIs(t, err, ErrRatingToken)
+}
@@ -0,0 +1,297 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotPaged            = errors.New("Tiket ini tidak sedang memanggil staff")
+	ErrAlreadyAcknowledged = errors.New("Panggilan sudah diterima staff lain")
+)
+
+// envMinutes reads a duration in minutes from the environment
+func envMinutes(name string, def int) time.Duration {
+	minutes := def
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		minutes = v
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// PageRepeat is how often an unacknowledged urgent ticket is paged again (URGENT_PAGE_REPEAT_MINUTES, default 3)
+func PageRepeat() time.Duration { return envMinutes("URGENT_PAGE_REPEAT_MINUTES", 3) }
+
+// OnCallAfter is when on-call staff join the page (URGENT_ONCALL_AFTER_MINUTES, default 5)
+func OnCallAfter() time.Duration { return envMinutes("URGENT_ONCALL_AFTER_MINUTES", 5) }
+
+// EscalateAfter is when the managers join the page (URGENT_ESCALATE_AFTER_MINUTES, default 15)
+func EscalateAfter() time.Duration { return envMinutes("URGENT_ESCALATE_AFTER_MINUTES", 15) }
+
+// rung is one step of the paging ladder: from After on, Route is paged together with the earlier rungs
+type rung struct {
+	Tier  string
+	After time.Duration
+	Route func(tx *gorm.DB, now time.Time) (notification.Route, error)
+}
+
+func ladder() []rung {
+	return []rung{
+		{Tier: notification.TierOnShift, After: 0, Route: notification.StaffRoute},
+		{Tier: notification.TierOnCall, After: OnCallAfter(), Route: notification.OnCallRoute},
+		{Tier: notification.TierManagers, After: EscalateAfter(), Route: func(tx *gorm.DB, _ time.Time) (notification.Route, error) {
+			ids, err := notification.Managers(tx)
+			return notification.Route{Tier: notification.TierManagers, UserIDs: ids}, err
+		}},
+	}
+}
+
+// rungFor is the highest rung reached after waiting elapsed
+func rungFor(elapsed time.Duration) int {
+	current := 0
+	for i, r := range ladder() {
+		if elapsed >= r.After {
+			current = i
+		}
+	}
+	return current
+}
+
+func rungIndex(tier string) int {
+	for i, r := range ladder() {
+		if r.Tier == tier {
+			return i
+		}
+	}
+	return -1
+}
+
+// page is a page decided inside a transaction, sent once it has committed
+type page struct {
+	route  notification.Route
+	ticket models.Ticket
+}
+
+func (p *page) send() {
+	t := p.ticket
+	title := fmt.Sprintf("🔥 URGENT #%d: %s", t.TicketNumber, t.Location)
+	msg := t.Subject + " (ON AIR ISSUE)"
+	if t.PageCount > 1 {
+		title = fmt.Sprintf("🔥 URGENT #%d belum ditangani (panggilan ke-%d)", t.TicketNumber, t.PageCount)
+		msg = fmt.Sprintf("%s - %s, sudah %d menit", t.Subject, t.Location, int(time.Since(t.CreatedAt).Minutes()))
+	}
+	url := "/staff/tickets/" + t.ID.String()
+	go notification.SendPage(p.route, title, msg, url, url+"/ack", "urgent-"+t.ID.String(),
+		notification.ForTicket(t.ID, t.Priority))
+}
+
+// pageRung records a page of the ticket to every rung up to upTo and returns it for sending.
+// The ticket must be locked by the caller.
+func pageRung(tx *gorm.DB, t *models.Ticket, upTo int, now time.Time) (*page, error) {
+	route := notification.Route{Tier: ladder()[upTo].Tier}
+	seen := make(map[uuid.UUID]bool)
+	for _, r := range ladder()[:upTo+1] {
+		rr, err := r.Route(tx, now)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range rr.UserIDs {
+			if !seen[id] {
+				seen[id] = true
+				route.UserIDs = append(route.UserIDs, id)
+			}
+		}
+	}
+
+	t.PageCount++
+	t.LastPagedAt = &now
+	t.PageTier = route.Tier
+	if err := tx.Model(t).Updates(map[string]interface{}{
+		"page_count":    t.PageCount,
+		"last_paged_at": now,
+		"page_tier":     route.Tier,
+	}).Error; err != nil {
+		return nil, err
+	}
+	// Pages are system actions, recorded on behalf of the requester like the auto-close
+	if err := tx.Create(&models.TicketActivity{
+		TicketID:   t.ID,
+		ActorID:    t.RequesterID,
+		ActionType: models.ActivityPage,
+		NewValue:   route.Tier,
+		Note:       fmt.Sprintf("Panggilan ke-%d ke %s (%d orang)", t.PageCount, route.Tier, len(route.UserIDs)),
+		CreatedAt:  now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &page{route: route, ticket: *t}, nil
+}
+
+// StartPaging sends the first page of a new URGENT_ON_AIR ticket to the staff on shift.
+// PageUrgent repeats and escalates it until someone acknowledges.
+func StartPaging(ticketID uuid.UUID) {
+	var p *page
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var t models.Ticket
+		if err := lockTicketAnyStatus(tx, ticketID, &t); err != nil {
+			return err
+		}
+		if t.Priority != models.PriorityUrgentOnAir || t.PageCount > 0 {
+			return nil
+		}
+		var err error
+		p, err = pageRung(tx, &t, 0, time.Now())
+		return err
+	})
+	if err != nil {
+		log.Printf("[Ticket] ❌ Paging urgent ticket %s failed: %v", ticketID, err)
+		return
+	}
+	if p != nil {
+		p.send()
+	}
+}
+
+// PageUrgent is the scheduler job running the paging ladder: every PageRepeat an urgent ticket
+// nobody acknowledged is paged again, joined by on-call staff after OnCallAfter and by the
+// managers after EscalateAfter.
+func PageUrgent(tx *gorm.DB, now time.Time) error {
+	// A ticket being acknowledged or claimed right now is skipped, the next run sees it answered
+	var tickets []models.Ticket
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("priority = ? AND status IN ?", models.PriorityUrgentOnAir,
+		[]models.TicketStatus{models.StatusOpen, models.StatusInProgress, models.StatusHandover}).
+		Where("acknowledged_at IS NULL AND claimed_at IS NULL AND first_response_at IS NULL").
+		Find(&tickets).Error; err != nil {
+		return err
+	}
+
+	var pages []*page
+	for i := range tickets {
+		t := &tickets[i]
+		upTo := rungFor(now.Sub(t.CreatedAt))
+		escalated := upTo > rungIndex(t.PageTier)
+		due := t.LastPagedAt == nil || !t.LastPagedAt.Add(PageRepeat()).After(now)
+		if !escalated && !due {
+			continue
+		}
+		p, err := pageRung(tx, t, upTo, now)
+		if err != nil {
+			return err
+		}
+		pages = append(pages, p)
+		log.Printf("[Ticket] 📟 Page %d of urgent ticket #%d to %s (%d user)", t.PageCount, t.TicketNumber,
+			p.route.Tier, len(p.route.UserIDs))
+	}
+	for _, p := range pages {
+		database.AfterCommit(tx, p.send)
+	}
+	return nil
+}
+
+// IsPaging reports whether the ticket's page is still waiting for an answer
+func IsPaging(t *models.Ticket) bool {
+	return t.Priority == models.PriorityUrgentOnAir && t.AcknowledgedAt == nil &&
+		t.ClaimedAt == nil && t.FirstResponseAt == nil &&
+		t.Status != models.StatusResolved && t.Status != models.StatusClosed
+}
+
+// Acknowledge answers the page of an urgent ticket, which stops the ladder
+func Acknowledge(ticketID, userID uuid.UUID) (*models.Ticket, error) {
+	var t models.Ticket
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockTicket(tx, ticketID, &t); err != nil {
+			return err
+		}
+		if _, err := activeStaff(tx, userID); err != nil {
+			return ErrNotAllowed
+		}
+		if t.Priority != models.PriorityUrgentOnAir {
+			return ErrNotPaged
+		}
+		if t.AcknowledgedByID != nil {
+			if *t.AcknowledgedByID == userID {
+				return nil
+			}
+			return ErrAlreadyAcknowledged
+		}
+		return acknowledge(tx, &t, userID, time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// acknowledge stops the paging ladder of an urgent ticket and records who answered.
+// Claiming, replying to or otherwise moving the ticket on counts as answering too.
+func acknowledge(tx *gorm.DB, t *models.Ticket, userID uuid.UUID, now time.Time) error {
+	if t.Priority != models.PriorityUrgentOnAir || t.AcknowledgedAt != nil {
+		return nil
+	}
+	if err := tx.Model(t).Updates(map[string]interface{}{
+		"acknowledged_at":    now,
+		"acknowledged_by_id": userID,
+	}).Error; err != nil {
+		return err
+	}
+	t.AcknowledgedAt = &now
+	t.AcknowledgedByID = &userID
+	return tx.Create(&models.TicketActivity{
+		TicketID:   t.ID,
+		ActorID:    userID,
+		ActionType: models.ActivityAcknowledge,
+		Note:       fmt.Sprintf("Panggilan diterima setelah %d menit", int(now.Sub(t.CreatedAt).Minutes())),
+		CreatedAt:  now,
+	}).Error
+}
+
+// AckStats summarises how quickly urgent tickets created in a period were acknowledged
+type AckStats struct {
+	Urgent       int64   // Urgent tickets created
+	Acknowledged int64   // ... of which someone answered the page
+	AvgMinutes   float64 // Mean time to acknowledge
+	P90Minutes   float64 // 90% were acknowledged within this time
+	Escalated    int64   // Pages that reached the managers
+}
+
+// UrgentAckStats reports the time to acknowledge of urgent tickets created in [from, to)
+func UrgentAckStats(from, to time.Time) AckStats {
+	var stats AckStats
+	base := func() *gorm.DB {
+		return database.DB.Model(&models.Ticket{}).
+			Where("priority = ? AND created_at >= ? AND created_at < ?", models.PriorityUrgentOnAir, from, to)
+	}
+	base().Count(&stats.Urgent)
+	base().Where("page_tier = ?", notification.TierManagers).Count(&stats.Escalated)
+
+	var row struct {
+		Count int64
+		Avg   *float64
+		P90   *float64
+	}
+	base().Where("acknowledged_at IS NOT NULL").
+		Select("COUNT(*) AS count, " +
+			"AVG(EXTRACT(EPOCH FROM (acknowledged_at - created_at))/60) AS avg, " +
+			"percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM (acknowledged_at - created_at))/60) AS p90").
+		Scan(&row)
+	stats.Acknowledged = row.Count
+	if row.Avg != nil {
+		stats.AvgMinutes = *row.Avg
+	}
+	if row.P90 != nil {
+		stats.P90Minutes = *row.P90
+	}
+	return stats
+}
@@ -0,0 +1,101 @@
+package ticket
+
+import (
+	"it-broadcast-ops/internal/models"
+	"it-broadcast-ops/internal/notification"
+	"it-broadcast-ops/internal/testutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRungFor(t *testing.T) {
+	assert.Equal(t, 0, rungFor(0))
+	assert.Equal(t, 0, rungFor(OnCallAfter()-time.Second))
+	assert.Equal(t, 1, rungFor(OnCallAfter()))
+	assert.Equal(t, 2, rungFor(EscalateAfter()))
+	assert.Equal(t, 2, rungFor(24*time.Hour))
+	assert.Equal(t, notification.TierManagers, ladder()[rungFor(EscalateAfter())].Tier)
+}
+
+func TestPageUrgent_RepeatsAndEscalatesUntilAcknowledged(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	staff := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	other := models.User{Email: "other@example.com", FullName: "Other", Role: models.RoleStaff, IsActive: true}
+	db.Create(&requester)
+	db.Create(&staff)
+	db.Create(&other)
+
+	created := time.Now()
+	urgent := models.Ticket{Subject: "Playout mati", Location: models.LocationMCR, RequesterID: requester.ID,
+		Priority: models.PriorityUrgentOnAir, CreatedAt: created}
+	normal := models.Ticket{Subject: "Printer", Location: models.LocationMCR, RequesterID: requester.ID, CreatedAt: created}
+	db.Create(&urgent)
+	db.Create(&normal)
+
+	reload := func() models.Ticket {
+		var tk models.Ticket
+		db.First(&tk, "id = ?", urgent.ID)
+		return tk
+	}
+
+	assert.NoError(t, PageUrgent(db, created))
+	tk := reload()
+	assert.Equal(t, 1, tk.PageCount)
+	assert.Equal(t, notification.TierOnShift, tk.PageTier)
+
+	assert.NoError(t, PageUrgent(db, created.Add(PageRepeat()-time.Second)))
+	assert.Equal(t, 1, reload().PageCount, "no repeat before PageRepeat")
+
+	assert.NoError(t, PageUrgent(db, created.Add(PageRepeat())))
+	assert.Equal(t, 2, reload().PageCount)
+
+	assert.NoError(t, PageUrgent(db, created.Add(EscalateAfter())))
+	tk = reload()
+	assert.Equal(t, 3, tk.PageCount)
+	assert.Equal(t, notification.TierManagers, tk.PageTier)
+
+	var pages int64
+	db.Model(&models.TicketActivity{}).Where("ticket_id = ? AND action_type = ?", urgent.ID, models.ActivityPage).Count(&pages)
+	assert.Equal(t, int64(3), pages)
+	db.Model(&models.TicketActivity{}).Where("ticket_id = ? AND action_type = ?", normal.ID, models.ActivityPage).Count(&pages)
+	assert.Zero(t, pages, "only urgent tickets are paged")
+
+	acked, err := Acknowledge(urgent.ID, staff.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, staff.ID, *acked.AcknowledgedByID)
+	_, err = Acknowledge(urgent.ID, staff.ID)
+	assert.NoError(t, err, "acknowledging twice is harmless")
+	_, err = Acknowledge(urgent.ID, other.ID)
+	assert.ErrorIs(t, err, ErrAlreadyAcknowledged)
+	_, err = Acknowledge(normal.ID, staff.ID)
+	assert.ErrorIs(t, err, ErrNotPaged)
+
+	assert.NoError(t, PageUrgent(db, created.Add(time.Hour)))
+	assert.Equal(t, 3, reload().PageCount, "acknowledged pages stop")
+
+	var acks int64
+	db.Model(&models.TicketActivity{}).Where("ticket_id = ? AND action_type = ?", urgent.ID, models.ActivityAcknowledge).Count(&acks)
+	assert.Equal(t, int64(1), acks)
+}
+
+func TestClaim_AcknowledgesPage(t *testing.T) {
+	db := testutil.SetupTestDB()
+
+	requester := models.User{Email: "req@example.com", FullName: "Requester", Role: models.RoleConsumer, IsActive: true}
+	staff := models.User{Email: "staff@example.com", FullName: "Staff", Role: models.RoleStaff, IsActive: true}
+	db.Create(&requester)
+	db.Create(&staff)
+
+	tk := models.Ticket{Subject: "Encoder freeze", Location: models.LocationMCR, RequesterID: requester.ID,
+		Priority: models.PriorityUrgentOnAir}
+	db.Create(&tk)
+
+	claimed, err := Claim(tk.ID, staff.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, claimed.AcknowledgedAt)
+	assert.False(t, IsPaging(claimed))
+}
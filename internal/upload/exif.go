@@ -0,0 +1,118 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const gpsIFDTag = 0x8825
+
+// StripGPS removes the GPS block from a JPEG's Exif data. The entries and their values
+// are zeroed in place, so offsets stay valid and the rest of the metadata (orientation,
+// camera, timestamp) is kept. Anything that does not parse is returned unchanged.
+func StripGPS(jpeg []byte) []byte {
+	if len(jpeg) < 4 || jpeg[0] != 0xFF || jpeg[1] != 0xD8 {
+		return jpeg
+	}
+	out := jpeg
+	copied := false
+
+	for i := 2; i+4 <= len(out); {
+		if out[i] != 0xFF {
+			return out
+		}
+		marker := out[i+1]
+		if marker == 0xFF { // Fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // Image data starts, no metadata after this
+			return out
+		}
+		length := int(binary.BigEndian.Uint16(out[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(out) {
+			return out
+		}
+		payload := out[i+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			if !copied {
+				out = append([]byte(nil), out...)
+				payload = out[i+4 : end]
+				copied = true
+			}
+			stripGPSFromTIFF(payload[6:])
+		}
+		i = end
+	}
+	return out
+}
+
+// stripGPSFromTIFF finds the GPS IFD through IFD0 and empties it
+func stripGPSFromTIFF(tiff []byte) {
+	if len(tiff) < 8 {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	ifd0 := int(order.Uint32(tiff[4:]))
+	if ifd0+2 > len(tiff) {
+		return
+	}
+	n := int(order.Uint16(tiff[ifd0:]))
+	for e := 0; e < n; e++ {
+		entry := ifd0 + 2 + e*12
+		if entry+12 > len(tiff) {
+			return
+		}
+		if order.Uint16(tiff[entry:]) == gpsIFDTag {
+			clearIFD(tiff, order, int(order.Uint32(tiff[entry+8:])))
+			return
+		}
+	}
+}
+
+// clearIFD zeroes every entry of the IFD at offset, including values stored out of line,
+// and sets its entry count to 0
+func clearIFD(tiff []byte, order binary.ByteOrder, offset int) {
+	if offset <= 0 || offset+2 > len(tiff) {
+		return
+	}
+	n := int(order.Uint16(tiff[offset:]))
+	for e := 0; e < n; e++ {
+		entry := offset + 2 + e*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		size := int64(order.Uint32(tiff[entry+4:])) * int64(tiffTypeSize(order.Uint16(tiff[entry+2:])))
+		if size > 4 {
+			start := int64(order.Uint32(tiff[entry+8:]))
+			if start >= 0 && start+size <= int64(len(tiff)) {
+				clear(tiff[start : start+size])
+			}
+		}
+		clear(tiff[entry : entry+12])
+	}
+	order.PutUint16(tiff[offset:], 0)
+}
+
+func tiffTypeSize(t uint16) int {
+	switch t {
+	case 3, 8: // SHORT, SSHORT
+		return 2
+	case 4, 9, 11: // LONG, SLONG, FLOAT
+		return 4
+	case 5, 10, 12: // RATIONAL, SRATIONAL, DOUBLE
+		return 8
+	default: // BYTE, ASCII, UNDEFINED, ...
+		return 1
+	}
+}
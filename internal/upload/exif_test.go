@@ -0,0 +1,83 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exifJPEG builds a small JPEG whose Exif has an orientation tag and a GPS position
+func exifJPEG(t *testing.T) []byte {
+	var img bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+
+	le := binary.LittleEndian
+	tiff := make([]byte, 92)
+	copy(tiff, "II")
+	le.PutUint16(tiff[2:], 42)
+	le.PutUint32(tiff[4:], 8)
+	// IFD0: orientation and the GPS pointer
+	le.PutUint16(tiff[8:], 2)
+	le.PutUint16(tiff[10:], 0x0112)
+	le.PutUint16(tiff[12:], 3)
+	le.PutUint32(tiff[14:], 1)
+	le.PutUint16(tiff[18:], 6)
+	le.PutUint16(tiff[22:], gpsIFDTag)
+	le.PutUint16(tiff[24:], 4)
+	le.PutUint32(tiff[26:], 1)
+	le.PutUint32(tiff[30:], 38)
+	// GPS IFD: latitude ref (inline) and latitude (three rationals at 68)
+	le.PutUint16(tiff[38:], 2)
+	le.PutUint16(tiff[40:], 0x0001)
+	le.PutUint16(tiff[42:], 2)
+	le.PutUint32(tiff[44:], 2)
+	copy(tiff[48:], "S")
+	le.PutUint16(tiff[52:], 0x0002)
+	le.PutUint16(tiff[54:], 5)
+	le.PutUint32(tiff[56:], 3)
+	le.PutUint32(tiff[60:], 68)
+	for i, v := range []uint32{6, 1, 10, 1, 4512, 100} {
+		le.PutUint32(tiff[68+i*4:], v)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(payload)+2))
+	app1 = append(app1, payload...)
+
+	src := img.Bytes()
+	out := append([]byte{}, src[:2]...)
+	out = append(out, app1...)
+	return append(out, src[2:]...)
+}
+
+func TestStripGPS(t *testing.T) {
+	original := exifJPEG(t)
+	before := append([]byte(nil), original...)
+
+	stripped := StripGPS(original)
+	assert.Equal(t, before, original, "input is not modified")
+	assert.Equal(t, len(original), len(stripped))
+
+	tiff := stripped[2+4+6:]
+	le := binary.LittleEndian
+	assert.Zero(t, le.Uint16(tiff[38:]), "GPS IFD is empty")
+	assert.NotContains(t, string(tiff), "S\x00")
+	assert.Equal(t, make([]byte, 24), tiff[68:92], "coordinates are wiped")
+	assert.Equal(t, uint16(6), le.Uint16(tiff[18:]), "orientation is kept")
+
+	_, err := jpeg.Decode(bytes.NewReader(stripped))
+	assert.NoError(t, err)
+}
+
+func TestStripGPS_LeavesOtherDataAlone(t *testing.T) {
+	assert.Equal(t, []byte("not a jpeg"), StripGPS([]byte("not a jpeg")))
+
+	var img bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4)), nil))
+	assert.Equal(t, img.Bytes(), StripGPS(img.Bytes()))
+}
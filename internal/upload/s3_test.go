@@ -0,0 +1,51 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Runs against a local MinIO, e.g.
+// docker run -p 9000:9000 minio/minio server /data
+// MINIO_ENDPOINT=localhost:9000 go test ./internal/upload/
+func TestS3Storage_MinIO(t *testing.T) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("MINIO_ENDPOINT not set")
+	}
+	ctx := context.Background()
+	s, err := NewS3Storage(ctx, S3Config{
+		Endpoint:  endpoint,
+		AccessKey: envOr("MINIO_ACCESS_KEY", "minioadmin"),
+		SecretKey: envOr("MINIO_SECRET_KEY", "minioadmin"),
+		Bucket:    "goreport-test",
+	})
+	assert.NoError(t, err)
+
+	key := RandomKey("tests", ".txt")
+	assert.NoError(t, s.Put(ctx, key, strings.NewReader("halo"), 4, "text/plain"))
+
+	rc, obj, err := s.Get(ctx, key)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	assert.Equal(t, "halo", string(data))
+	assert.Equal(t, "text/plain", obj.ContentType)
+	assert.Equal(t, int64(4), obj.Size)
+
+	assert.NoError(t, s.Delete(ctx, key))
+	_, _, err = s.Get(ctx, key)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,122 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Object describes a stored file
+type Object struct {
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// Storage keeps uploaded files under slash separated keys such as tickets/3f2a....jpg.
+// Keys are generated by this package, never taken from the client.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get returns ErrNotFound when the key does not exist
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// CleanKey normalises a key and rejects anything escaping the storage root
+func CleanKey(key string) (string, error) {
+	key = path.Clean("/" + strings.ReplaceAll(key, "\\", "/"))[1:]
+	if key == "" || strings.HasPrefix(key, ".") {
+		return "", ErrNotFound
+	}
+	return key, nil
+}
+
+// LocalStorage keeps files on disk below Root. Root must not be served statically:
+// files are only readable through the authorised handler.
+type LocalStorage struct {
+	Root string
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{Root: root}
+}
+
+func (s *LocalStorage) path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStorage) Put(_ context.Context, key string, r io.Reader, _ int64, _ string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0750); err != nil {
+		return err
+	}
+	// Write next to the target and rename, so readers never see half a file
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *LocalStorage) Get(_ context.Context, key string) (io.ReadCloser, *Object, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, nil, ErrNotFound
+	}
+
+	// Disk has no metadata: sniff the type again, like the upload did
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, &Object{ContentType: http.DetectContentType(head[:n]), Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *LocalStorage) Delete(_ context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
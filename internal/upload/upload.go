@@ -0,0 +1,254 @@
+// Package upload validates uploaded files and keeps them in private storage.
+// Files are stored under random names and only served by the authorised /files handler.
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	ErrTooLarge = errors.New("File terlalu besar")
+	ErrType     = errors.New("Format file tidak didukung")
+	ErrNotFound = errors.New("File tidak ditemukan")
+	ErrMissing  = errors.New("Pilih file terlebih dahulu")
+)
+
+// ErrorStatus maps upload errors to HTTP status codes
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrMissing):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Policy limits what an upload field accepts
+type Policy struct {
+	MaxSize int64
+	// Types maps the sniffed content type to the extension the file is stored with
+	Types map[string]string
+	// Label lists the accepted formats in error messages
+	Label string
+}
+
+var imageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var documentTypes = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+var ticketTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"video/mp4":  ".mp4",
+	"video/webm": ".webm",
+}
+
+var (
+	ProofImagePolicy  = Policy{MaxSize: 5 << 20, Types: imageTypes, Label: "PNG, JPEG, GIF atau WebP"}
+	AvatarPolicy      = Policy{MaxSize: 2 << 20, Types: imageTypes, Label: "PNG, JPEG, GIF atau WebP"}
+	ArticleFilePolicy = Policy{MaxSize: 10 << 20, Types: documentTypes, Label: "PNG, JPEG, GIF, WebP atau PDF"}
+	// Photos of a fault and short videos of it
+	TicketFilePolicy = Policy{MaxSize: 20 << 20, Types: ticketTypes, Label: "foto PNG, JPEG, GIF, WebP atau video MP4, WebM"}
+)
+
+func (p Policy) tooLarge() error {
+	return fmt.Errorf("%w (maksimal %dMB)", ErrTooLarge, p.MaxSize>>20)
+}
+
+func (p Policy) wrongType() error {
+	return fmt.Errorf("%w (hanya %s)", ErrType, p.Label)
+}
+
+// File is a validated upload, ready to be stored
+type File struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// Read validates an upload against the policy. The type is sniffed from the content,
+// the client's file name and Content-Type header are never trusted. Location data is
+// removed from JPEG photos.
+func Read(r io.Reader, p Policy) (*File, error) {
+	data, err := io.ReadAll(io.LimitReader(r, p.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > p.MaxSize {
+		return nil, p.tooLarge()
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := p.Types[contentType]
+	if !ok {
+		return nil, p.wrongType()
+	}
+	if contentType == "image/jpeg" {
+		data = StripGPS(data)
+	}
+	return &File{Data: data, ContentType: contentType, Ext: ext}, nil
+}
+
+// ReadForm validates a multipart form file, rejecting oversized files before reading them
+func ReadForm(fh *multipart.FileHeader, p Policy) (*File, error) {
+	if fh.Size > p.MaxSize {
+		return nil, p.tooLarge()
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, p)
+}
+
+// RandomKey returns an unguessable key below prefix
+func RandomKey(prefix, ext string) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return strings.Trim(prefix, "/") + "/" + hex.EncodeToString(b) + ext
+}
+
+// Store saves a validated file under a random key below prefix and returns the key
+func Store(prefix string, f *File) (string, error) {
+	key := RandomKey(prefix, f.Ext)
+	if err := Put(key, f.Data, f.ContentType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Put saves data under an exact key, e.g. a thumbnail next to its original
+func Put(key string, data []byte, contentType string) error {
+	return store.Put(context.Background(), key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+// Save validates a form file and stores it, returning the URL to keep on the record
+func Save(fh *multipart.FileHeader, prefix string, p Policy) (string, error) {
+	f, err := ReadForm(fh, p)
+	if err != nil {
+		return "", err
+	}
+	key, err := Store(prefix, f)
+	if err != nil {
+		return "", err
+	}
+	return URL(key), nil
+}
+
+// URLPrefix is where the authorised handler serves stored files
+const URLPrefix = "/files/"
+
+func URL(key string) string {
+	return URLPrefix + key
+}
+
+// KeyFromURL returns the storage key of a URL made by URL
+func KeyFromURL(url string) (string, bool) {
+	if !strings.HasPrefix(url, URLPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, URLPrefix), true
+}
+
+// Remove deletes the file behind a URL made by URL. Other URLs (legacy uploads,
+// external avatars) are left alone.
+func Remove(url string) {
+	key, ok := KeyFromURL(url)
+	if !ok {
+		return
+	}
+	if err := store.Delete(context.Background(), key); err != nil {
+		log.Printf("[Upload] Removing %s failed: %v", key, err)
+	}
+}
+
+// Open reads a stored file
+func Open(key string) (io.ReadCloser, *Object, error) {
+	return store.Get(context.Background(), key)
+}
+
+// defaultDir is outside web/ so nothing can serve it statically by accident
+const defaultDir = "data/uploads"
+
+var store Storage = NewLocalStorage(defaultDir)
+
+// Current returns the configured storage
+func Current() Storage {
+	return store
+}
+
+// SetStorage replaces the storage, e.g. with a temporary directory in tests
+func SetStorage(s Storage) {
+	store = s
+}
+
+// Init selects the storage backend from the environment:
+// UPLOAD_STORAGE=local (default) keeps files in UPLOAD_DIR, UPLOAD_STORAGE=s3 uses
+// S3_ENDPOINT, S3_ACCESS_KEY, S3_SECRET_KEY, S3_BUCKET, S3_REGION and S3_USE_SSL.
+func Init() error {
+	switch backend := os.Getenv("UPLOAD_STORAGE"); backend {
+	case "", "local":
+		dir := os.Getenv("UPLOAD_DIR")
+		if dir == "" {
+			dir = defaultDir
+		}
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return err
+		}
+		store = NewLocalStorage(dir)
+		log.Println("[Upload] ✅ Storing files in", dir)
+	case "s3":
+		cfg := S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			UseSSL:    os.Getenv("S3_USE_SSL") != "false",
+		}
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			return errors.New("S3_ENDPOINT and S3_BUCKET are required for UPLOAD_STORAGE=s3")
+		}
+		s3, err := NewS3Storage(context.Background(), cfg)
+		if err != nil {
+			return err
+		}
+		store = s3
+		log.Printf("[Upload] ✅ Storing files in bucket %s at %s", cfg.Bucket, cfg.Endpoint)
+	default:
+		return fmt.Errorf("unknown UPLOAD_STORAGE %q", backend)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pngBytes(t *testing.T) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))))
+	return buf.Bytes()
+}
+
+func TestRead_SniffsAgainstPolicy(t *testing.T) {
+	_, err := Read(strings.NewReader("<html><script>alert(1)</script>"), ProofImagePolicy)
+	assert.ErrorIs(t, err, ErrType)
+	assert.Contains(t, err.Error(), "PNG, JPEG")
+
+	small := Policy{MaxSize: 16, Types: imageTypes}
+	_, err = Read(bytes.NewReader(pngBytes(t)), small)
+	assert.ErrorIs(t, err, ErrTooLarge)
+
+	f, err := Read(bytes.NewReader(pngBytes(t)), ProofImagePolicy)
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", f.ContentType)
+	assert.Equal(t, ".png", f.Ext)
+
+	f, err = Read(bytes.NewReader(exifJPEG(t)), ProofImagePolicy)
+	assert.NoError(t, err)
+	assert.Equal(t, StripGPS(exifJPEG(t)), f.Data)
+}
+
+func TestStore_LocalStorage(t *testing.T) {
+	SetStorage(NewLocalStorage(t.TempDir()))
+	defer SetStorage(NewLocalStorage(defaultDir))
+
+	f, err := Read(bytes.NewReader(pngBytes(t)), AvatarPolicy)
+	assert.NoError(t, err)
+	a, err := Store("avatars", f)
+	assert.NoError(t, err)
+	b, _ := Store("avatars", f)
+	assert.NotEqual(t, a, b, "random names")
+	assert.True(t, strings.HasPrefix(a, "avatars/") && strings.HasSuffix(a, ".png"))
+
+	key, ok := KeyFromURL(URL(a))
+	assert.True(t, ok)
+	rc, obj, err := Open(key)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	assert.Equal(t, f.Data, data)
+	assert.Equal(t, "image/png", obj.ContentType)
+
+	Remove(URL(a))
+	_, _, err = Open(a)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Keys never leave the root
+	_, _, err = Current().Get(context.Background(), "../../etc/passwd")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
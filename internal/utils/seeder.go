@@ -121,6 +121,7 @@ func SeedDatabase(c *gin.Context) {
 		Category:   "AUDIO",
 		AuthorID:   itPagi.ID,
 		IsVerified: true,
+		Status:     models.ArticlePublished,
 	}
 	database.DB.FirstOrCreate(&verifiedArticle, models.KnowledgeArticle{Title: verifiedArticle.Title})
 
@@ -131,6 +132,7 @@ func SeedDatabase(c *gin.Context) {
 		Category:   "IT_NETWORK",
 		AuthorID:   itMalam.ID,
 		IsVerified: false,
+		Status:     models.ArticleInReview,
 	}
 	database.DB.FirstOrCreate(&unverifiedArticle, models.KnowledgeArticle{Title: unverifiedArticle.Title})
 
@@ -141,7 +143,7 @@ func SeedDatabase(c *gin.Context) {
 	
 	instance := models.RoutineInstance{
 		TemplateID:     template.ID,
-		AssignedUserID: itPagi.ID, // Assign to Pagi
+		AssignedUserID: &itPagi.ID, // Assign to Pagi
 		ChecklistState: initialState,
 		GeneratedAt:    now,
 		DueAt:          due,
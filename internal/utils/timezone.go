@@ -0,0 +1,13 @@
+package utils
+
+import "time"
+
+// Jakarta returns the Asia/Jakarta location used for schedules and shift times.
+// Falls back to a fixed WIB offset when the server has no timezone data (e.g. minimal alpine/windows).
+func Jakarta() *time.Location {
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		return time.FixedZone("WIB", 7*3600)
+	}
+	return loc
+}
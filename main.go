@@ -6,8 +6,12 @@ import (
 	
 	"github.com/joho/godotenv"
 	"it-broadcast-ops/internal/database"
+	"it-broadcast-ops/internal/email"
+	"it-broadcast-ops/internal/notification"
 	redisClient "it-broadcast-ops/internal/redis"
+	"it-broadcast-ops/internal/scheduler"
 	"it-broadcast-ops/internal/server"
+	"it-broadcast-ops/internal/upload"
 	_ "it-broadcast-ops/docs" // Swagger docs
 )
 
@@ -23,7 +27,7 @@ import (
 
 // @securityDefinitions.apikey CookieAuth
 // @in cookie
-// @name user_id
+// @name g_session
 
 func main() {
 	// Load .env
@@ -39,6 +43,27 @@ func main() {
 		log.Println("⚠️  Redis not available. Chat will use polling instead of real-time.")
 	}
 
+	// Upload storage (local disk or S3)
+	if err := upload.Init(); err != nil {
+		log.Fatal("Upload storage failed to start: ", err)
+	}
+
+	// Email notifications (disabled without SMTP_HOST)
+	if err := email.Init(); err != nil {
+		log.Fatal("Email failed to start: ", err)
+	}
+
+	// Web push signing keys (stored encrypted in the database unless VAPID_* is set)
+	if err := notification.InitKeys(); err != nil {
+		log.Println("⚠️  Push notification keys failed to load, using a temporary key: ", err)
+	}
+
+	// Background jobs (routine generation, ...)
+	scheduler.Start()
+
+	// Outbox emails are sent on their own ticker
+	email.StartOutbox()
+
 	// Setup Router
 	r := server.NewRouter()
 
@@ -1,4 +1,4 @@
-const CACHE_NAME = 'it-ops-v2'; // Bump version to force update
+const CACHE_NAME = 'it-ops-v3'; // Bump version to force update
 const urlsToCache = [
   '/',
   '/auth/login',
@@ -69,8 +69,11 @@ self.addEventListener('push', function (event) {
     icon: 'https://cdn-icons-png.flaticon.com/512/2115/2115955.png',
     badge: 'https://cdn-icons-png.flaticon.com/512/2115/2115955.png',
     vibrate: [100, 50, 100],
-    data: { url: data.url },
-    tag: 'it-ops-notification'
+    data: { url: data.url, actions: data.actions || [] },
+    tag: data.tag || 'it-ops-notification',
+    renotify: !!data.tag,
+    requireInteraction: !!data.requireInteraction,
+    actions: (data.actions || []).map(a => ({ action: a.action, title: a.title }))
   };
 
   event.waitUntil(
@@ -80,6 +83,20 @@ self.addEventListener('push', function (event) {
 
 self.addEventListener('notificationclick', function (event) {
   event.notification.close();
+
+  // Action buttons (e.g. acknowledging an urgent page) are answered without opening the app
+  const action = (event.notification.data.actions || []).find(a => a.action === event.action);
+  if (action && action.url) {
+    event.waitUntil(
+      fetch(action.url, { method: 'POST', credentials: 'include', headers: { 'Accept': 'application/json' } })
+        .then(res => {
+          if (!res.ok) return clients.openWindow(event.notification.data.url);
+        })
+        .catch(() => clients.openWindow(event.notification.data.url))
+    );
+    return;
+  }
+
   event.waitUntil(
     clients.matchAll({ type: 'window', includeUncontrolled: true }).then(windowClients => {
       for (let client of windowClients) {